@@ -0,0 +1,74 @@
+package aws
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// BackoffPolicy decides how long a waiter sleeps between polls.
+type BackoffPolicy interface {
+	// Backoff returns the duration to sleep after the attempt-th poll (0-origin).
+	Backoff(attempt int) time.Duration
+}
+
+// ConstantBackoff sleeps the same interval between every poll.
+type ConstantBackoff struct {
+	Interval time.Duration
+}
+
+func (b *ConstantBackoff) Backoff(attempt int) time.Duration {
+	return b.Interval
+}
+
+// ExponentialBackoff multiplies the interval by Multiplier (2 if unset) after
+// every poll, starting at Initial and capped at Max if Max is positive.
+type ExponentialBackoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+}
+
+func (b *ExponentialBackoff) Backoff(attempt int) time.Duration {
+	m := b.Multiplier
+	if m <= 1 {
+		m = 2
+	}
+
+	// Cap the interval before converting it, which would overflow otherwise
+	max := time.Duration(math.MaxInt64)
+	if b.Max > 0 {
+		max = b.Max
+	}
+
+	d := float64(b.Initial)
+	for i := 0; i < attempt && d < float64(max); i++ {
+		d *= m
+	}
+	if d >= float64(max) {
+		return max
+	}
+	return time.Duration(d)
+}
+
+// ExponentialJitterBackoff behaves like ExponentialBackoff, but randomizes each
+// interval between half and the whole of the exponential one so that several
+// grabeni processes don't poll the API in lockstep.
+type ExponentialJitterBackoff ExponentialBackoff
+
+var (
+	jitterMu   sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+func (b *ExponentialJitterBackoff) Backoff(attempt int) time.Duration {
+	d := (*ExponentialBackoff)(b).Backoff(attempt)
+	if d <= 1 {
+		return d
+	}
+
+	jitterMu.Lock()
+	defer jitterMu.Unlock()
+	return d/2 + time.Duration(jitterRand.Int63n(int64(d/2)+1))
+}
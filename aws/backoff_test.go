@@ -0,0 +1,47 @@
+package aws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConstantBackoff(t *testing.T) {
+	b := &ConstantBackoff{Interval: 2 * time.Second}
+
+	assert.Equal(t, 2*time.Second, b.Backoff(0))
+	assert.Equal(t, 2*time.Second, b.Backoff(10))
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	b := &ExponentialBackoff{Initial: 100 * time.Millisecond, Max: time.Second}
+
+	assert.Equal(t, 100*time.Millisecond, b.Backoff(0))
+	assert.Equal(t, 200*time.Millisecond, b.Backoff(1))
+	assert.Equal(t, 800*time.Millisecond, b.Backoff(3))
+	assert.Equal(t, time.Second, b.Backoff(4))
+	assert.Equal(t, time.Second, b.Backoff(100))
+
+	b = &ExponentialBackoff{Initial: 100 * time.Millisecond, Multiplier: 3}
+
+	assert.Equal(t, 900*time.Millisecond, b.Backoff(2))
+	assert.True(t, b.Backoff(1000) > 0, "an uncapped interval must not overflow")
+
+	// Initial over Max is capped from the first poll
+	b = &ExponentialBackoff{Initial: 2 * time.Second, Max: time.Second}
+
+	assert.Equal(t, time.Second, b.Backoff(0))
+	assert.Equal(t, time.Second, b.Backoff(1))
+}
+
+func TestExponentialJitterBackoff(t *testing.T) {
+	b := &ExponentialJitterBackoff{Initial: 100 * time.Millisecond, Max: time.Second}
+
+	for attempt := 0; attempt < 10; attempt++ {
+		max := (&ExponentialBackoff{Initial: 100 * time.Millisecond, Max: time.Second}).Backoff(attempt)
+		d := b.Backoff(attempt)
+		assert.True(t, d >= max/2, "attempt %d: %s < %s", attempt, d, max/2)
+		assert.True(t, d <= max, "attempt %d: %s > %s", attempt, d, max)
+	}
+}
@@ -0,0 +1,107 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yuuki/grabeni/aws/model"
+)
+
+// CheckResult is the outcome of one of the checks by CheckGrabENI.
+type CheckResult struct {
+	Name   string
+	OK     bool
+	Detail string
+}
+
+// CheckGrabENIWithContext checks whether the ENI can be grabbed to the
+// instance as p without changing anything. An error is returned only if the
+// checks can't be run, such as the ENI or the instance not found.
+func (c *ENIClient) CheckGrabENIWithContext(ctx context.Context, p *GrabENIParam) ([]*CheckResult, error) {
+	eni, err := c.DescribeENIByIDWithContext(ctx, p.InterfaceID)
+	if err != nil {
+		return nil, err
+	}
+	instance, err := c.describeInstanceWithType(ctx, p.InstanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	attachedHere := eni.AttachedInstanceID() == instance.InstanceID()
+	deviceIndex, _ := decideDeviceIndex(p, eni, instance)
+
+	results := []*CheckResult{
+		checkEqual("availability zone", eni.AvailabilityZone(), instance.AvailabilityZone()),
+		checkEqual("vpc", eni.VpcID(), instance.VpcID()),
+		{
+			Name:   "instance state",
+			OK:     instance.StateName() == "running",
+			Detail: fmt.Sprintf("%s is %s", instance.InstanceID(), instance.StateName()),
+		},
+		checkDeviceIndex(eni, instance, int64(deviceIndex)),
+		checkENICapacity(instance, attachedHere),
+		{
+			Name:   "requester managed",
+			OK:     !eni.RequesterManaged(),
+			Detail: fmt.Sprintf("requester managed: %t", eni.RequesterManaged()),
+		},
+		{
+			Name:   "interface type",
+			OK:     eni.InterfaceType() != "trunk" && eni.InterfaceType() != "branch",
+			Detail: fmt.Sprintf("interface type: %s", eni.InterfaceType()),
+		},
+	}
+
+	return results, nil
+}
+
+func checkEqual(name, eniValue, instanceValue string) *CheckResult {
+	return &CheckResult{
+		Name:   name,
+		OK:     eniValue == instanceValue,
+		Detail: fmt.Sprintf("eni: %s, instance: %s", eniValue, instanceValue),
+	}
+}
+
+// checkENICapacity fails if the instance has no room for another ENI, or if
+// the limit of its instance type is unknown.
+func checkENICapacity(instance *model.Instance, attachedHere bool) *CheckResult {
+	attached, max := len(instance.NetworkInterfaces), instance.MaxNetworkInterfaces()
+	if max == 0 {
+		return &CheckResult{
+			Name:   "eni capacity",
+			OK:     attachedHere,
+			Detail: fmt.Sprintf("%d/unknown ENIs attached to %s (%s)", attached, instance.InstanceID(), instance.Type()),
+		}
+	}
+	return &CheckResult{
+		Name:   "eni capacity",
+		OK:     attachedHere || !instance.ENILimitReached(),
+		Detail: fmt.Sprintf("%d/%d ENIs attached to %s (%s)", attached, max, instance.InstanceID(), instance.Type()),
+	}
+}
+
+func checkDeviceIndex(eni *model.ENI, instance *model.Instance, deviceIndex int64) *CheckResult {
+	// The ENI itself may hold the index
+	if eni.AttachedInstanceID() == instance.InstanceID() && eni.AttachedDeviceIndex() == deviceIndex {
+		return &CheckResult{
+			Name:   "device index",
+			OK:     true,
+			Detail: fmt.Sprintf("device %d is held by %s itself", deviceIndex, eni.InterfaceID()),
+		}
+	}
+	for _, idx := range instance.AttachedDeviceIndexes() {
+		if idx == deviceIndex {
+			return &CheckResult{
+				Name:   "device index",
+				OK:     false,
+				Detail: fmt.Sprintf("device %d is used by another ENI", deviceIndex),
+			}
+		}
+	}
+	return &CheckResult{
+		Name:   "device index",
+		OK:     true,
+		Detail: fmt.Sprintf("device %d is free", deviceIndex),
+	}
+}
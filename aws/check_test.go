@@ -0,0 +1,119 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/yuuki/grabeni/aws/model"
+)
+
+func TestCheckGrabENI(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, mock.Anything).Return(
+		&ec2.DescribeNetworkInterfacesOutput{
+			NetworkInterfaces: []*ec2.NetworkInterface{
+				{
+					NetworkInterfaceId: aws.String("eni-00000001"),
+					Status:             aws.String("available"),
+					AvailabilityZone:   aws.String("ap-northeast-1a"),
+					VpcId:              aws.String("vpc-00000001"),
+					InterfaceType:      aws.String("interface"),
+					RequesterManaged:   aws.Bool(false),
+				},
+			},
+		}, nil)
+	mockEC2.On("DescribeInstancesWithContext", mock.Anything, mock.Anything).Return(&ec2.DescribeInstancesOutput{
+		Reservations: []*ec2.Reservation{
+			{
+				Instances: []*ec2.Instance{{
+					InstanceId:   aws.String("i-00000002"),
+					InstanceType: aws.String("t3.micro"),
+					State:        &ec2.InstanceState{Name: aws.String("running")},
+					Placement:    &ec2.Placement{AvailabilityZone: aws.String("ap-northeast-1c")},
+					VpcId:        aws.String("vpc-00000001"),
+					NetworkInterfaces: []*ec2.InstanceNetworkInterface{
+						{Attachment: &ec2.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int64(0)}},
+						{Attachment: &ec2.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int64(1)}},
+					},
+				}},
+			},
+		},
+	}, nil)
+	mockEC2.On("DescribeInstanceTypesPagesWithContext", mock.Anything, &ec2.DescribeInstanceTypesInput{
+		InstanceTypes: []*string{aws.String("t3.micro")},
+	}, mock.Anything).Run(returnPages(&ec2.DescribeInstanceTypesOutput{
+		InstanceTypes: []*ec2.InstanceTypeInfo{{
+			InstanceType: aws.String("t3.micro"),
+			NetworkInfo:  &ec2.NetworkInfo{MaximumNetworkInterfaces: aws.Int64(2)},
+		}},
+	})).Return(nil)
+
+	results, err := c.CheckGrabENIWithContext(context.Background(), &GrabENIParam{
+		InterfaceID: "eni-00000001",
+		InstanceID:  "i-00000002",
+		DeviceIndex: 1,
+	})
+
+	assert.NoError(t, err)
+	ok := make(map[string]bool)
+	for _, r := range results {
+		ok[r.Name] = r.OK
+	}
+	assert.Equal(t, map[string]bool{
+		"availability zone": false,
+		"vpc":               true,
+		"instance state":    true,
+		"device index":      false,
+		"eni capacity":      false,
+		"requester managed": true,
+		"interface type":    true,
+	}, ok)
+}
+
+func TestCheckGrabENIAutoDeviceIndexQuiet(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+	var logs bytes.Buffer
+	c.logger = log.New(&logs, "", 0)
+
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, mock.Anything).Return(
+		describeENIOutput("available", "", ""), nil)
+	mockEC2.On("DescribeInstancesWithContext", mock.Anything, mock.Anything).Return(
+		describeInstanceOutput("i-00000002", 0, 1), nil)
+
+	results, err := c.CheckGrabENIWithContext(context.Background(), &GrabENIParam{
+		InterfaceID: "eni-00000001",
+		InstanceID:  "i-00000002",
+		DeviceIndex: DeviceIndexAuto,
+	})
+
+	assert.NoError(t, err)
+	for _, r := range results {
+		if r.Name == "device index" {
+			assert.True(t, r.OK, r.Detail)
+		}
+	}
+	assert.Empty(t, logs.String())
+}
+
+func TestCheckENICapacityUnknown(t *testing.T) {
+	out := describeInstanceOutput("i-00000002", 0, 1)
+	out.Reservations[0].Instances[0].InstanceType = aws.String("t3.micro")
+	instance := model.NewInstance(out.Reservations[0].Instances[0])
+
+	r := checkENICapacity(instance, false)
+	assert.False(t, r.OK)
+	assert.Equal(t, "2/unknown ENIs attached to i-00000002 (t3.micro)", r.Detail)
+
+	// An ENI already attached to the instance needs no room
+	assert.True(t, checkENICapacity(instance, true).OK)
+}
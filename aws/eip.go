@@ -0,0 +1,202 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/yuuki/grabeni/aws/model"
+)
+
+type GrabEIPParam struct {
+	// Address is the allocation ID or the public IP of the Elastic IP.
+	Address string
+	// InterfaceID or InstanceID is the association target. The address is
+	// associated with the primary ENI of InstanceID if InterfaceID is empty.
+	InterfaceID string
+	InstanceID  string
+	// PrivateIP is the private IP of the ENI to associate with, the primary
+	// private IP if empty.
+	PrivateIP string
+	// DryRun only checks whether the request would succeed.
+	DryRun bool
+}
+
+// EIPMove is the result of GrabEIP.
+type EIPMove struct {
+	// Previous is the address before the move, which may not be associated.
+	Previous *model.Address
+	// Current is the address after the move, nil on a dry run.
+	Current *model.Address
+}
+
+func (c *ENIClient) DescribeAddresses() ([]*model.Address, error) {
+	return c.DescribeAddressesWithContext(context.Background())
+}
+
+// DescribeAddressesWithContext describes all the Elastic IPs for use in VPC.
+func (c *ENIClient) DescribeAddressesWithContext(ctx context.Context) ([]*model.Address, error) {
+	resp, err := c.svc.DescribeAddressesWithContext(ctx, &ec2.DescribeAddressesInput{
+		Filters: []*ec2.Filter{{
+			Name:   aws.String("domain"),
+			Values: aws.StringSlice([]string{"vpc"}),
+		}},
+	})
+	if err != nil {
+		return nil, wrapContextError(ctx, "describe", "addresses", err)
+	}
+
+	addrs := make([]*model.Address, 0, len(resp.Addresses))
+	for _, a := range resp.Addresses {
+		addrs = append(addrs, model.NewAddress(a))
+	}
+	return addrs, nil
+}
+
+func (c *ENIClient) DescribeAddress(address string) (*model.Address, error) {
+	return c.DescribeAddressWithContext(context.Background(), address)
+}
+
+// DescribeAddressWithContext describes the Elastic IP by its allocation ID or
+// its public IP.
+func (c *ENIClient) DescribeAddressWithContext(ctx context.Context, address string) (*model.Address, error) {
+	input := &ec2.DescribeAddressesInput{}
+	if strings.HasPrefix(address, "eipalloc-") {
+		input.AllocationIds = aws.StringSlice([]string{address})
+	} else {
+		input.PublicIps = aws.StringSlice([]string{address})
+	}
+
+	resp, err := c.svc.DescribeAddressesWithContext(ctx, input)
+	if err != nil {
+		var aerr awserr.Error
+		if errors.As(err, &aerr) && (aerr.Code() == "InvalidAllocationID.NotFound" || aerr.Code() == "InvalidAddress.NotFound") {
+			return nil, &AddressNotFoundError{Address: address}
+		}
+		return nil, wrapContextError(ctx, "describe", address, err)
+	}
+	if len(resp.Addresses) < 1 {
+		return nil, &AddressNotFoundError{Address: address}
+	}
+
+	return model.NewAddress(resp.Addresses[0]), nil
+}
+
+func (c *ENIClient) GrabEIP(p *GrabEIPParam, wp *WaiterParam) (*EIPMove, error) {
+	return c.GrabEIPWithContext(context.Background(), p, wp)
+}
+
+// GrabEIPWithContext associates the Elastic IP with the ENI or the instance
+// of p, taking it over from its current association, and waits until the
+// association is visible. The previous association is restored if the new one
+// doesn't settle. It returns nil without error if the address is already
+// associated with the target.
+func (c *ENIClient) GrabEIPWithContext(ctx context.Context, p *GrabEIPParam, wp *WaiterParam) (*EIPMove, error) {
+	if err := validateWaitUntilParam(wp); err != nil {
+		return nil, err
+	}
+	ctx, cancel := withOpTimeout(ctx, wp)
+	defer cancel()
+	if p.InterfaceID == "" && p.InstanceID == "" {
+		return nil, fmt.Errorf("ENI or instance to associate %s with required", p.Address)
+	}
+
+	prev, err := c.DescribeAddressWithContext(ctx, p.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	// Do nothing if the address is already associated with the target
+	if p.associatedWith(prev) {
+		return nil, nil
+	}
+
+	input := &ec2.AssociateAddressInput{
+		AllocationId:       aws.String(prev.AllocationID()),
+		AllowReassociation: aws.Bool(true),
+	}
+	target := p.InstanceID
+	if p.InterfaceID != "" {
+		input.NetworkInterfaceId = aws.String(p.InterfaceID)
+		target = p.InterfaceID
+	} else {
+		input.InstanceId = aws.String(p.InstanceID)
+	}
+	if p.PrivateIP != "" {
+		input.PrivateIpAddress = aws.String(p.PrivateIP)
+	}
+	if p.DryRun {
+		input.DryRun = aws.Bool(true)
+	}
+
+	resp, err := c.svc.AssociateAddressWithContext(ctx, input)
+	if p.DryRun && isDryRunOperation(err) {
+		return &EIPMove{Previous: prev}, nil
+	}
+	if err != nil {
+		return nil, wrapContextError(ctx, "associate", p.Address, translateAPIError(err, p.InterfaceID, p.InstanceID))
+	}
+
+	c.logger.Printf("--> Associating: %15s to %s\n", prev.PublicIP(), target)
+
+	// Wait until associate event completed or timeout
+	var cur *model.Address
+	err = c.waitUntil(ctx, "associate", p.Address, wp, func(ctx context.Context) (bool, error) {
+		var err error
+		if cur, err = c.DescribeAddressWithContext(ctx, prev.AllocationID()); err != nil {
+			return false, err
+		}
+		return p.associatedWith(cur), nil
+	})
+	if err != nil {
+		return nil, c.restoreAddress(prev, aws.StringValue(resp.AssociationId), err)
+	}
+
+	c.logger.Printf("--> Associated: %15s to %s\n", prev.PublicIP(), target)
+	return &EIPMove{Previous: prev, Current: cur}, nil
+}
+
+func (p *GrabEIPParam) associatedWith(addr *model.Address) bool {
+	if p.PrivateIP != "" && addr.PrivateIpAddress() != p.PrivateIP {
+		return false
+	}
+	if p.InterfaceID != "" {
+		return addr.NetworkInterfaceID() == p.InterfaceID
+	}
+	return addr.InstanceID() == p.InstanceID
+}
+
+// restoreAddress puts the association of the address back to prev. It doesn't
+// inherit the context of the grab so that a canceled grab is still restored.
+func (c *ENIClient) restoreAddress(prev *model.Address, associationID string, cause error) error {
+	ctx := context.Background()
+
+	var err error
+	target := "no association"
+	if prev.Associated() {
+		input := &ec2.AssociateAddressInput{
+			AllocationId:       aws.String(prev.AllocationID()),
+			NetworkInterfaceId: aws.String(prev.NetworkInterfaceID()),
+			AllowReassociation: aws.Bool(true),
+		}
+		target = prev.NetworkInterfaceID()
+		if prev.PrivateIpAddress() != "" {
+			input.PrivateIpAddress = aws.String(prev.PrivateIpAddress())
+			target = fmt.Sprintf("%s (%s)", prev.NetworkInterfaceID(), prev.PrivateIpAddress())
+		}
+		c.logger.Printf("--> Restoring: %15s to %s\n", prev.PublicIP(), target)
+		_, err = c.svc.AssociateAddressWithContext(ctx, input)
+	} else if associationID != "" {
+		c.logger.Printf("--> Restoring: %15s to %s\n", prev.PublicIP(), target)
+		_, err = c.svc.DisassociateAddressWithContext(ctx, &ec2.DisassociateAddressInput{
+			AssociationId: aws.String(associationID),
+		})
+	}
+
+	return &RollbackError{Err: cause, RollbackErr: err, Target: target}
+}
@@ -0,0 +1,205 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/yuuki/grabeni/aws/model"
+)
+
+// Build a DescribeAddresses response for eipalloc-00000001 associated with interfaceID
+func describeAddressOutput(interfaceID string) *ec2.DescribeAddressesOutput {
+	addr := &ec2.Address{
+		AllocationId: aws.String("eipalloc-00000001"),
+		PublicIp:     aws.String("203.0.113.10"),
+		Domain:       aws.String("vpc"),
+	}
+	if interfaceID != "" {
+		addr.AssociationId = aws.String("eipassoc-" + interfaceID)
+		addr.NetworkInterfaceId = aws.String(interfaceID)
+		addr.PrivateIpAddress = aws.String("10.0.0.10")
+	}
+	return &ec2.DescribeAddressesOutput{Addresses: []*ec2.Address{addr}}
+}
+
+var allocationInput = &ec2.DescribeAddressesInput{
+	AllocationIds: []*string{aws.String("eipalloc-00000001")},
+}
+
+func TestGrabEIP(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockEC2.On("DescribeAddressesWithContext", mock.Anything, &ec2.DescribeAddressesInput{
+		PublicIps: []*string{aws.String("203.0.113.10")},
+	}).Return(describeAddressOutput("eni-00000001"), nil).Once()
+	mockEC2.On("AssociateAddressWithContext", mock.Anything, &ec2.AssociateAddressInput{
+		AllocationId:       aws.String("eipalloc-00000001"),
+		NetworkInterfaceId: aws.String("eni-00000002"),
+		AllowReassociation: aws.Bool(true),
+	}).Return(&ec2.AssociateAddressOutput{AssociationId: aws.String("eipassoc-eni-00000002")}, nil).Once()
+	mockEC2.On("DescribeAddressesWithContext", mock.Anything, allocationInput).Return(
+		describeAddressOutput("eni-00000001"), nil).Once()
+	mockEC2.On("DescribeAddressesWithContext", mock.Anything, allocationInput).Return(
+		describeAddressOutput("eni-00000002"), nil).Once()
+
+	move, err := c.GrabEIPWithContext(context.Background(), &GrabEIPParam{
+		Address:     "203.0.113.10",
+		InterfaceID: "eni-00000002",
+	}, &WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, move) {
+		assert.Equal(t, "eni-00000001", move.Previous.NetworkInterfaceID())
+		assert.Equal(t, "eni-00000002", move.Current.NetworkInterfaceID())
+	}
+	mockEC2.AssertExpectations(t)
+}
+
+func TestGrabEIPAlreadyAssociated(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockEC2.On("DescribeAddressesWithContext", mock.Anything, allocationInput).Return(
+		describeAddressOutput("eni-00000002"), nil)
+
+	move, err := c.GrabEIPWithContext(context.Background(), &GrabEIPParam{
+		Address:     "eipalloc-00000001",
+		InterfaceID: "eni-00000002",
+	}, &WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+	assert.NoError(t, err)
+	assert.Nil(t, move)
+	mockEC2.AssertNotCalled(t, "AssociateAddressWithContext", mock.Anything, mock.Anything)
+}
+
+func TestGrabEIPDryRun(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockEC2.On("DescribeAddressesWithContext", mock.Anything, allocationInput).Return(
+		describeAddressOutput(""), nil)
+	mockEC2.On("AssociateAddressWithContext", mock.Anything, &ec2.AssociateAddressInput{
+		AllocationId:       aws.String("eipalloc-00000001"),
+		InstanceId:         aws.String("i-00000002"),
+		AllowReassociation: aws.Bool(true),
+		DryRun:             aws.Bool(true),
+	}).Return(nil, awserr.New("DryRunOperation", "Request would have succeeded, but DryRun flag is set.", nil)).Once()
+
+	move, err := c.GrabEIPWithContext(context.Background(), &GrabEIPParam{
+		Address:    "eipalloc-00000001",
+		InstanceID: "i-00000002",
+		DryRun:     true,
+	}, &WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, move) {
+		assert.False(t, move.Previous.Associated())
+		assert.Nil(t, move.Current)
+	}
+	mockEC2.AssertExpectations(t)
+}
+
+func TestGrabEIPRestore(t *testing.T) {
+	tests := []struct {
+		name     string
+		prev     string
+		target   string
+		restored string
+		calls    int
+	}{
+		{
+			name:     "reassociated with the previous ENI",
+			prev:     "eni-00000001",
+			target:   "eni-00000001 (10.0.0.10)",
+			restored: "AssociateAddressWithContext",
+			calls:    2,
+		},
+		{
+			name:     "disassociated",
+			prev:     "",
+			target:   "no association",
+			restored: "DisassociateAddressWithContext",
+			calls:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		mockEC2 := new(EC2API)
+		c := newClient(mockEC2)
+
+		mockEC2.On("DescribeAddressesWithContext", mock.Anything, allocationInput).Return(
+			describeAddressOutput(tt.prev), nil)
+		mockEC2.On("AssociateAddressWithContext", mock.Anything, &ec2.AssociateAddressInput{
+			AllocationId:       aws.String("eipalloc-00000001"),
+			NetworkInterfaceId: aws.String("eni-00000002"),
+			AllowReassociation: aws.Bool(true),
+		}).Return(&ec2.AssociateAddressOutput{AssociationId: aws.String("eipassoc-eni-00000002")}, nil).Once()
+		mockEC2.On("AssociateAddressWithContext", mock.Anything, &ec2.AssociateAddressInput{
+			AllocationId:       aws.String("eipalloc-00000001"),
+			NetworkInterfaceId: aws.String("eni-00000001"),
+			PrivateIpAddress:   aws.String("10.0.0.10"),
+			AllowReassociation: aws.Bool(true),
+		}).Return(&ec2.AssociateAddressOutput{}, nil)
+		mockEC2.On("DisassociateAddressWithContext", mock.Anything, &ec2.DisassociateAddressInput{
+			AssociationId: aws.String("eipassoc-eni-00000002"),
+		}).Return(&ec2.DisassociateAddressOutput{}, nil)
+
+		move, err := c.GrabEIPWithContext(context.Background(), &GrabEIPParam{
+			Address:     "eipalloc-00000001",
+			InterfaceID: "eni-00000002",
+		}, &WaiterParam{MaxAttempts: 2, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+		assert.Nil(t, move, tt.name)
+		var rollbackErr *RollbackError
+		if assert.True(t, errors.As(err, &rollbackErr), tt.name) {
+			assert.True(t, rollbackErr.RolledBack(), tt.name)
+			assert.Equal(t, tt.target, rollbackErr.Target, tt.name)
+		}
+		var timeout *WaitTimeoutError
+		assert.True(t, errors.As(err, &timeout), tt.name)
+		mockEC2.AssertNumberOfCalls(t, tt.restored, tt.calls)
+	}
+}
+
+func TestRestoreAddressWithoutPrivateIP(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	out := describeAddressOutput("eni-00000001")
+	out.Addresses[0].PrivateIpAddress = nil
+	mockEC2.On("AssociateAddressWithContext", mock.Anything, &ec2.AssociateAddressInput{
+		AllocationId:       aws.String("eipalloc-00000001"),
+		NetworkInterfaceId: aws.String("eni-00000001"),
+		AllowReassociation: aws.Bool(true),
+	}).Return(&ec2.AssociateAddressOutput{}, nil).Once()
+
+	err := c.restoreAddress(model.NewAddress(out.Addresses[0]), "eipassoc-eni-00000002", errors.New("failed"))
+
+	var rollbackErr *RollbackError
+	if assert.True(t, errors.As(err, &rollbackErr)) {
+		assert.True(t, rollbackErr.RolledBack())
+		assert.Equal(t, "eni-00000001", rollbackErr.Target)
+	}
+	mockEC2.AssertExpectations(t)
+}
+
+func TestDescribeAddressNotFound(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockEC2.On("DescribeAddressesWithContext", mock.Anything, allocationInput).Return(
+		nil, awserr.New("InvalidAllocationID.NotFound", "The allocation ID 'eipalloc-00000001' does not exist", nil))
+
+	addr, err := c.DescribeAddress("eipalloc-00000001")
+
+	assert.Nil(t, addr)
+	assert.EqualError(t, err, "no such Elastic IP eipalloc-00000001")
+}
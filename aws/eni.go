@@ -1,10 +1,12 @@
 package aws
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -15,42 +17,84 @@ import (
 	"github.com/yuuki/grabeni/aws/model"
 )
 
+const (
+	// The number of instance IDs per DescribeInstances request
+	describeInstancesBatchSize = 100
+	// The maximum number of DescribeInstances requests in flight
+	describeInstancesConcurrency = 4
+)
+
+// DeviceIndexAuto makes AttachENI and GrabENI pick the lowest device index
+// free on the target instance.
+const DeviceIndexAuto = -1
+
 type ENIClient struct {
 	svc       ec2iface.EC2API
 	logger    *log.Logger
 	logWriter io.Writer
+
+	// instanceTypes caches the instance type info by type name
+	instanceTypesMu sync.Mutex
+	instanceTypes   map[string]*ec2.InstanceTypeInfo
 }
 
 type AttachENIParam struct {
 	InterfaceID string
 	InstanceID  string
+	// DeviceIndex may be DeviceIndexAuto.
 	DeviceIndex int
+	// DryRun only checks whether the request would succeed.
+	DryRun bool
 }
 
 type DetachENIParam struct {
 	InterfaceID string
+	// Force escalates to a forced detach if a normal detach has not completed
+	// within ForceGracePeriod.
+	Force            bool
+	ForceGracePeriod time.Duration
+	// DryRun only checks whether the request would succeed.
+	DryRun bool
 }
 
-type GrabENIParam AttachENIParam
-
-type WaiterParam struct {
-	MaxAttempts int
-	IntervalSec int
-}
-
-func validateWaitUntilParam(p *WaiterParam) error {
-	if p == nil {
-		return fmt.Errorf("WaitUntilParam require")
-	}
-
-	if p.MaxAttempts <= 0 {
-		return fmt.Errorf("invalid max attempts (%d)", p.MaxAttempts)
-	}
-	if p.IntervalSec <= 0 {
-		return fmt.Errorf("invalid interval (%d) seconds", p.IntervalSec)
-	}
-
-	return nil
+type GrabENIParam struct {
+	InterfaceID string
+	InstanceID  string
+	// DeviceIndex may be DeviceIndexAuto.
+	DeviceIndex int
+	// PreserveDeviceIndex attaches the ENI at the device index it had on the
+	// previous instance if it is free on InstanceID, falling back to DeviceIndex.
+	PreserveDeviceIndex bool
+	// ForceDetach and ForceDetachGracePeriod are passed on to the detach step
+	// as DetachENIParam.Force and DetachENIParam.ForceGracePeriod.
+	ForceDetach            bool
+	ForceDetachGracePeriod time.Duration
+	// Rollback reattaches the ENI to the instance and device index it was
+	// detached from if attaching to InstanceID fails.
+	Rollback bool
+	// LeaseTTL, if positive, takes the lease of the ENI for InstanceID before
+	// detaching it, so that a concurrent grab by another instance backs off.
+	// The lease is kept after the grab, and released if the grab fails.
+	// LeaseSettleDelay and StealLease are passed on as LeaseParam.SettleDelay
+	// and LeaseParam.Steal.
+	LeaseTTL         time.Duration
+	LeaseSettleDelay time.Duration
+	StealLease       bool
+	// Fence, if set, fences the instance the ENI is attached to by the method
+	// before detaching the ENI, and the grab is aborted if fencing fails
+	// unless SkipFenceOnError is set. FenceIsolate isolates the ENIs of the
+	// instance but the grabbed one into QuarantineSecurityGroupID.
+	Fence                     string
+	QuarantineSecurityGroupID string
+	SkipFenceOnError          bool
+
+	// fenceExclude are the other ENIs grabbed along with the ENI, which
+	// FenceIsolate leaves alone too.
+	fenceExclude []string
+	// rollbackFrom, if set, is the only instance that the ENI may be detached
+	// from, so that a rollback doesn't take the ENI away from an instance
+	// that has grabbed it in the meantime.
+	rollbackFrom string
 }
 
 func NewENIClient() *ENIClient {
@@ -74,18 +118,22 @@ func (c *ENIClient) WithLogWriter(w io.Writer) *ENIClient {
 }
 
 func (c *ENIClient) DescribeENIByID(InterfaceID string) (*model.ENI, error) {
+	return c.DescribeENIByIDWithContext(context.Background(), InterfaceID)
+}
+
+func (c *ENIClient) DescribeENIByIDWithContext(ctx context.Context, InterfaceID string) (*model.ENI, error) {
 	params := &ec2.DescribeNetworkInterfacesInput{
 		NetworkInterfaceIds: []*string{
 			aws.String(InterfaceID),
 		},
 	}
-	resp, err := c.svc.DescribeNetworkInterfaces(params)
+	resp, err := c.svc.DescribeNetworkInterfacesWithContext(ctx, params)
 	if err != nil {
-		return nil, err
+		return nil, wrapContextError(ctx, "describe", InterfaceID, translateAPIError(err, InterfaceID, ""))
 	}
 
 	if len(resp.NetworkInterfaces) < 1 {
-		return nil, nil
+		return nil, &ENINotFoundError{InterfaceID: InterfaceID}
 	}
 
 	eni := model.NewENI(resp.NetworkInterfaces[0])
@@ -95,7 +143,7 @@ func (c *ENIClient) DescribeENIByID(InterfaceID string) (*model.ENI, error) {
 	if instanceId == "" {
 		return eni, nil
 	}
-	instance, err := c.DescribeInstanceByID(instanceId)
+	instance, err := c.DescribeInstanceByIDWithContext(ctx, instanceId)
 	if err != nil {
 		return nil, err
 	}
@@ -104,19 +152,36 @@ func (c *ENIClient) DescribeENIByID(InterfaceID string) (*model.ENI, error) {
 	return eni, nil
 }
 
-func (c *ENIClient) DescribeENIs() ([]*model.ENI, error) {
-	resp, err := c.svc.DescribeNetworkInterfaces(nil)
+// DescribeENIs describes the ENIs passing f, or all the ENIs if f is nil.
+func (c *ENIClient) DescribeENIs(f *ListFilter) ([]*model.ENI, error) {
+	return c.DescribeENIsWithContext(context.Background(), f)
+}
+
+func (c *ENIClient) DescribeENIsWithContext(ctx context.Context, f *ListFilter) ([]*model.ENI, error) {
+	match, err := f.matcher()
 	if err != nil {
 		return nil, err
 	}
 
-	if len(resp.NetworkInterfaces) < 1 {
-		return nil, nil
+	enis := make([]*model.ENI, 0)
+	input := &ec2.DescribeNetworkInterfacesInput{
+		MaxResults: aws.Int64(1000),
+		Filters:    f.ec2Filters(),
+	}
+	err = c.svc.DescribeNetworkInterfacesPagesWithContext(ctx, input, func(page *ec2.DescribeNetworkInterfacesOutput, lastPage bool) bool {
+		for _, iface := range page.NetworkInterfaces {
+			if eni := model.NewENI(iface); match(eni) {
+				enis = append(enis, eni)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, wrapContextError(ctx, "describe", "network interfaces", err)
 	}
 
-	enis := make([]*model.ENI, 0)
-	for _, iface := range resp.NetworkInterfaces {
-		enis = append(enis, model.NewENI(iface))
+	if len(enis) < 1 {
+		return nil, nil
 	}
 
 	instanceIDs := make([]string, 0)
@@ -126,7 +191,7 @@ func (c *ENIClient) DescribeENIs() ([]*model.ENI, error) {
 		}
 	}
 
-	instances, err := c.DescribeInstancesByIDs(instanceIDs)
+	instances, err := c.DescribeInstancesByIDsWithContext(ctx, instanceIDs)
 	if err != nil {
 		return nil, err
 	}
@@ -154,7 +219,11 @@ func (c *ENIClient) DescribeENIs() ([]*model.ENI, error) {
 }
 
 func (c *ENIClient) AttachENI(param *AttachENIParam) (*model.ENI, error) {
-	eni, err := c.DescribeENIByID(param.InterfaceID)
+	return c.AttachENIWithContext(context.Background(), param)
+}
+
+func (c *ENIClient) AttachENIWithContext(ctx context.Context, param *AttachENIParam) (*model.ENI, error) {
+	eni, err := c.DescribeENIByIDWithContext(ctx, param.InterfaceID)
 	if err != nil {
 		return nil, err
 	}
@@ -163,66 +232,114 @@ func (c *ENIClient) AttachENI(param *AttachENIParam) (*model.ENI, error) {
 	if eni.AttachedInstanceID() == param.InstanceID {
 		return nil, nil
 	}
+	if id := eni.AttachedInstanceID(); id != "" {
+		return nil, &AlreadyAttachedError{InterfaceID: param.InterfaceID, InstanceID: id}
+	}
+
+	instance, err := c.describeInstanceWithType(ctx, param.InstanceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkENILimit(instance); err != nil {
+		return nil, err
+	}
+
+	if param.DeviceIndex == DeviceIndexAuto {
+		resolved := *param
+		resolved.DeviceIndex = int(instance.FreeDeviceIndex())
+		c.logger.Printf("--> Device index: %d (auto)\n", resolved.DeviceIndex)
+		param = &resolved
+	}
+
+	if err := c.attachENI(ctx, param); err != nil {
+		return nil, err
+	}
+
+	return eni, nil
+}
 
+// attachENI calls AttachNetworkInterface without looking at the current state of the ENI.
+func (c *ENIClient) attachENI(ctx context.Context, param *AttachENIParam) error {
 	input := &ec2.AttachNetworkInterfaceInput{
 		NetworkInterfaceId: aws.String(param.InterfaceID),
 		InstanceId:         aws.String(param.InstanceID),
 		DeviceIndex:        aws.Int64(int64(param.DeviceIndex)),
 	}
-	_, err = c.svc.AttachNetworkInterface(input)
-	if err != nil {
-		return nil, err
+	if param.DryRun {
+		input.DryRun = aws.Bool(true)
+	}
+	_, err := c.svc.AttachNetworkInterfaceWithContext(ctx, input)
+	if err != nil && !(param.DryRun && isDryRunOperation(err)) {
+		return wrapContextError(ctx, "attach", param.InterfaceID, translateAPIError(err, param.InterfaceID, param.InstanceID))
 	}
 
-	return eni, nil
+	return nil
 }
 
 func (c *ENIClient) AttachENIWithWaiter(p *AttachENIParam, wp *WaiterParam) (*model.ENI, error) {
+	return c.AttachENIWithWaiterWithContext(context.Background(), p, wp)
+}
+
+func (c *ENIClient) AttachENIWithWaiterWithContext(ctx context.Context, p *AttachENIParam, wp *WaiterParam) (*model.ENI, error) {
 	if err := validateWaitUntilParam(wp); err != nil {
 		return nil, err
 	}
+	ctx, cancel := withOpTimeout(ctx, wp)
+	defer cancel()
 
-	if eni, err := c.AttachENI(p); eni == nil || err != nil {
-		return nil, err
+	if eni, err := c.AttachENIWithContext(ctx, p); eni == nil || err != nil || p.DryRun {
+		return eni, err
 	}
 
 	c.logger.Printf("--> Attaching: %15s\n", p.InterfaceID)
 
 	// Wait until attach event completed or timeout
-	for i := 0; i < wp.MaxAttempts; i++ {
-		fmt.Fprint(c.logWriter, ".") // use fmt.Fprint because standard log package always newline
-
-		eni, err := c.DescribeENIByID(p.InterfaceID)
-		if err != nil {
-			return nil, err
+	var eni *model.ENI
+	err := c.waitUntil(ctx, "attach", p.InterfaceID, wp, func(ctx context.Context) (bool, error) {
+		var err error
+		if eni, err = c.DescribeENIByIDWithContext(ctx, p.InterfaceID); err != nil {
+			return false, err
 		}
-		if eni.Status() == "in-use" && eni.AttachedStatus() == "attached" {
-			c.logger.Println()
-			c.logger.Printf("--> Attached: %15s\n", p.InterfaceID)
-			return eni, nil // detach completed
-		}
-
-		time.Sleep(time.Duration(wp.IntervalSec) * time.Second)
+		return eni.Status() == "in-use" && eni.AttachedStatus() == "attached", nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, fmt.Errorf("attach %s error: over %d polling attempts", p.InterfaceID, wp.MaxAttempts)
+	c.logger.Printf("--> Attached: %15s\n", p.InterfaceID)
+	return eni, nil
 }
 
 func (c *ENIClient) DetachENIByAttachmentID(attachmentID string) error {
+	return c.DetachENIByAttachmentIDWithContext(context.Background(), attachmentID, false)
+}
+
+func (c *ENIClient) DetachENIByAttachmentIDWithContext(ctx context.Context, attachmentID string, force bool) error {
+	return c.detachENIByAttachmentID(ctx, attachmentID, force, false)
+}
+
+func (c *ENIClient) detachENIByAttachmentID(ctx context.Context, attachmentID string, force, dryRun bool) error {
 	params := &ec2.DetachNetworkInterfaceInput{
 		AttachmentId: aws.String(attachmentID),
-		Force:        aws.Bool(false),
+		Force:        aws.Bool(force),
 	}
-	_, err := c.svc.DetachNetworkInterface(params)
-	if err != nil {
-		return err
+	if dryRun {
+		params.DryRun = aws.Bool(true)
+	}
+	_, err := c.svc.DetachNetworkInterfaceWithContext(ctx, params)
+	if err != nil && !(dryRun && isDryRunOperation(err)) {
+		return wrapContextError(ctx, "detach", attachmentID, err)
 	}
 
 	return nil
 }
 
 func (c *ENIClient) DetachENI(param *DetachENIParam) (*model.ENI, error) {
-	eni, err := c.DescribeENIByID(param.InterfaceID)
+	return c.DetachENIWithContext(context.Background(), param)
+}
+
+func (c *ENIClient) DetachENIWithContext(ctx context.Context, param *DetachENIParam) (*model.ENI, error) {
+	eni, err := c.DescribeENIByIDWithContext(ctx, param.InterfaceID)
 	if err != nil {
 		return nil, err
 	}
@@ -232,7 +349,12 @@ func (c *ENIClient) DetachENI(param *DetachENIParam) (*model.ENI, error) {
 		return nil, nil
 	}
 
-	if err := c.DetachENIByAttachmentID(eni.AttachmentID()); err != nil {
+	// Only wait for the detachment if someone else has already started it
+	if eni.AttachedStatus() == "detaching" && !param.DryRun {
+		return eni, nil
+	}
+
+	if err := c.detachENIByAttachmentID(ctx, eni.AttachmentID(), false, param.DryRun); err != nil {
 		return nil, err
 	}
 
@@ -240,100 +362,413 @@ func (c *ENIClient) DetachENI(param *DetachENIParam) (*model.ENI, error) {
 }
 
 func (c *ENIClient) DetachENIWithWaiter(p *DetachENIParam, wp *WaiterParam) (*model.ENI, error) {
+	return c.DetachENIWithWaiterWithContext(context.Background(), p, wp)
+}
+
+func (c *ENIClient) DetachENIWithWaiterWithContext(ctx context.Context, p *DetachENIParam, wp *WaiterParam) (*model.ENI, error) {
 	if err := validateWaitUntilParam(wp); err != nil {
 		return nil, err
 	}
+	ctx, cancel := withOpTimeout(ctx, wp)
+	defer cancel()
 
-	if eni, err := c.DetachENI(p); eni == nil || err != nil {
-		return nil, err
+	if eni, err := c.DetachENIWithContext(ctx, p); eni == nil || err != nil || p.DryRun {
+		return eni, err
 	}
 
 	c.logger.Printf("--> Detaching: %15s\n", p.InterfaceID)
 
 	// Wait until detach event completed or timeout
-	for i := 0; i < wp.MaxAttempts; i++ {
-		fmt.Fprint(c.logWriter, ".") // use fmt.Fprint because standard log package always newline
-
-		eni, err := c.DescribeENIByID(p.InterfaceID)
-		if err != nil {
-			return nil, err
+	var eni *model.ENI
+	start, forced := time.Now(), false
+	err := c.waitUntil(ctx, "detach", p.InterfaceID, wp, func(ctx context.Context) (bool, error) {
+		var err error
+		if eni, err = c.DescribeENIByIDWithContext(ctx, p.InterfaceID); err != nil {
+			return false, err
 		}
 		if eni.Status() == "available" {
-			c.logger.Println()
-			c.logger.Printf("--> Detached: %15s\n", eni.InterfaceID())
-			return eni, nil // detach completed
+			return true, nil
 		}
 
-		time.Sleep(time.Duration(wp.IntervalSec) * time.Second)
+		// Escalate to a forced detach if the owner doesn't release the ENI in time
+		if p.Force && !forced && time.Since(start) >= p.ForceGracePeriod {
+			c.logger.Println()
+			c.logger.Printf("--> Force detaching: %15s (not detached within %s)\n", p.InterfaceID, p.ForceGracePeriod)
+			if err := c.DetachENIByAttachmentIDWithContext(ctx, eni.AttachmentID(), true); err != nil {
+				return false, err
+			}
+			forced = true
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, fmt.Errorf("detach %s error: over %d polling attempts", p.InterfaceID, wp.MaxAttempts)
+	c.logger.Printf("--> Detached: %15s\n", eni.InterfaceID())
+	return eni, nil
 }
 
 func (c *ENIClient) GrabENI(p *GrabENIParam, wp *WaiterParam) (*model.ENI, error) {
-	eni, err := c.DescribeENIByID(p.InterfaceID)
-	if err != nil {
+	return c.GrabENIWithContext(context.Background(), p, wp)
+}
+
+func (c *ENIClient) GrabENIWithContext(ctx context.Context, p *GrabENIParam, wp *WaiterParam) (*model.ENI, error) {
+	if err := validateWaitUntilParam(wp); err != nil {
 		return nil, err
 	}
+	ctx, cancel := withOpTimeout(ctx, wp)
+	defer cancel()
+
+	eni, _, err := c.grabENI(ctx, p, wp)
+	return eni, err
+}
 
-	// Skip detaching if the target ENI has still not attached with the other instance
-	if eni.Status() == "in-use" && eni.AttachedStatus() == "attached" {
-		// Do nothing if the target ENI already attached with the target instance
+// grabENI is GrabENIWithContext, which also reports whether it has detached
+// or started to detach the ENI from its previous instance, even if the grab
+// has failed after.
+func (c *ENIClient) grabENI(ctx context.Context, p *GrabENIParam, wp *WaiterParam) (*model.ENI, bool, error) {
+	if p.Fence != "" {
+		if err := ValidateFenceMethod(p.Fence, p.QuarantineSecurityGroupID); err != nil {
+			return nil, false, err
+		}
+	}
+
+	eni, err := c.DescribeENIByIDWithContext(ctx, p.InterfaceID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Wait out an attachment or a detachment in progress, such as a concurrent grab by a peer
+	if eni.InTransition() {
+		if eni, err = c.waitENISettled(ctx, eni, wp); err != nil {
+			return nil, false, err
+		}
+	}
+
+	switch {
+	case eni.Status() == "in-use" && eni.AttachedStatus() == "attached":
+		// Do nothing if the target ENI already attached with the target
+		// instance, but take the lease that the instance is meant to hold
 		if eni.AttachedInstanceID() == p.InstanceID {
-			return nil, nil
+			if p.LeaseTTL > 0 {
+				if _, err := c.AcquireLeaseWithContext(ctx, p.leaseParam()); err != nil {
+					return nil, false, err
+				}
+			}
+			return nil, false, nil
+		}
+		if p.rollbackFrom != "" && eni.AttachedInstanceID() != p.rollbackFrom {
+			return nil, false, &AlreadyAttachedError{InterfaceID: p.InterfaceID, InstanceID: eni.AttachedInstanceID()}
+		}
+	case eni.Status() == "available":
+		// Skip detaching because the target ENI is not attached with any instance
+	default:
+		return nil, false, &InvalidStateError{
+			Op:             "grab",
+			InterfaceID:    p.InterfaceID,
+			Status:         eni.Status(),
+			AttachedStatus: eni.AttachedStatus(),
+		}
+	}
+
+	// Check the target and decide the device index before detaching, so that
+	// the ENI isn't taken away from the previous owner in vain
+	instance, err := c.describeInstanceWithType(ctx, p.InstanceID)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := checkENILimit(instance); err != nil {
+		return nil, false, err
+	}
+	deviceIndex := c.grabDeviceIndex(p, eni, instance)
+
+	if p.LeaseTTL > 0 {
+		if _, err := c.AcquireLeaseWithContext(ctx, p.leaseParam()); err != nil {
+			return nil, false, err
+		}
+	}
+
+	// Remember the previous owner to roll back to
+	prevInstanceID, prevDeviceIndex := eni.AttachedInstanceID(), eni.AttachedDeviceIndex()
+	detached := false
+
+	if p.Fence != "" && prevInstanceID != "" {
+		if err := c.fenceGrab(ctx, p, prevInstanceID, wp); err != nil {
+			c.releaseGrabLease(p)
+			return nil, false, err
 		}
+	}
+
+	if eni.Status() == "in-use" {
+		// A detach that has timed out may still complete
+		detached = true
+		if _, err := c.DetachENIWithWaiterWithContext(ctx, &DetachENIParam{
+			InterfaceID:      eni.InterfaceID(),
+			Force:            p.ForceDetach,
+			ForceGracePeriod: p.ForceDetachGracePeriod,
+		}, wp); err != nil {
+			c.releaseGrabLease(p)
+			return nil, detached, err
+		}
+	}
+
+	param := &AttachENIParam{
+		InterfaceID: p.InterfaceID,
+		InstanceID:  p.InstanceID,
+		DeviceIndex: deviceIndex,
+	}
+	if eni, err = c.AttachENIWithWaiterWithContext(ctx, param, wp); err != nil {
+		c.releaseGrabLease(p)
+		if p.Rollback && detached {
+			return nil, detached, c.rollbackGrab(p, prevInstanceID, int(prevDeviceIndex), wp, err)
+		}
+		return nil, detached, err
+	}
+
+	return eni, detached, nil
+}
+
+// fenceGrab fences the instance that the ENI is grabbed from. A failed fence
+// is only logged with SkipFenceOnError.
+func (c *ENIClient) fenceGrab(ctx context.Context, p *GrabENIParam, instanceID string, wp *WaiterParam) error {
+	err := c.FenceInstanceWithContext(ctx, &FenceParam{
+		InstanceID:                instanceID,
+		Method:                    p.Fence,
+		QuarantineSecurityGroupID: p.QuarantineSecurityGroupID,
+		ExcludeInterfaceIDs:       append([]string{p.InterfaceID}, p.fenceExclude...),
+	}, wp)
+	if err != nil && p.SkipFenceOnError && ctx.Err() == nil {
+		c.logger.Printf("--> Fencing skipped: %15s: %s\n", instanceID, err)
+		return nil
+	}
+	return err
+}
+
+func (p *GrabENIParam) leaseParam() *LeaseParam {
+	return &LeaseParam{
+		InterfaceID: p.InterfaceID,
+		Owner:       p.InstanceID,
+		TTL:         p.LeaseTTL,
+		SettleDelay: p.LeaseSettleDelay,
+		Steal:       p.StealLease,
+	}
+}
+
+// releaseGrabLease releases the lease taken for a grab that has failed, so
+// that other instances don't have to wait for its expiry. It doesn't inherit
+// the context of the grab, like rollbackGrab.
+func (c *ENIClient) releaseGrabLease(p *GrabENIParam) {
+	if p.LeaseTTL <= 0 {
+		return
+	}
+	if err := c.ReleaseLeaseWithContext(context.Background(), p.InterfaceID, p.InstanceID); err != nil {
+		c.logger.Printf("--> Releasing lease failed: %15s: %s\n", p.InterfaceID, err)
+	}
+}
+
+// grabDeviceIndex decides the device index to attach the ENI to the instance
+// with, resolving PreserveDeviceIndex and DeviceIndexAuto of p, and logs how
+// it was decided.
+func (c *ENIClient) grabDeviceIndex(p *GrabENIParam, eni *model.ENI, instance *model.Instance) int {
+	idx, notes := decideDeviceIndex(p, eni, instance)
+	for _, note := range notes {
+		c.logger.Printf("--> Device index: %s\n", note)
+	}
+	return idx
+}
 
-		if _, err := c.DetachENIWithWaiter(&DetachENIParam{InterfaceID: eni.InterfaceID()}, wp); err != nil {
-			return nil, err
+// decideDeviceIndex is grabDeviceIndex without logging. It returns the notes
+// on how the device index was decided along with it.
+func decideDeviceIndex(p *GrabENIParam, eni *model.ENI, instance *model.Instance) (int, []string) {
+	var notes []string
+	prev := eni.AttachedDeviceIndex()
+	if eni.AttachedInstanceID() == instance.InstanceID() {
+		return int(prev), notes
+	}
+
+	if p.PreserveDeviceIndex && eni.AttachedInstanceID() != "" {
+		if !instance.DeviceIndexInUse(prev) {
+			return int(prev), append(notes, fmt.Sprintf("%d (preserved)", prev))
 		}
+		notes = append(notes, fmt.Sprintf("%d is in use on %s, not preserved", prev, instance.InstanceID()))
 	}
 
-	param := AttachENIParam(*p)
-	if eni, err = c.AttachENIWithWaiter(&param, wp); err != nil {
+	if p.DeviceIndex == DeviceIndexAuto {
+		idx := int(instance.FreeDeviceIndex())
+		return idx, append(notes, fmt.Sprintf("%d (auto)", idx))
+	}
+	return p.DeviceIndex, notes
+}
+
+// waitENISettled polls the ENI until it is neither attaching nor detaching.
+func (c *ENIClient) waitENISettled(ctx context.Context, eni *model.ENI, wp *WaiterParam) (*model.ENI, error) {
+	id := eni.InterfaceID()
+	c.logger.Printf("--> Waiting: %15s (%s)\n", id, eni.AttachedStatus())
+
+	err := c.waitUntil(ctx, "wait", id, wp, func(ctx context.Context) (bool, error) {
+		var err error
+		if eni, err = c.DescribeENIByIDWithContext(ctx, id); err != nil {
+			return false, err
+		}
+		return !eni.InTransition(), nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
+	c.logger.Printf("--> Settled: %15s (%s)\n", id, eni.Status())
 	return eni, nil
 }
 
+// rollbackGrab moves the ENI back to the instance it was grabbed from. It
+// doesn't inherit the context of the grab so that a canceled grab is still
+// rolled back, bounded by wp.
+func (c *ENIClient) rollbackGrab(p *GrabENIParam, instanceID string, deviceIndex int, wp *WaiterParam, cause error) error {
+	c.logger.Printf("--> Rolling back: %15s to %s\n", p.InterfaceID, instanceID)
+
+	_, err := c.GrabENIWithContext(context.Background(), &GrabENIParam{
+		InterfaceID:            p.InterfaceID,
+		InstanceID:             instanceID,
+		DeviceIndex:            deviceIndex,
+		ForceDetach:            p.ForceDetach,
+		ForceDetachGracePeriod: p.ForceDetachGracePeriod,
+	}, wp)
+
+	return &RollbackError{
+		Err:         cause,
+		RollbackErr: err,
+		Target:      fmt.Sprintf("instance %s (device index %d)", instanceID, deviceIndex),
+	}
+}
+
 func (c *ENIClient) DescribeInstanceByID(instanceID string) (*model.Instance, error) {
+	return c.DescribeInstanceByIDWithContext(context.Background(), instanceID)
+}
+
+func (c *ENIClient) DescribeInstanceByIDWithContext(ctx context.Context, instanceID string) (*model.Instance, error) {
 	p := &ec2.DescribeInstancesInput{
 		InstanceIds: []*string{aws.String(instanceID)},
 	}
-	resp, err := c.svc.DescribeInstances(p)
+	resp, err := c.svc.DescribeInstancesWithContext(ctx, p)
 	if err != nil {
-		return nil, err
+		return nil, wrapContextError(ctx, "describe", instanceID, translateAPIError(err, "", instanceID))
 	}
 
 	if len(resp.Reservations) < 1 {
-		return nil, nil // Not found
+		return nil, &InstanceNotFoundError{InstanceID: instanceID}
 	}
 
 	instances := resp.Reservations[0].Instances
 
 	if len(instances) < 1 {
-		return nil, nil // Not found
+		return nil, &InstanceNotFoundError{InstanceID: instanceID}
 	}
 
 	return model.NewInstance(instances[0]), nil
 }
 
 func (c *ENIClient) DescribeInstancesByIDs(instanceIDs []string) ([]*model.Instance, error) {
+	return c.DescribeInstancesByIDsWithContext(context.Background(), instanceIDs)
+}
+
+// DescribeInstancesByIDsWithContext describes the instances in batches of
+// describeInstancesBatchSize concurrently. Instances that no longer exist are
+// left out of the result instead of failing the whole request.
+func (c *ENIClient) DescribeInstancesByIDsWithContext(ctx context.Context, instanceIDs []string) ([]*model.Instance, error) {
+	batches := chunkStrings(uniqueStrings(instanceIDs), describeInstancesBatchSize)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	results := make([][]*model.Instance, len(batches))
+	sem := make(chan struct{}, describeInstancesConcurrency)
+
+	for i, batch := range batches {
+		wg.Add(1)
+		go func(i int, batch []string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			instances, err := c.describeInstancesByFilter(ctx, batch)
+			if err != nil {
+				// Give up the other batches as soon as one fails
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			results[i] = instances
+		}(i, batch)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	instances := make([]*model.Instance, 0)
+	for _, r := range results {
+		instances = append(instances, r...)
+	}
+
+	return instances, nil
+}
+
+// describeInstancesByFilter uses the instance-id filter rather than
+// InstanceIds, which fails with InvalidInstanceID.NotFound if any of the
+// instances has gone away since its ID was found.
+func (c *ENIClient) describeInstancesByFilter(ctx context.Context, instanceIDs []string) ([]*model.Instance, error) {
 	p := &ec2.DescribeInstancesInput{
-		InstanceIds: aws.StringSlice(instanceIDs),
+		Filters: []*ec2.Filter{{
+			Name:   aws.String("instance-id"),
+			Values: aws.StringSlice(instanceIDs),
+		}},
 	}
-	resp, err := c.svc.DescribeInstances(p)
+
+	instances := make([]*model.Instance, 0)
+	err := c.svc.DescribeInstancesPagesWithContext(ctx, p, func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+		for _, r := range page.Reservations {
+			for _, i := range r.Instances {
+				instances = append(instances, model.NewInstance(i))
+			}
+		}
+		return true
+	})
 	if err != nil {
-		return nil, err
+		return nil, wrapContextError(ctx, "describe", "instances", err)
 	}
 
-	instances := make([]*model.Instance, 0)
+	return instances, nil
+}
 
-	for _, r := range resp.Reservations {
-		for _, i := range r.Instances {
-			instances = append(instances, model.NewInstance(i))
+func uniqueStrings(ss []string) []string {
+	seen := make(map[string]bool, len(ss))
+	uniq := make([]string, 0, len(ss))
+	for _, s := range ss {
+		if seen[s] {
+			continue
 		}
+		seen[s] = true
+		uniq = append(uniq, s)
 	}
+	return uniq
+}
 
-	return instances, nil
+func chunkStrings(ss []string, size int) [][]string {
+	chunks := make([][]string, 0, (len(ss)+size-1)/size)
+	for size < len(ss) {
+		ss, chunks = ss[size:], append(chunks, ss[:size])
+	}
+	if len(ss) > 0 {
+		chunks = append(chunks, ss)
+	}
+	return chunks
 }
@@ -1,12 +1,18 @@
 package aws
 
 import (
-	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -19,7 +25,7 @@ func newClient(svc *EC2API) *ENIClient {
 	return &ENIClient{
 		svc:       svc,
 		logger:    l,
-		logWriter: new(bytes.Buffer),
+		logWriter: io.Discard, // shared by concurrent grabs
 	}
 }
 
@@ -28,7 +34,7 @@ func TestDescribeENIByID(t *testing.T) {
 		mockEC2 := new(EC2API)
 		c := newClient(mockEC2)
 
-		mockEC2.On("DescribeNetworkInterfaces", &ec2.DescribeNetworkInterfacesInput{
+		mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, &ec2.DescribeNetworkInterfacesInput{
 			NetworkInterfaceIds: []*string{aws.String("eni-00000001")},
 		}).Return(&ec2.DescribeNetworkInterfacesOutput{
 			NetworkInterfaces: []*ec2.NetworkInterface{
@@ -41,7 +47,7 @@ func TestDescribeENIByID(t *testing.T) {
 			},
 		}, nil)
 
-		mockEC2.On("DescribeInstances", &ec2.DescribeInstancesInput{
+		mockEC2.On("DescribeInstancesWithContext", mock.Anything, &ec2.DescribeInstancesInput{
 			InstanceIds: []*string{aws.String("i-00000001")},
 		}).Return(&ec2.DescribeInstancesOutput{
 			Reservations: []*ec2.Reservation{
@@ -64,7 +70,7 @@ func TestDescribeENIByID(t *testing.T) {
 		mockEC2 := new(EC2API)
 		c := newClient(mockEC2)
 
-		mockEC2.On("DescribeNetworkInterfaces", &ec2.DescribeNetworkInterfacesInput{
+		mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, &ec2.DescribeNetworkInterfacesInput{
 			NetworkInterfaceIds: []*string{aws.String("eni-00000001")},
 		}).Return(&ec2.DescribeNetworkInterfacesOutput{
 			NetworkInterfaces: []*ec2.NetworkInterface{
@@ -75,7 +81,7 @@ func TestDescribeENIByID(t *testing.T) {
 			},
 		}, nil)
 
-		mockEC2.On("DescribeInstances", &ec2.DescribeInstancesInput{
+		mockEC2.On("DescribeInstancesWithContext", mock.Anything, &ec2.DescribeInstancesInput{
 			InstanceIds: []*string{aws.String("i-00000001")},
 		}).Return(&ec2.DescribeInstancesOutput{
 			Reservations: []*ec2.Reservation{
@@ -94,11 +100,32 @@ func TestDescribeENIByID(t *testing.T) {
 	}
 }
 
+// Make the mocked Describe*Pages call fn with each page in turn
+func returnPages(pages ...interface{}) func(mock.Arguments) {
+	return func(args mock.Arguments) {
+		for i, page := range pages {
+			last := i == len(pages)-1
+			var cont bool
+			switch fn := args.Get(2).(type) {
+			case func(*ec2.DescribeNetworkInterfacesOutput, bool) bool:
+				cont = fn(page.(*ec2.DescribeNetworkInterfacesOutput), last)
+			case func(*ec2.DescribeInstancesOutput, bool) bool:
+				cont = fn(page.(*ec2.DescribeInstancesOutput), last)
+			case func(*ec2.DescribeInstanceTypesOutput, bool) bool:
+				cont = fn(page.(*ec2.DescribeInstanceTypesOutput), last)
+			}
+			if !cont {
+				return
+			}
+		}
+	}
+}
+
 func TestDescribeENIs(t *testing.T) {
 	mockEC2 := new(EC2API)
 	c := newClient(mockEC2)
 
-	mockEC2.On("DescribeNetworkInterfaces", mock.Anything).Return(
+	mockEC2.On("DescribeNetworkInterfacesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).Run(returnPages(
 		&ec2.DescribeNetworkInterfacesOutput{
 			NetworkInterfaces: []*ec2.NetworkInterface{
 				{
@@ -107,40 +134,57 @@ func TestDescribeENIs(t *testing.T) {
 						InstanceId: aws.String("i-00000001"),
 					},
 				},
+			},
+		},
+		&ec2.DescribeNetworkInterfacesOutput{
+			NetworkInterfaces: []*ec2.NetworkInterface{
 				{
 					NetworkInterfaceId: aws.String("eni-00000002"),
 					Attachment:         nil,
 				},
+				{
+					NetworkInterfaceId: aws.String("eni-00000003"),
+					Attachment: &ec2.NetworkInterfaceAttachment{
+						InstanceId: aws.String("i-00000003"), // terminated in the meantime
+					},
+				},
 			},
-		}, nil)
+		},
+	)).Return(nil)
 
-	mockEC2.On("DescribeInstances", &ec2.DescribeInstancesInput{
-		InstanceIds: []*string{aws.String("i-00000001")},
-	}).Return(&ec2.DescribeInstancesOutput{
-		Reservations: []*ec2.Reservation{
-			{
-				Instances: []*ec2.Instance{{
-					InstanceId: aws.String("i-00000001"),
-				}},
+	mockEC2.On("DescribeInstancesPagesWithContext", mock.Anything, &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{{
+			Name:   aws.String("instance-id"),
+			Values: aws.StringSlice([]string{"i-00000001", "i-00000003"}),
+		}},
+	}, mock.Anything).Run(returnPages(
+		&ec2.DescribeInstancesOutput{
+			Reservations: []*ec2.Reservation{
+				{
+					Instances: []*ec2.Instance{{
+						InstanceId: aws.String("i-00000001"),
+					}},
+				},
 			},
 		},
-	}, nil)
+	)).Return(nil)
 
-	enis, err := c.DescribeENIs()
+	enis, err := c.DescribeENIs(nil)
 
 	assert.NoError(t, err)
-	assert.Equal(t, 2, len(enis))
+	assert.Equal(t, 3, len(enis))
 	if assert.NotNil(t, enis[0].AttachedInstance()) {
 		assert.Equal(t, "i-00000001", enis[0].AttachedInstanceID())
 	}
 	assert.Nil(t, enis[1].AttachedInstance())
+	assert.Nil(t, enis[2].AttachedInstance())
 }
 
 func TestDescribeInstanceByID(t *testing.T) {
 	mockEC2 := new(EC2API)
 	c := newClient(mockEC2)
 
-	mockEC2.On("DescribeInstances", &ec2.DescribeInstancesInput{
+	mockEC2.On("DescribeInstancesWithContext", mock.Anything, &ec2.DescribeInstancesInput{
 		InstanceIds: []*string{aws.String("i-00000001")},
 	}).Return(&ec2.DescribeInstancesOutput{
 		Reservations: []*ec2.Reservation{
@@ -162,19 +206,24 @@ func TestDescribeInstancesByID(t *testing.T) {
 	mockEC2 := new(EC2API)
 	c := newClient(mockEC2)
 
-	mockEC2.On("DescribeInstances", &ec2.DescribeInstancesInput{
-		InstanceIds: []*string{aws.String("i-00000001")},
-	}).Return(&ec2.DescribeInstancesOutput{
-		Reservations: []*ec2.Reservation{
-			{
-				Instances: []*ec2.Instance{{
-					InstanceId: aws.String("i-00000001"),
-				}},
+	mockEC2.On("DescribeInstancesPagesWithContext", mock.Anything, &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{{
+			Name:   aws.String("instance-id"),
+			Values: []*string{aws.String("i-00000001")},
+		}},
+	}, mock.Anything).Run(returnPages(
+		&ec2.DescribeInstancesOutput{
+			Reservations: []*ec2.Reservation{
+				{
+					Instances: []*ec2.Instance{{
+						InstanceId: aws.String("i-00000001"),
+					}},
+				},
 			},
 		},
-	}, nil)
+	)).Return(nil)
 
-	instances, err := c.DescribeInstancesByIDs([]string{"i-00000001"})
+	instances, err := c.DescribeInstancesByIDs([]string{"i-00000001", "i-00000001"})
 
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(instances))
@@ -183,16 +232,687 @@ func TestDescribeInstancesByID(t *testing.T) {
 	mockEC2 = new(EC2API)
 	c = newClient(mockEC2)
 
-	mockEC2.On("DescribeInstances", &ec2.DescribeInstancesInput{
-		InstanceIds: []*string{aws.String("i-00000000")},
-	}).Return(&ec2.DescribeInstancesOutput{
-		Reservations: []*ec2.Reservation{
-			{Instances: nil},
+	mockEC2.On("DescribeInstancesPagesWithContext", mock.Anything, &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{{
+			Name:   aws.String("instance-id"),
+			Values: []*string{aws.String("i-00000000")},
+		}},
+	}, mock.Anything).Run(returnPages(
+		&ec2.DescribeInstancesOutput{
+			Reservations: []*ec2.Reservation{
+				{Instances: nil},
+			},
 		},
-	}, nil)
+	)).Return(nil)
 
 	instances, err = c.DescribeInstancesByIDs([]string{"i-00000000"})
 
 	assert.NoError(t, err)
 	assert.Equal(t, 0, len(instances))
+
+	// No request for no instance
+	mockEC2 = new(EC2API)
+	c = newClient(mockEC2)
+
+	instances, err = c.DescribeInstancesByIDs(nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(instances))
+	mockEC2.AssertNotCalled(t, "DescribeInstancesPagesWithContext", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDescribeInstancesByIDBatches(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	ids := make([]string, 0, 250)
+	for i := 0; i < 250; i++ {
+		ids = append(ids, fmt.Sprintf("i-%08d", i))
+	}
+
+	mockEC2.On("DescribeInstancesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		input := args.Get(1).(*ec2.DescribeInstancesInput)
+		instances := make([]*ec2.Instance, 0)
+		for _, id := range input.Filters[0].Values {
+			instances = append(instances, &ec2.Instance{InstanceId: id})
+		}
+		returnPages(&ec2.DescribeInstancesOutput{
+			Reservations: []*ec2.Reservation{{Instances: instances}},
+		})(args)
+	}).Return(nil)
+
+	instances, err := c.DescribeInstancesByIDs(ids)
+
+	assert.NoError(t, err)
+	if assert.Equal(t, 250, len(instances)) {
+		for i, instance := range instances {
+			assert.Equal(t, ids[i], instance.InstanceID())
+		}
+	}
+	mockEC2.AssertNumberOfCalls(t, "DescribeInstancesPagesWithContext", 3)
+
+	// One failed batch fails the whole
+	mockEC2 = new(EC2API)
+	c = newClient(mockEC2)
+
+	mockEC2.On("DescribeInstancesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("RequestLimitExceeded"))
+
+	instances, err = c.DescribeInstancesByIDs(ids)
+
+	assert.Nil(t, instances)
+	assert.EqualError(t, err, "RequestLimitExceeded")
+}
+
+func TestAttachENIWithWaiterWithContextCanceled(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, &ec2.DescribeNetworkInterfacesInput{
+		NetworkInterfaceIds: []*string{aws.String("eni-00000001")},
+	}).Return(&ec2.DescribeNetworkInterfacesOutput{
+		NetworkInterfaces: []*ec2.NetworkInterface{
+			{
+				NetworkInterfaceId: aws.String("eni-00000001"),
+				Status:             aws.String("available"),
+			},
+		},
+	}, nil)
+
+	mockEC2.On("DescribeInstancesWithContext", mock.Anything, mock.Anything).Return(
+		describeInstanceOutput("i-00000001", 0), nil)
+	mockEC2.On("AttachNetworkInterfaceWithContext", mock.Anything, mock.Anything).Return(
+		&ec2.AttachNetworkInterfaceOutput{}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	eni, err := c.AttachENIWithWaiterWithContext(ctx, &AttachENIParam{
+		InterfaceID: "eni-00000001",
+		InstanceID:  "i-00000001",
+		DeviceIndex: 1,
+	}, &WaiterParam{MaxAttempts: 10, IntervalSec: 1})
+
+	assert.Nil(t, eni)
+	var canceled *CanceledError
+	if assert.True(t, errors.As(err, &canceled)) {
+		assert.Equal(t, "attach", canceled.Op)
+		assert.Equal(t, "eni-00000001", canceled.ID)
+	}
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestDetachENIWithWaiterWithContextCanceled(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, &ec2.DescribeNetworkInterfacesInput{
+		NetworkInterfaceIds: []*string{aws.String("eni-00000001")},
+	}).Return(&ec2.DescribeNetworkInterfacesOutput{
+		NetworkInterfaces: []*ec2.NetworkInterface{
+			{
+				NetworkInterfaceId: aws.String("eni-00000001"),
+				Status:             aws.String("in-use"),
+				Attachment: &ec2.NetworkInterfaceAttachment{
+					AttachmentId: aws.String("eni-attach-00000001"),
+				},
+			},
+		},
+	}, nil)
+
+	mockEC2.On("DetachNetworkInterfaceWithContext", mock.Anything, &ec2.DetachNetworkInterfaceInput{
+		AttachmentId: aws.String("eni-attach-00000001"),
+		Force:        aws.Bool(false),
+	}).Return(&ec2.DetachNetworkInterfaceOutput{}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	eni, err := c.DetachENIWithWaiterWithContext(ctx, &DetachENIParam{
+		InterfaceID: "eni-00000001",
+	}, &WaiterParam{MaxAttempts: 10, IntervalSec: 1})
+
+	assert.Nil(t, eni)
+	var canceled *CanceledError
+	assert.True(t, errors.As(err, &canceled))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestDetachENIWithWaiterForce(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	input := &ec2.DescribeNetworkInterfacesInput{
+		NetworkInterfaceIds: []*string{aws.String("eni-00000001")},
+	}
+	inUse := func(attachedStatus string) *ec2.DescribeNetworkInterfacesOutput {
+		return &ec2.DescribeNetworkInterfacesOutput{
+			NetworkInterfaces: []*ec2.NetworkInterface{
+				{
+					NetworkInterfaceId: aws.String("eni-00000001"),
+					Status:             aws.String("in-use"),
+					Attachment: &ec2.NetworkInterfaceAttachment{
+						AttachmentId: aws.String("eni-attach-00000001"),
+						Status:       aws.String(attachedStatus),
+					},
+				},
+			},
+		}
+	}
+	available := &ec2.DescribeNetworkInterfacesOutput{
+		NetworkInterfaces: []*ec2.NetworkInterface{
+			{
+				NetworkInterfaceId: aws.String("eni-00000001"),
+				Status:             aws.String("available"),
+			},
+		},
+	}
+
+	// DetachENI, then two polls without progress, then detached
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, input).Return(inUse("attached"), nil).Once()
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, input).Return(inUse("detaching"), nil).Twice()
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, input).Return(available, nil).Once()
+
+	mockEC2.On("DetachNetworkInterfaceWithContext", mock.Anything, &ec2.DetachNetworkInterfaceInput{
+		AttachmentId: aws.String("eni-attach-00000001"),
+		Force:        aws.Bool(false),
+	}).Return(&ec2.DetachNetworkInterfaceOutput{}, nil).Once()
+	mockEC2.On("DetachNetworkInterfaceWithContext", mock.Anything, &ec2.DetachNetworkInterfaceInput{
+		AttachmentId: aws.String("eni-attach-00000001"),
+		Force:        aws.Bool(true),
+	}).Return(&ec2.DetachNetworkInterfaceOutput{}, nil).Once()
+
+	eni, err := c.DetachENIWithWaiterWithContext(context.Background(), &DetachENIParam{
+		InterfaceID:      "eni-00000001",
+		Force:            true,
+		ForceGracePeriod: 0,
+	}, &WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "available", eni.Status())
+	mockEC2.AssertExpectations(t)
+}
+
+func TestGrabENIWithRollback(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	input := &ec2.DescribeNetworkInterfacesInput{
+		NetworkInterfaceIds: []*string{aws.String("eni-00000001")},
+	}
+	attachedTo := func(instanceID string, deviceIndex int64) *ec2.DescribeNetworkInterfacesOutput {
+		return &ec2.DescribeNetworkInterfacesOutput{
+			NetworkInterfaces: []*ec2.NetworkInterface{
+				{
+					NetworkInterfaceId: aws.String("eni-00000001"),
+					Status:             aws.String("in-use"),
+					Attachment: &ec2.NetworkInterfaceAttachment{
+						AttachmentId: aws.String("eni-attach-00000001"),
+						InstanceId:   aws.String(instanceID),
+						DeviceIndex:  aws.Int64(deviceIndex),
+						Status:       aws.String("attached"),
+					},
+				},
+			},
+		}
+	}
+	available := &ec2.DescribeNetworkInterfacesOutput{
+		NetworkInterfaces: []*ec2.NetworkInterface{
+			{
+				NetworkInterfaceId: aws.String("eni-00000001"),
+				Status:             aws.String("available"),
+			},
+		},
+	}
+
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, input).Return(attachedTo("i-00000001", 2), nil).Twice()
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, input).Return(available, nil).Times(4)
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, input).Return(attachedTo("i-00000001", 2), nil).Once()
+
+	for _, id := range []string{"i-00000001", "i-00000002"} {
+		mockEC2.On("DescribeInstancesWithContext", mock.Anything, &ec2.DescribeInstancesInput{
+			InstanceIds: []*string{aws.String(id)},
+		}).Return(describeInstanceOutput(id, 0), nil)
+	}
+
+	mockEC2.On("DetachNetworkInterfaceWithContext", mock.Anything, mock.Anything).Return(&ec2.DetachNetworkInterfaceOutput{}, nil).Once()
+
+	attachErr := errors.New("AttachmentLimitExceeded")
+	mockEC2.On("AttachNetworkInterfaceWithContext", mock.Anything, &ec2.AttachNetworkInterfaceInput{
+		NetworkInterfaceId: aws.String("eni-00000001"),
+		InstanceId:         aws.String("i-00000002"),
+		DeviceIndex:        aws.Int64(1),
+	}).Return(nil, attachErr).Once()
+	mockEC2.On("AttachNetworkInterfaceWithContext", mock.Anything, &ec2.AttachNetworkInterfaceInput{
+		NetworkInterfaceId: aws.String("eni-00000001"),
+		InstanceId:         aws.String("i-00000001"),
+		DeviceIndex:        aws.Int64(2),
+	}).Return(&ec2.AttachNetworkInterfaceOutput{}, nil).Once()
+
+	eni, err := c.GrabENIWithContext(context.Background(), &GrabENIParam{
+		InterfaceID: "eni-00000001",
+		InstanceID:  "i-00000002",
+		DeviceIndex: 1,
+		Rollback:    true,
+	}, &WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+	assert.Nil(t, eni)
+	var rollbackErr *RollbackError
+	if assert.True(t, errors.As(err, &rollbackErr)) {
+		assert.True(t, rollbackErr.RolledBack())
+		assert.Equal(t, "instance i-00000001 (device index 2)", rollbackErr.Target)
+	}
+	assert.True(t, errors.Is(err, attachErr))
+	mockEC2.AssertExpectations(t)
+}
+
+// Build a DescribeNetworkInterfaces response for eni-00000001
+func describeENIOutput(status, attachedStatus, instanceID string) *ec2.DescribeNetworkInterfacesOutput {
+	return describeENIOutputOf("eni-00000001", status, attachedStatus, instanceID, 1)
+}
+
+// Build a DescribeNetworkInterfaces response for the ENI, attached at
+// deviceIndex of instanceID if attachedStatus is given
+func describeENIOutputOf(interfaceID, status, attachedStatus, instanceID string, deviceIndex int64) *ec2.DescribeNetworkInterfacesOutput {
+	iface := &ec2.NetworkInterface{
+		NetworkInterfaceId: aws.String(interfaceID),
+		Status:             aws.String(status),
+	}
+	if attachedStatus != "" {
+		iface.Attachment = &ec2.NetworkInterfaceAttachment{
+			AttachmentId: aws.String("eni-attach-" + strings.TrimPrefix(interfaceID, "eni-")),
+			InstanceId:   aws.String(instanceID),
+			DeviceIndex:  aws.Int64(deviceIndex),
+			Status:       aws.String(attachedStatus),
+		}
+	}
+	return &ec2.DescribeNetworkInterfacesOutput{
+		NetworkInterfaces: []*ec2.NetworkInterface{iface},
+	}
+}
+
+// Build a DescribeNetworkInterfaces response for the ENI attached at
+// deviceIndex of instanceID, or available if instanceID is empty
+func describeENIAtOutput(interfaceID, instanceID string, deviceIndex int64) *ec2.DescribeNetworkInterfacesOutput {
+	if instanceID == "" {
+		return describeENIOutputOf(interfaceID, "available", "", "", 0)
+	}
+	return describeENIOutputOf(interfaceID, "in-use", "attached", instanceID, deviceIndex)
+}
+
+func mockDescribeENISequence(m *EC2API, interfaceID string, outputs ...*ec2.DescribeNetworkInterfacesOutput) {
+	input := &ec2.DescribeNetworkInterfacesInput{
+		NetworkInterfaceIds: []*string{aws.String(interfaceID)},
+	}
+	for _, o := range outputs {
+		m.On("DescribeNetworkInterfacesWithContext", mock.Anything, input).Return(o, nil).Once()
+	}
+}
+
+func mockAttach(m *EC2API, interfaceID, instanceID string, deviceIndex int64, err error) {
+	var out *ec2.AttachNetworkInterfaceOutput
+	if err == nil {
+		out = &ec2.AttachNetworkInterfaceOutput{}
+	}
+	m.On("AttachNetworkInterfaceWithContext", mock.Anything, &ec2.AttachNetworkInterfaceInput{
+		NetworkInterfaceId: aws.String(interfaceID),
+		InstanceId:         aws.String(instanceID),
+		DeviceIndex:        aws.Int64(deviceIndex),
+	}).Return(out, err).Once()
+}
+
+func TestGrabENITransitions(t *testing.T) {
+	type describe struct {
+		status         string
+		attachedStatus string
+		instanceID     string
+	}
+	tests := []struct {
+		name      string
+		describes []describe
+		detach    bool
+		attach    bool
+		attached  bool
+	}{
+		{
+			name: "available",
+			describes: []describe{
+				{"available", "", ""},
+				{"available", "", ""},
+				{"in-use", "attached", "i-00000002"},
+			},
+			attach:   true,
+			attached: true,
+		},
+		{
+			name: "attached to the other instance",
+			describes: []describe{
+				{"in-use", "attached", "i-00000001"},
+				{"in-use", "attached", "i-00000001"},
+				{"available", "", ""},
+				{"available", "", ""},
+				{"in-use", "attached", "i-00000002"},
+			},
+			detach:   true,
+			attach:   true,
+			attached: true,
+		},
+		{
+			name: "already attached to the target instance",
+			describes: []describe{
+				{"in-use", "attached", "i-00000002"},
+			},
+		},
+		{
+			name: "detaching from the other instance",
+			describes: []describe{
+				{"in-use", "detaching", "i-00000001"},
+				{"in-use", "detaching", "i-00000001"},
+				{"available", "", ""},
+				{"available", "", ""},
+				{"in-use", "attached", "i-00000002"},
+			},
+			attach:   true,
+			attached: true,
+		},
+		{
+			name: "attaching to the other instance",
+			describes: []describe{
+				{"in-use", "attaching", "i-00000001"},
+				{"in-use", "attached", "i-00000001"},
+				{"in-use", "attached", "i-00000001"},
+				{"available", "", ""},
+				{"available", "", ""},
+				{"in-use", "attached", "i-00000002"},
+			},
+			detach:   true,
+			attach:   true,
+			attached: true,
+		},
+		{
+			name: "attaching to the target instance",
+			describes: []describe{
+				{"in-use", "attaching", "i-00000002"},
+				{"in-use", "attached", "i-00000002"},
+			},
+		},
+		{
+			name: "detached but still in use",
+			describes: []describe{
+				{"in-use", "detached", "i-00000001"},
+				{"available", "", ""},
+				{"available", "", ""},
+				{"in-use", "attached", "i-00000002"},
+			},
+			attach:   true,
+			attached: true,
+		},
+	}
+
+	for _, tt := range tests {
+		mockEC2 := new(EC2API)
+		c := newClient(mockEC2)
+
+		input := &ec2.DescribeNetworkInterfacesInput{
+			NetworkInterfaceIds: []*string{aws.String("eni-00000001")},
+		}
+		instanceIDs := []string{"i-00000002"}
+		for _, d := range tt.describes {
+			mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, input).Return(
+				describeENIOutput(d.status, d.attachedStatus, d.instanceID), nil).Once()
+			if d.instanceID != "" {
+				instanceIDs = append(instanceIDs, d.instanceID)
+			}
+		}
+		for _, id := range uniqueStrings(instanceIDs) {
+			mockEC2.On("DescribeInstancesWithContext", mock.Anything, &ec2.DescribeInstancesInput{
+				InstanceIds: []*string{aws.String(id)},
+			}).Return(describeInstanceOutput(id, 0), nil)
+		}
+		if tt.detach {
+			mockEC2.On("DetachNetworkInterfaceWithContext", mock.Anything, &ec2.DetachNetworkInterfaceInput{
+				AttachmentId: aws.String("eni-attach-00000001"),
+				Force:        aws.Bool(false),
+			}).Return(&ec2.DetachNetworkInterfaceOutput{}, nil).Once()
+		}
+		if tt.attach {
+			mockEC2.On("AttachNetworkInterfaceWithContext", mock.Anything, &ec2.AttachNetworkInterfaceInput{
+				NetworkInterfaceId: aws.String("eni-00000001"),
+				InstanceId:         aws.String("i-00000002"),
+				DeviceIndex:        aws.Int64(1),
+			}).Return(&ec2.AttachNetworkInterfaceOutput{}, nil).Once()
+		}
+
+		eni, err := c.GrabENIWithContext(context.Background(), &GrabENIParam{
+			InterfaceID: "eni-00000001",
+			InstanceID:  "i-00000002",
+			DeviceIndex: 1,
+		}, &WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+		assert.NoError(t, err, tt.name)
+		if tt.attached {
+			if assert.NotNil(t, eni, tt.name) {
+				assert.Equal(t, "i-00000002", eni.AttachedInstanceID(), tt.name)
+			}
+		} else {
+			assert.Nil(t, eni, tt.name)
+		}
+		mockEC2.AssertExpectations(t)
+	}
+}
+
+func TestGrabENIUnexpectedStatus(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, mock.Anything).Return(
+		describeENIOutput("associated", "", ""), nil).Once()
+
+	eni, err := c.GrabENIWithContext(context.Background(), &GrabENIParam{
+		InterfaceID: "eni-00000001",
+		InstanceID:  "i-00000002",
+		DeviceIndex: 1,
+	}, &WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+	assert.Nil(t, eni)
+	assert.EqualError(t, err, `grab eni-00000001 error: unexpected status "associated" (attachment "")`)
+	var invalidState *InvalidStateError
+	assert.True(t, errors.As(err, &invalidState))
+}
+
+func TestDescribeENIByIDNotFound(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, &ec2.DescribeNetworkInterfacesInput{
+		NetworkInterfaceIds: []*string{aws.String("eni-00000001")},
+	}).Return(nil, awserr.New("InvalidNetworkInterfaceID.NotFound", "The networkInterface ID 'eni-00000001' does not exist", nil))
+
+	eni, err := c.DescribeENIByID("eni-00000001")
+
+	assert.Nil(t, eni)
+	var notFound *ENINotFoundError
+	if assert.True(t, errors.As(err, &notFound)) {
+		assert.Equal(t, "eni-00000001", notFound.InterfaceID)
+	}
+
+	mockEC2 = new(EC2API)
+	c = newClient(mockEC2)
+
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, mock.Anything).Return(
+		&ec2.DescribeNetworkInterfacesOutput{}, nil)
+
+	eni, err = c.DescribeENIByID("eni-00000001")
+
+	assert.Nil(t, eni)
+	assert.EqualError(t, err, "no such ENI eni-00000001")
+}
+
+func TestDescribeInstanceByIDNotFound(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockEC2.On("DescribeInstancesWithContext", mock.Anything, &ec2.DescribeInstancesInput{
+		InstanceIds: []*string{aws.String("i-00000001")},
+	}).Return(nil, awserr.New("InvalidInstanceID.NotFound", "The instance ID 'i-00000001' does not exist", nil))
+
+	i, err := c.DescribeInstanceByID("i-00000001")
+
+	assert.Nil(t, i)
+	var notFound *InstanceNotFoundError
+	if assert.True(t, errors.As(err, &notFound)) {
+		assert.Equal(t, "i-00000001", notFound.InstanceID)
+	}
+}
+
+func TestAttachENIAlreadyAttached(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, mock.Anything).Return(
+		describeENIOutput("in-use", "attached", "i-00000001"), nil)
+	mockEC2.On("DescribeInstancesWithContext", mock.Anything, mock.Anything).Return(&ec2.DescribeInstancesOutput{
+		Reservations: []*ec2.Reservation{
+			{
+				Instances: []*ec2.Instance{{
+					InstanceId: aws.String("i-00000001"),
+				}},
+			},
+		},
+	}, nil)
+
+	eni, err := c.AttachENI(&AttachENIParam{
+		InterfaceID: "eni-00000001",
+		InstanceID:  "i-00000002",
+		DeviceIndex: 1,
+	})
+
+	assert.Nil(t, eni)
+	var alreadyAttached *AlreadyAttachedError
+	if assert.True(t, errors.As(err, &alreadyAttached)) {
+		assert.Equal(t, "i-00000001", alreadyAttached.InstanceID)
+	}
+	mockEC2.AssertNotCalled(t, "AttachNetworkInterfaceWithContext", mock.Anything, mock.Anything)
+}
+
+// Build a DescribeInstances response for an instance with ENIs at indexes
+func describeInstanceOutput(instanceID string, indexes ...int64) *ec2.DescribeInstancesOutput {
+	ifaces := make([]*ec2.InstanceNetworkInterface, 0, len(indexes))
+	for _, idx := range indexes {
+		ifaces = append(ifaces, &ec2.InstanceNetworkInterface{
+			Attachment: &ec2.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int64(idx)},
+		})
+	}
+	return &ec2.DescribeInstancesOutput{
+		Reservations: []*ec2.Reservation{{
+			Instances: []*ec2.Instance{{
+				InstanceId:        aws.String(instanceID),
+				NetworkInterfaces: ifaces,
+			}},
+		}},
+	}
+}
+
+// mockDescribeInstance returns the call so that it can be limited by Once
+// for a sequence of responses.
+func mockDescribeInstance(m *EC2API, out *ec2.DescribeInstancesOutput) *mock.Call {
+	return m.On("DescribeInstancesWithContext", mock.Anything, &ec2.DescribeInstancesInput{
+		InstanceIds: []*string{out.Reservations[0].Instances[0].InstanceId},
+	}).Return(out, nil)
+}
+
+func TestAttachENIAutoDeviceIndex(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, mock.Anything).Return(
+		describeENIOutput("available", "", ""), nil)
+	mockEC2.On("DescribeInstancesWithContext", mock.Anything, &ec2.DescribeInstancesInput{
+		InstanceIds: []*string{aws.String("i-00000002")},
+	}).Return(describeInstanceOutput("i-00000002", 0, 1, 3), nil)
+	mockEC2.On("AttachNetworkInterfaceWithContext", mock.Anything, &ec2.AttachNetworkInterfaceInput{
+		NetworkInterfaceId: aws.String("eni-00000001"),
+		InstanceId:         aws.String("i-00000002"),
+		DeviceIndex:        aws.Int64(2),
+	}).Return(&ec2.AttachNetworkInterfaceOutput{}, nil).Once()
+
+	param := &AttachENIParam{
+		InterfaceID: "eni-00000001",
+		InstanceID:  "i-00000002",
+		DeviceIndex: DeviceIndexAuto,
+	}
+	_, err := c.AttachENI(param)
+
+	assert.NoError(t, err)
+	assert.Equal(t, DeviceIndexAuto, param.DeviceIndex, "param should be left untouched")
+	mockEC2.AssertExpectations(t)
+}
+
+func TestGrabENIDeviceIndex(t *testing.T) {
+	tests := []struct {
+		name     string
+		param    GrabENIParam
+		indexes  []int64
+		expected int64
+	}{
+		{
+			name:     "preserved",
+			param:    GrabENIParam{DeviceIndex: 3, PreserveDeviceIndex: true},
+			indexes:  []int64{0},
+			expected: 1,
+		},
+		{
+			name:     "previous index in use",
+			param:    GrabENIParam{DeviceIndex: 3, PreserveDeviceIndex: true},
+			indexes:  []int64{0, 1},
+			expected: 3,
+		},
+		{
+			name:     "previous index in use and auto",
+			param:    GrabENIParam{DeviceIndex: DeviceIndexAuto, PreserveDeviceIndex: true},
+			indexes:  []int64{0, 1, 2},
+			expected: 3,
+		},
+		{
+			name:     "auto",
+			param:    GrabENIParam{DeviceIndex: DeviceIndexAuto},
+			indexes:  []int64{0, 2},
+			expected: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		mockEC2 := new(EC2API)
+		c := newClient(mockEC2)
+
+		for _, d := range []*ec2.DescribeNetworkInterfacesOutput{
+			describeENIOutput("in-use", "attached", "i-00000001"),
+			describeENIOutput("in-use", "attached", "i-00000001"),
+			describeENIOutput("available", "", ""),
+			describeENIOutput("available", "", ""),
+			describeENIOutput("in-use", "attached", "i-00000002"),
+		} {
+			mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, mock.Anything).Return(d, nil).Once()
+		}
+		mockEC2.On("DescribeInstancesWithContext", mock.Anything, &ec2.DescribeInstancesInput{
+			InstanceIds: []*string{aws.String("i-00000001")},
+		}).Return(describeInstanceOutput("i-00000001", 0, 1), nil)
+		mockEC2.On("DescribeInstancesWithContext", mock.Anything, &ec2.DescribeInstancesInput{
+			InstanceIds: []*string{aws.String("i-00000002")},
+		}).Return(describeInstanceOutput("i-00000002", tt.indexes...), nil)
+		mockEC2.On("DetachNetworkInterfaceWithContext", mock.Anything, mock.Anything).Return(
+			&ec2.DetachNetworkInterfaceOutput{}, nil).Once()
+		mockEC2.On("AttachNetworkInterfaceWithContext", mock.Anything, &ec2.AttachNetworkInterfaceInput{
+			NetworkInterfaceId: aws.String("eni-00000001"),
+			InstanceId:         aws.String("i-00000002"),
+			DeviceIndex:        aws.Int64(tt.expected),
+		}).Return(&ec2.AttachNetworkInterfaceOutput{}, nil).Once()
+
+		p := tt.param
+		p.InterfaceID, p.InstanceID = "eni-00000001", "i-00000002"
+		_, err := c.GrabENIWithContext(context.Background(), &p,
+			&WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+		assert.NoError(t, err, tt.name)
+		mockEC2.AssertExpectations(t)
+	}
 }
@@ -0,0 +1,253 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// ENINotFoundError is returned when the ENI doesn't exist.
+type ENINotFoundError struct {
+	InterfaceID string
+}
+
+func (e *ENINotFoundError) Error() string {
+	return fmt.Sprintf("no such ENI %s", e.InterfaceID)
+}
+
+// InstanceNotFoundError is returned when the instance doesn't exist.
+type InstanceNotFoundError struct {
+	InstanceID string
+}
+
+func (e *InstanceNotFoundError) Error() string {
+	return fmt.Sprintf("no such instance %s", e.InstanceID)
+}
+
+// AddressNotFoundError is returned when the Elastic IP doesn't exist.
+type AddressNotFoundError struct {
+	Address string
+}
+
+func (e *AddressNotFoundError) Error() string {
+	return fmt.Sprintf("no such Elastic IP %s", e.Address)
+}
+
+// RouteTableNotFoundError is returned when the route table doesn't exist.
+type RouteTableNotFoundError struct {
+	RouteTableID string
+}
+
+func (e *RouteTableNotFoundError) Error() string {
+	return fmt.Sprintf("no such route table %s", e.RouteTableID)
+}
+
+// AmbiguousSelectorError is returned when a selector matches more than one
+// resource.
+type AmbiguousSelectorError struct {
+	Selector string
+	// Kind is the plural name of the resources, such as "ENIs".
+	Kind string
+	IDs  []string
+}
+
+func (e *AmbiguousSelectorError) Error() string {
+	return fmt.Sprintf("%s matches %d %s: %s", e.Selector, len(e.IDs), e.Kind, strings.Join(e.IDs, ", "))
+}
+
+// AlreadyAttachedError is returned when the ENI is attached to an instance
+// other than the requested one.
+type AlreadyAttachedError struct {
+	InterfaceID string
+	InstanceID  string
+}
+
+func (e *AlreadyAttachedError) Error() string {
+	return fmt.Sprintf("%s is already attached to instance %s", e.InterfaceID, e.InstanceID)
+}
+
+// LeaseHeldError is returned when another instance holds the lease of the
+// ENI, or has won the race to take it.
+type LeaseHeldError struct {
+	InterfaceID string
+	Owner       string
+	Expiry      time.Time
+}
+
+func (e *LeaseHeldError) Error() string {
+	return fmt.Sprintf("%s is leased by instance %s until %s", e.InterfaceID, e.Owner, e.Expiry.Format(time.RFC3339))
+}
+
+// LeaseLostError is returned when renewing a lease that another instance
+// has taken.
+type LeaseLostError struct {
+	InterfaceID string
+	// Owner is the instance holding the lease now.
+	Owner string
+}
+
+func (e *LeaseLostError) Error() string {
+	return fmt.Sprintf("lease of %s has been taken by instance %s", e.InterfaceID, e.Owner)
+}
+
+// WaitTimeoutError is returned when a waiter gives up polling over Attempts
+// polls, or when the operation runs past its overall Timeout.
+type WaitTimeoutError struct {
+	Op       string
+	ID       string
+	Attempts int
+	Timeout  time.Duration
+}
+
+func (e *WaitTimeoutError) Error() string {
+	if e.Timeout > 0 {
+		return fmt.Sprintf("%s %s error: timed out after %s", e.Op, e.ID, e.Timeout)
+	}
+	return fmt.Sprintf("%s %s error: over %d polling attempts", e.Op, e.ID, e.Attempts)
+}
+
+// ENILimitExceededError is returned when the instance already has as many
+// ENIs attached as its instance type allows.
+type ENILimitExceededError struct {
+	InstanceID   string
+	InstanceType string
+	Max          int64
+}
+
+func (e *ENILimitExceededError) Error() string {
+	return fmt.Sprintf("instance %s (%s) already has the maximum %d ENIs attached", e.InstanceID, e.InstanceType, e.Max)
+}
+
+// PrimaryPrivateIPError is returned when the private IP to move is the primary
+// IP of the ENI holding it, which can't be unassigned.
+type PrimaryPrivateIPError struct {
+	PrivateIP   string
+	InterfaceID string
+}
+
+func (e *PrimaryPrivateIPError) Error() string {
+	return fmt.Sprintf("%s is the primary private IP of %s and can't be moved", e.PrivateIP, e.InterfaceID)
+}
+
+// InvalidStateError is returned when the ENI is in a state that the operation
+// can't handle.
+type InvalidStateError struct {
+	Op             string
+	InterfaceID    string
+	Status         string
+	AttachedStatus string
+}
+
+func (e *InvalidStateError) Error() string {
+	return fmt.Sprintf("%s %s error: unexpected status %q (attachment %q)", e.Op, e.InterfaceID, e.Status, e.AttachedStatus)
+}
+
+// CanceledError is returned when an operation is aborted because its context
+// has been canceled or its deadline has been exceeded.
+type CanceledError struct {
+	Op  string
+	ID  string
+	Err error
+}
+
+func (e *CanceledError) Error() string {
+	return fmt.Sprintf("%s %s canceled: %s", e.Op, e.ID, e.Err)
+}
+
+func (e *CanceledError) Unwrap() error {
+	return e.Err
+}
+
+// wrapContextError replaces err with a *WaitTimeoutError if the operation's
+// timeout has passed, or with a *CanceledError if ctx is otherwise done, so
+// that callers can tell them apart from an AWS API failure.
+func wrapContextError(ctx context.Context, op, id string, err error) error {
+	if timeout, ok := opTimedOut(ctx); ok {
+		return &WaitTimeoutError{Op: op, ID: id, Timeout: timeout}
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return &CanceledError{Op: op, ID: id, Err: ctxErr}
+	}
+	return err
+}
+
+// translateAPIError maps the "not found" error codes of EC2 API onto
+// ENINotFoundError and InstanceNotFoundError.
+func translateAPIError(err error, interfaceID, instanceID string) error {
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) {
+		return err
+	}
+
+	switch aerr.Code() {
+	case "InvalidNetworkInterfaceID.NotFound":
+		return &ENINotFoundError{InterfaceID: interfaceID}
+	case "InvalidInstanceID.NotFound":
+		return &InstanceNotFoundError{InstanceID: instanceID}
+	}
+	return err
+}
+
+// translateRouteTableError maps InvalidRouteTableID.NotFound onto
+// RouteTableNotFoundError. The error doesn't tell which of routeTableIDs is
+// missing, so it is reported for all of them if there are several.
+func translateRouteTableError(err error, routeTableIDs []string) error {
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == "InvalidRouteTableID.NotFound" {
+		return &RouteTableNotFoundError{RouteTableID: strings.Join(routeTableIDs, ", ")}
+	}
+	return err
+}
+
+// isDryRunOperation reports whether err is the response to a DryRun request
+// that would have succeeded.
+func isDryRunOperation(err error) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == "DryRunOperation"
+}
+
+// FenceError is returned when fencing the instance has failed.
+type FenceError struct {
+	InstanceID string
+	Method     string
+	Err        error
+}
+
+func (e *FenceError) Error() string {
+	return fmt.Sprintf("fencing %s by %s failed: %s", e.InstanceID, e.Method, e.Err)
+}
+
+func (e *FenceError) Unwrap() error {
+	return e.Err
+}
+
+// RollbackError is returned when an operation failed and the previous state
+// has been restored or tried to be restored.
+type RollbackError struct {
+	// Err is the error which triggered the rollback.
+	Err error
+	// RollbackErr is the error of the rollback itself, nil if it succeeded.
+	RollbackErr error
+	// Target describes the state that the rollback restores.
+	Target string
+}
+
+func (e *RollbackError) Error() string {
+	if e.RollbackErr != nil {
+		return fmt.Sprintf("%s; rollback to %s failed: %s", e.Err, e.Target, e.RollbackErr)
+	}
+	return fmt.Sprintf("%s; rolled back to %s", e.Err, e.Target)
+}
+
+func (e *RollbackError) Unwrap() error {
+	return e.Err
+}
+
+// RolledBack reports whether the previous state has been restored.
+func (e *RollbackError) RolledBack() bool {
+	return e.RollbackErr == nil
+}
@@ -0,0 +1,91 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/yuuki/grabeni/aws/model"
+)
+
+type EvacuateParam struct {
+	InstanceID string
+	// StandbyInstanceID is the instance to move the ENIs to. The ENIs are only
+	// detached if it is empty.
+	StandbyInstanceID string
+	// ForceDetach and ForceDetachGracePeriod are used as in GrabENIParam.
+	ForceDetach            bool
+	ForceDetachGracePeriod time.Duration
+	// Concurrency is the maximum number of ENIs moved at once, 1 if not positive.
+	Concurrency int
+}
+
+func (c *ENIClient) DescribeSecondaryENIs(instanceID string) ([]*model.ENI, error) {
+	return c.DescribeSecondaryENIsWithContext(context.Background(), instanceID)
+}
+
+// DescribeSecondaryENIsWithContext describes the ENIs attached to the instance
+// other than the primary one at device index 0.
+func (c *ENIClient) DescribeSecondaryENIsWithContext(ctx context.Context, instanceID string) ([]*model.ENI, error) {
+	enis, err := c.DescribeENIsWithContext(ctx, &ListFilter{InstanceID: instanceID})
+	if err != nil {
+		return nil, err
+	}
+
+	secondaries := make([]*model.ENI, 0, len(enis))
+	for _, eni := range enis {
+		if eni.AttachedDeviceIndex() != 0 {
+			secondaries = append(secondaries, eni)
+		}
+	}
+	return secondaries, nil
+}
+
+func (c *ENIClient) EvacuateENIs(p *EvacuateParam, wp *WaiterParam) ([]*ENIResult, error) {
+	return c.EvacuateENIsWithContext(context.Background(), p, wp)
+}
+
+// EvacuateENIsWithContext detaches all the secondary ENIs from p.InstanceID,
+// or grabs them to p.StandbyInstanceID at the same device indexes if free
+// there. The primary ENI is never touched.
+func (c *ENIClient) EvacuateENIsWithContext(ctx context.Context, p *EvacuateParam, wp *WaiterParam) ([]*ENIResult, error) {
+	if err := validateWaitUntilParam(wp); err != nil {
+		return nil, err
+	}
+	ctx, cancel := withOpTimeout(ctx, wp)
+	defer cancel()
+	if p.StandbyInstanceID == p.InstanceID {
+		return nil, fmt.Errorf("standby instance %s is the instance to evacuate", p.StandbyInstanceID)
+	}
+
+	enis, err := c.DescribeSecondaryENIsWithContext(ctx, p.InstanceID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(enis))
+	for _, eni := range enis {
+		ids = append(ids, eni.InterfaceID())
+	}
+	if len(ids) == 0 {
+		return []*ENIResult{}, nil
+	}
+
+	if p.StandbyInstanceID == "" {
+		return c.DetachENIsWithContext(ctx, ids, &DetachENIParam{
+			Force:            p.ForceDetach,
+			ForceGracePeriod: p.ForceDetachGracePeriod,
+		}, wp), nil
+	}
+
+	return c.GrabENIsWithContext(ctx, &GrabENIsParam{
+		GrabENIParam: GrabENIParam{
+			InstanceID:             p.StandbyInstanceID,
+			DeviceIndex:            DeviceIndexAuto,
+			PreserveDeviceIndex:    true,
+			ForceDetach:            p.ForceDetach,
+			ForceDetachGracePeriod: p.ForceDetachGracePeriod,
+		},
+		InterfaceIDs: ids,
+		Concurrency:  p.Concurrency,
+	}, wp)
+}
@@ -0,0 +1,67 @@
+package aws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestEvacuateENIs(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockEC2.On("DescribeNetworkInterfacesPagesWithContext", mock.Anything, &ec2.DescribeNetworkInterfacesInput{
+		MaxResults: aws.Int64(1000),
+		Filters: []*ec2.Filter{{
+			Name:   aws.String("attachment.instance-id"),
+			Values: aws.StringSlice([]string{"i-00000001"}),
+		}},
+	}, mock.Anything).Run(returnPages(
+		&ec2.DescribeNetworkInterfacesOutput{
+			NetworkInterfaces: []*ec2.NetworkInterface{
+				describeENIAtOutput("eni-00000000", "i-00000001", 0).NetworkInterfaces[0],
+				describeENIAtOutput("eni-0000000a", "i-00000001", 1).NetworkInterfaces[0],
+			},
+		},
+	)).Return(nil)
+	mockEC2.On("DescribeInstancesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).Run(returnPages(
+		describeInstanceOutput("i-00000001", 0, 1),
+	)).Return(nil)
+	mockDescribeInstance(mockEC2, describeInstanceOutput("i-00000001", 0, 1))
+
+	// Only the secondary ENI is detached
+	mockDescribeENISequence(mockEC2, "eni-0000000a",
+		describeENIAtOutput("eni-0000000a", "i-00000001", 1),
+		describeENIAtOutput("eni-0000000a", "", 0),
+	)
+	mockEC2.On("DetachNetworkInterfaceWithContext", mock.Anything, &ec2.DetachNetworkInterfaceInput{
+		AttachmentId: aws.String("eni-attach-0000000a"),
+		Force:        aws.Bool(false),
+	}).Return(&ec2.DetachNetworkInterfaceOutput{}, nil).Once()
+
+	results, err := c.EvacuateENIsWithContext(context.Background(), &EvacuateParam{InstanceID: "i-00000001"},
+		&WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+	assert.NoError(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "eni-0000000a", results[0].InterfaceID)
+		assert.Equal(t, OutcomeMoved, results[0].Outcome)
+	}
+	mockEC2.AssertExpectations(t)
+}
+
+func TestEvacuateENIsToItself(t *testing.T) {
+	c := newClient(new(EC2API))
+
+	_, err := c.EvacuateENIsWithContext(context.Background(), &EvacuateParam{
+		InstanceID:        "i-00000001",
+		StandbyInstanceID: "i-00000001",
+	}, &WaiterParam{MaxAttempts: 5, IntervalSec: 1})
+
+	assert.EqualError(t, err, "standby instance i-00000001 is the instance to evacuate")
+}
@@ -0,0 +1,151 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/yuuki/grabeni/aws/model"
+)
+
+// Methods of fencing an instance
+const (
+	// FenceStop force-stops the instance and waits until it has stopped.
+	FenceStop = "stop"
+	// FenceIsolate replaces the security groups of the ENIs of the instance
+	// with a quarantine security group, and waits until they are replaced.
+	FenceIsolate = "isolate"
+)
+
+type FenceParam struct {
+	InstanceID string
+	// Method is either FenceStop or FenceIsolate.
+	Method string
+	// QuarantineSecurityGroupID is the security group that FenceIsolate
+	// leaves the ENIs with.
+	QuarantineSecurityGroupID string
+	// ExcludeInterfaceIDs are left alone by FenceIsolate, such as the ENIs
+	// being grabbed from the instance.
+	ExcludeInterfaceIDs []string
+}
+
+// ValidateFenceMethod checks the fence method and the quarantine security
+// group it requires.
+func ValidateFenceMethod(method, quarantineSecurityGroupID string) error {
+	switch method {
+	case FenceStop:
+		return nil
+	case FenceIsolate:
+		if quarantineSecurityGroupID == "" {
+			return fmt.Errorf("fencing by %s requires a quarantine security group", method)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown fence method %q, must be %s or %s", method, FenceStop, FenceIsolate)
+}
+
+func (c *ENIClient) FenceInstance(p *FenceParam, wp *WaiterParam) error {
+	return c.FenceInstanceWithContext(context.Background(), p, wp)
+}
+
+// FenceInstanceWithContext makes sure the instance can't serve anymore by
+// p.Method, and waits with wp for the fence to take effect. Errors of the
+// fence itself are returned as FenceError.
+func (c *ENIClient) FenceInstanceWithContext(ctx context.Context, p *FenceParam, wp *WaiterParam) error {
+	if err := ValidateFenceMethod(p.Method, p.QuarantineSecurityGroupID); err != nil {
+		return err
+	}
+	if err := validateWaitUntilParam(wp); err != nil {
+		return err
+	}
+	ctx, cancel := withOpTimeout(ctx, wp)
+	defer cancel()
+
+	c.logger.Printf("--> Fencing: %15s (%s)\n", p.InstanceID, p.Method)
+
+	var err error
+	switch p.Method {
+	case FenceStop:
+		err = c.stopInstance(ctx, p.InstanceID, wp)
+	case FenceIsolate:
+		err = c.isolateInstance(ctx, p, wp)
+	}
+	if err != nil {
+		return &FenceError{InstanceID: p.InstanceID, Method: p.Method, Err: err}
+	}
+
+	c.logger.Printf("--> Fenced: %15s (%s)\n", p.InstanceID, p.Method)
+	return nil
+}
+
+func (c *ENIClient) stopInstance(ctx context.Context, instanceID string, wp *WaiterParam) error {
+	_, err := c.svc.StopInstancesWithContext(ctx, &ec2.StopInstancesInput{
+		InstanceIds: []*string{aws.String(instanceID)},
+		Force:       aws.Bool(true),
+	})
+	if err != nil {
+		return wrapContextError(ctx, "stop", instanceID, translateAPIError(err, "", instanceID))
+	}
+
+	return c.waitUntil(ctx, "stop", instanceID, wp, func(ctx context.Context) (bool, error) {
+		instance, err := c.DescribeInstanceByIDWithContext(ctx, instanceID)
+		if err != nil {
+			return false, err
+		}
+		return instance.StateName() == "stopped", nil
+	})
+}
+
+// isolateInstance replaces the security groups of the ENIs of the instance
+// but the excluded ones with the quarantine security group.
+func (c *ENIClient) isolateInstance(ctx context.Context, p *FenceParam, wp *WaiterParam) error {
+	instance, err := c.DescribeInstanceByIDWithContext(ctx, p.InstanceID)
+	if err != nil {
+		return err
+	}
+
+	ids := isolatedInterfaceIDs(instance, p)
+	for _, id := range ids {
+		_, err := c.svc.ModifyNetworkInterfaceAttributeWithContext(ctx, &ec2.ModifyNetworkInterfaceAttributeInput{
+			NetworkInterfaceId: aws.String(id),
+			Groups:             []*string{aws.String(p.QuarantineSecurityGroupID)},
+		})
+		if err != nil {
+			return wrapContextError(ctx, "isolate", id, translateAPIError(err, id, p.InstanceID))
+		}
+		c.logger.Printf("--> Isolated: %15s (%s)\n", id, p.QuarantineSecurityGroupID)
+	}
+
+	return c.waitUntil(ctx, "isolate", p.InstanceID, wp, func(ctx context.Context) (bool, error) {
+		instance, err := c.DescribeInstanceByIDWithContext(ctx, p.InstanceID)
+		if err != nil {
+			return false, err
+		}
+		return len(isolatedInterfaceIDs(instance, p)) == 0, nil
+	})
+}
+
+// isolatedInterfaceIDs returns the ENIs of the instance to isolate which have
+// a security group other than the quarantine one.
+func isolatedInterfaceIDs(instance *model.Instance, p *FenceParam) []string {
+	excluded := make(map[string]bool, len(p.ExcludeInterfaceIDs))
+	for _, id := range p.ExcludeInterfaceIDs {
+		excluded[id] = true
+	}
+
+	ids := make([]string, 0, len(instance.NetworkInterfaces))
+	for _, iface := range instance.NetworkInterfaces {
+		if iface.NetworkInterfaceId == nil || excluded[*iface.NetworkInterfaceId] {
+			continue
+		}
+		for _, g := range iface.Groups {
+			if g.GroupId == nil || *g.GroupId != p.QuarantineSecurityGroupID {
+				ids = append(ids, *iface.NetworkInterfaceId)
+				break
+			}
+		}
+	}
+	return ids
+}
@@ -0,0 +1,141 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Build a DescribeInstances response for an instance in the state with ENIs
+// in the security groups by ENI ID
+func describeFencedInstanceOutput(instanceID, state string, groups map[string]string) *ec2.DescribeInstancesOutput {
+	out := describeInstanceOutput(instanceID)
+	instance := out.Reservations[0].Instances[0]
+	instance.State = &ec2.InstanceState{Name: aws.String(state)}
+	ids := make([]string, 0, len(groups))
+	for id := range groups {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		instance.NetworkInterfaces = append(instance.NetworkInterfaces, &ec2.InstanceNetworkInterface{
+			NetworkInterfaceId: aws.String(id),
+			Groups:             []*ec2.GroupIdentifier{{GroupId: aws.String(groups[id])}},
+		})
+	}
+	return out
+}
+
+var fenceWaiter = &WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}}
+
+func TestFenceInstanceStop(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockEC2.On("StopInstancesWithContext", mock.Anything, &ec2.StopInstancesInput{
+		InstanceIds: []*string{aws.String("i-00000001")},
+		Force:       aws.Bool(true),
+	}).Return(&ec2.StopInstancesOutput{}, nil).Once()
+	mockDescribeInstance(mockEC2, describeFencedInstanceOutput("i-00000001", "stopping", nil)).Once()
+	mockDescribeInstance(mockEC2, describeFencedInstanceOutput("i-00000001", "stopped", nil)).Once()
+
+	err := c.FenceInstanceWithContext(context.Background(), &FenceParam{InstanceID: "i-00000001", Method: FenceStop}, fenceWaiter)
+
+	assert.NoError(t, err)
+	mockEC2.AssertExpectations(t)
+}
+
+func TestFenceInstanceIsolate(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockDescribeInstance(mockEC2, describeFencedInstanceOutput("i-00000001", "running", map[string]string{
+		"eni-0000000a": "sg-app", "eni-0000000b": "sg-quarantine", "eni-00000001": "sg-app",
+	})).Once()
+	mockDescribeInstance(mockEC2, describeFencedInstanceOutput("i-00000001", "running", map[string]string{
+		"eni-0000000a": "sg-quarantine", "eni-0000000b": "sg-quarantine", "eni-00000001": "sg-app",
+	})).Once()
+	mockEC2.On("ModifyNetworkInterfaceAttributeWithContext", mock.Anything, &ec2.ModifyNetworkInterfaceAttributeInput{
+		NetworkInterfaceId: aws.String("eni-0000000a"),
+		Groups:             []*string{aws.String("sg-quarantine")},
+	}).Return(&ec2.ModifyNetworkInterfaceAttributeOutput{}, nil).Once()
+
+	// The grabbed ENI keeps its security groups
+	err := c.FenceInstanceWithContext(context.Background(), &FenceParam{
+		InstanceID:                "i-00000001",
+		Method:                    FenceIsolate,
+		QuarantineSecurityGroupID: "sg-quarantine",
+		ExcludeInterfaceIDs:       []string{"eni-00000001"},
+	}, fenceWaiter)
+
+	assert.NoError(t, err)
+	mockEC2.AssertExpectations(t)
+}
+
+func TestFenceInstanceInvalid(t *testing.T) {
+	c := newClient(new(EC2API))
+
+	err := c.FenceInstanceWithContext(context.Background(), &FenceParam{InstanceID: "i-00000001", Method: "terminate"}, fenceWaiter)
+	assert.EqualError(t, err, `unknown fence method "terminate", must be stop or isolate`)
+
+	err = c.FenceInstanceWithContext(context.Background(), &FenceParam{InstanceID: "i-00000001", Method: FenceIsolate}, fenceWaiter)
+	assert.EqualError(t, err, "fencing by isolate requires a quarantine security group")
+}
+
+func TestGrabENIFenceFailed(t *testing.T) {
+	stopErr := awserr.New("UnauthorizedOperation", "You are not authorized to perform this operation.", nil)
+
+	tests := []struct {
+		name string
+		skip bool
+	}{
+		{name: "abort"},
+		{name: "skip on error", skip: true},
+	}
+
+	for _, tt := range tests {
+		mockEC2 := new(EC2API)
+		c := newClient(mockEC2)
+
+		mockDescribeENISequence(mockEC2, "eni-00000001",
+			describeENIAtOutput("eni-00000001", "i-00000001", 1),
+			describeENIAtOutput("eni-00000001", "i-00000001", 1),
+		)
+		mockDescribeInstance(mockEC2, describeInstanceOutput("i-00000001", 0, 1))
+		mockDescribeInstance(mockEC2, describeInstanceOutput("i-00000002", 0))
+		mockEC2.On("StopInstancesWithContext", mock.Anything, &ec2.StopInstancesInput{
+			InstanceIds: []*string{aws.String("i-00000001")},
+			Force:       aws.Bool(true),
+		}).Return(nil, stopErr).Once()
+		// Stops at detaching when fencing is skipped
+		detachErr := errors.New("detach failed")
+		mockEC2.On("DetachNetworkInterfaceWithContext", mock.Anything, mock.Anything).Return(nil, detachErr)
+
+		_, err := c.GrabENIWithContext(context.Background(), &GrabENIParam{
+			InterfaceID:      "eni-00000001",
+			InstanceID:       "i-00000002",
+			DeviceIndex:      1,
+			Fence:            FenceStop,
+			SkipFenceOnError: tt.skip,
+		}, fenceWaiter)
+
+		if tt.skip {
+			assert.Equal(t, detachErr, err, tt.name)
+			continue
+		}
+		var fenceErr *FenceError
+		if assert.True(t, errors.As(err, &fenceErr), tt.name) {
+			assert.Equal(t, "i-00000001", fenceErr.InstanceID)
+		}
+		assert.EqualError(t, err, "fencing i-00000001 by stop failed: UnauthorizedOperation: You are not authorized to perform this operation.")
+		mockEC2.AssertNotCalled(t, "DetachNetworkInterfaceWithContext", mock.Anything, mock.Anything)
+	}
+}
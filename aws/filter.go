@@ -0,0 +1,131 @@
+package aws
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/yuuki/grabeni/aws/model"
+)
+
+// ListFilter narrows down the ENIs described by DescribeENIs. Empty fields
+// don't filter anything.
+type ListFilter struct {
+	VPCID            string
+	SubnetID         string
+	AvailabilityZone string
+	Status           string
+	InstanceID       string
+	// Tags requires all of the tags to have the exact values.
+	Tags map[string]string
+	// Name is a glob pattern matched against the whole Name tag. '*' matches
+	// any sequence of characters, '?' any single character and '[...]' any
+	// character in the class.
+	Name string
+}
+
+// ec2Filters returns the filters that EC2 can apply on its side.
+func (f *ListFilter) ec2Filters() []*ec2.Filter {
+	if f == nil {
+		return nil
+	}
+
+	filters := make([]*ec2.Filter, 0)
+	add := func(name, value string) {
+		if value != "" {
+			filters = append(filters, &ec2.Filter{
+				Name:   aws.String(name),
+				Values: []*string{aws.String(value)},
+			})
+		}
+	}
+
+	add("vpc-id", f.VPCID)
+	add("subnet-id", f.SubnetID)
+	add("availability-zone", f.AvailabilityZone)
+	add("status", f.Status)
+	add("attachment.instance-id", f.InstanceID)
+
+	// Sort keys to make the request stable
+	keys := make([]string, 0, len(f.Tags))
+	for k := range f.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		add("tag:"+k, f.Tags[k])
+	}
+
+	// EC2 filters understand '*' and '?' wildcards, but not character classes
+	if !strings.ContainsAny(f.Name, `[\`) {
+		add("tag:Name", f.Name)
+	}
+
+	if len(filters) < 1 {
+		return nil
+	}
+	return filters
+}
+
+// matcher returns a function reporting whether an ENI passes the filters
+// which EC2 can't apply.
+func (f *ListFilter) matcher() (func(*model.ENI) bool, error) {
+	if f == nil || f.Name == "" {
+		return func(*model.ENI) bool { return true }, nil
+	}
+
+	re, err := globToRegexp(f.Name)
+	if err != nil {
+		return nil, err
+	}
+	return func(eni *model.ENI) bool { return re.MatchString(eni.Name()) }, nil
+}
+
+// globToRegexp compiles a glob pattern to a regexp matching the whole string.
+// The pattern is read rune by rune, so that non-ASCII names match.
+func globToRegexp(pattern string) (*regexp.Regexp, error) {
+	runes := []rune(pattern)
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '[':
+			end := indexRune(runes[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid name pattern %q: missing ']'", pattern)
+			}
+			class := string(runes[i+1 : i+end])
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+			}
+			b.WriteString(regexp.QuoteMeta(string(runes[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+func indexRune(runes []rune, r rune) int {
+	for i, c := range runes {
+		if c == r {
+			return i
+		}
+	}
+	return -1
+}
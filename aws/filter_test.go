@@ -0,0 +1,114 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/yuuki/grabeni/aws/model"
+)
+
+func TestListFilterEC2Filters(t *testing.T) {
+	var f *ListFilter
+	assert.Nil(t, f.ec2Filters())
+	assert.Nil(t, (&ListFilter{}).ec2Filters())
+
+	f = &ListFilter{
+		VPCID:            "vpc-00000001",
+		SubnetID:         "subnet-00000001",
+		AvailabilityZone: "ap-northeast-1a",
+		Status:           "in-use",
+		InstanceID:       "i-00000001",
+		Tags:             map[string]string{"role": "db", "env": "production"},
+		Name:             "db-*",
+	}
+
+	assert.Equal(t, []*ec2.Filter{
+		{Name: aws.String("vpc-id"), Values: []*string{aws.String("vpc-00000001")}},
+		{Name: aws.String("subnet-id"), Values: []*string{aws.String("subnet-00000001")}},
+		{Name: aws.String("availability-zone"), Values: []*string{aws.String("ap-northeast-1a")}},
+		{Name: aws.String("status"), Values: []*string{aws.String("in-use")}},
+		{Name: aws.String("attachment.instance-id"), Values: []*string{aws.String("i-00000001")}},
+		{Name: aws.String("tag:env"), Values: []*string{aws.String("production")}},
+		{Name: aws.String("tag:role"), Values: []*string{aws.String("db")}},
+		{Name: aws.String("tag:Name"), Values: []*string{aws.String("db-*")}},
+	}, f.ec2Filters())
+
+	// Character classes are matched on the client side
+	f = &ListFilter{Name: "db-[0-9]"}
+	assert.Nil(t, f.ec2Filters())
+}
+
+func TestListFilterMatcher(t *testing.T) {
+	eni := func(name string) *model.ENI {
+		return model.NewENI(&ec2.NetworkInterface{
+			TagSet: []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String(name)}},
+		})
+	}
+
+	tests := []struct {
+		pattern  string
+		name     string
+		expected bool
+	}{
+		{"", "anything", true},
+		{"db-*", "db-master-vip", true},
+		{"db-*", "web-vip", false},
+		{"db-?", "db-1", true},
+		{"db-?", "db-10", false},
+		{"db-[0-9]", "db-1", true},
+		{"db-[!0-9]", "db-1", false},
+		{"db/*", "db/master", true},
+		{"db.vip", "dbxvip", false},
+		{`db\*`, "db*", true},
+		{"データベース-*", "データベース-vip", true},
+		{"db-?", "db-é", true},
+		{`db-\é`, "db-é", true},
+		{"db-[éè]", "db-è", true},
+	}
+
+	for _, tt := range tests {
+		match, err := (&ListFilter{Name: tt.pattern}).matcher()
+		if assert.NoError(t, err) {
+			assert.Equal(t, tt.expected, match(eni(tt.name)), "pattern %q name %q", tt.pattern, tt.name)
+		}
+	}
+
+	_, err := (&ListFilter{Name: "db-[0-9"}).matcher()
+	assert.EqualError(t, err, `invalid name pattern "db-[0-9": missing ']'`)
+}
+
+func TestDescribeENIsWithFilter(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockEC2.On("DescribeNetworkInterfacesPagesWithContext", mock.Anything, &ec2.DescribeNetworkInterfacesInput{
+		MaxResults: aws.Int64(1000),
+		Filters: []*ec2.Filter{
+			{Name: aws.String("vpc-id"), Values: []*string{aws.String("vpc-00000001")}},
+		},
+	}, mock.Anything).Run(returnPages(
+		&ec2.DescribeNetworkInterfacesOutput{
+			NetworkInterfaces: []*ec2.NetworkInterface{
+				{
+					NetworkInterfaceId: aws.String("eni-00000001"),
+					TagSet:             []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("db-1")}},
+				},
+				{
+					NetworkInterfaceId: aws.String("eni-00000002"),
+					TagSet:             []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("db-a")}},
+				},
+			},
+		},
+	)).Return(nil)
+
+	enis, err := c.DescribeENIs(&ListFilter{VPCID: "vpc-00000001", Name: "db-[0-9]"})
+
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(enis)) {
+		assert.Equal(t, "eni-00000001", enis[0].InterfaceID())
+	}
+}
@@ -0,0 +1,316 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/yuuki/grabeni/aws/model"
+)
+
+// errAtomicAborted is the cause of the rollback of the ENIs that have been
+// grabbed by an atomic grab when another one failed.
+var errAtomicAborted = errors.New("atomic grab aborted by another ENI")
+
+// GrabENIsParam grabs several ENIs to the same instance.
+type GrabENIsParam struct {
+	// GrabENIParam applies to each of InterfaceIDs, with its InterfaceID ignored.
+	GrabENIParam
+	InterfaceIDs []string
+	// Concurrency is the maximum number of ENIs grabbed at once, 1 if not positive.
+	Concurrency int
+	// Atomic moves the grabbed ENIs back to where they were if any of the
+	// others fails, so that they never end up split across instances.
+	Atomic bool
+}
+
+// grabTask is the grab of one of the ENIs of GrabENIsParam, with the device
+// index decided up front so that concurrent grabs don't pick the same one.
+type grabTask struct {
+	param GrabENIParam
+	// prevInstanceID and prevDeviceIndex are where the ENI is attached before
+	// the grab, to roll back to.
+	prevInstanceID  string
+	prevDeviceIndex int64
+	// err fails the task without running it.
+	err error
+	// detached is whether the grab has detached or started to detach the ENI
+	// from prevInstanceID.
+	detached bool
+}
+
+func (t *grabTask) rollbackTarget() string {
+	if t.prevInstanceID == "" {
+		return "no attachment"
+	}
+	return fmt.Sprintf("instance %s (device index %d)", t.prevInstanceID, t.prevDeviceIndex)
+}
+
+// prepareGrabENIs describes the ENIs and the target instance, and decides the
+// device index of each ENI. An ENI that can't be grabbed, including one over
+// the ENI limit of the instance type, gets a task failing with the reason.
+func (c *ENIClient) prepareGrabENIs(ctx context.Context, p *GrabENIsParam) ([]*grabTask, error) {
+	instance, err := c.describeInstanceWithType(ctx, p.InstanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	used := make(map[int64]bool)
+	for _, idx := range instance.AttachedDeviceIndexes() {
+		used[idx] = true
+	}
+	attached := int64(len(instance.NetworkInterfaces))
+	max := instance.MaxNetworkInterfaces()
+
+	ids := uniqueStrings(p.InterfaceIDs)
+	tasks := make([]*grabTask, 0, len(ids))
+	for _, id := range ids {
+		t := &grabTask{param: p.GrabENIParam}
+		t.param.InterfaceID = id
+		t.param.PreserveDeviceIndex = false
+		t.param.fenceExclude = ids
+		tasks = append(tasks, t)
+
+		eni, err := c.DescribeENIByIDWithContext(ctx, id)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			t.err = err
+			continue
+		}
+		t.prevInstanceID, t.prevDeviceIndex = eni.AttachedInstanceID(), eni.AttachedDeviceIndex()
+		if t.prevInstanceID == p.InstanceID {
+			t.param.DeviceIndex = int(t.prevDeviceIndex)
+			continue
+		}
+
+		if max > 0 && attached >= max {
+			t.err = &ENILimitExceededError{InstanceID: instance.InstanceID(), InstanceType: instance.Type(), Max: max}
+			continue
+		}
+		attached++
+		t.param.DeviceIndex = c.nextDeviceIndex(p, eni, used)
+		used[int64(t.param.DeviceIndex)] = true
+	}
+
+	return tasks, nil
+}
+
+// nextDeviceIndex is grabDeviceIndex for the ENIs of GrabENIsParam, taking
+// the device indexes decided for the preceding ENIs as used.
+func (c *ENIClient) nextDeviceIndex(p *GrabENIsParam, eni *model.ENI, used map[int64]bool) int {
+	id := eni.InterfaceID()
+	if prev := eni.AttachedDeviceIndex(); p.PreserveDeviceIndex && eni.AttachedInstanceID() != "" {
+		if !used[prev] {
+			c.logger.Printf("--> Device index: %d for %s (preserved)\n", prev, id)
+			return int(prev)
+		}
+		c.logger.Printf("--> Device index: %d is in use on %s, not preserved for %s\n", prev, p.InstanceID, id)
+	}
+
+	if p.DeviceIndex == DeviceIndexAuto {
+		idx := int64(1)
+		for used[idx] {
+			idx++
+		}
+		c.logger.Printf("--> Device index: %d for %s (auto)\n", idx, id)
+		return int(idx)
+	}
+	return p.DeviceIndex
+}
+
+// GrabENIsWithContext grabs the ENIs to p.InstanceID, at most p.Concurrency
+// at once. A failure of one ENI doesn't stop the others unless p.Atomic.
+func (c *ENIClient) GrabENIsWithContext(ctx context.Context, p *GrabENIsParam, wp *WaiterParam) ([]*ENIResult, error) {
+	if err := validateWaitUntilParam(wp); err != nil {
+		return nil, err
+	}
+	ctx, cancel := withOpTimeout(ctx, wp)
+	defer cancel()
+
+	tasks, err := c.prepareGrabENIs(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*ENIResult, len(tasks))
+	failed := false
+	for i, t := range tasks {
+		results[i] = &ENIResult{InterfaceID: t.param.InterfaceID, InstanceID: p.InstanceID}
+		if t.err != nil {
+			results[i].Outcome, results[i].Err = OutcomeFailed, t.err
+			failed = true
+		}
+	}
+
+	// Don't touch any ENI if one of them is known to fail beforehand
+	if p.Atomic && failed {
+		for _, r := range results {
+			if r.Err == nil {
+				r.Outcome = OutcomeAborted
+			}
+		}
+		return results, nil
+	}
+
+	concurrency := p.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Start the grabs in the order of the ENIs with a pool of workers
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				c.runGrabTask(runCtx, cancel, p, tasks[i], results[i], wp)
+			}
+		}()
+	}
+	for i, t := range tasks {
+		if t.err == nil {
+			queue <- i
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if p.Atomic {
+		for _, r := range results {
+			if r.Outcome == OutcomeFailed || r.Outcome == OutcomeAborted {
+				c.rollbackGrabENIs(tasks, results, wp)
+				break
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// runGrabTask grabs the ENI of t and records the outcome in r. An atomic grab
+// is given up by canceling ctx with cancel as soon as one ENI fails.
+func (c *ENIClient) runGrabTask(ctx context.Context, cancel context.CancelFunc, p *GrabENIsParam, t *grabTask, r *ENIResult, wp *WaiterParam) {
+	// Leave the ENIs not started yet alone once an atomic grab is given up
+	if p.Atomic && ctx.Err() != nil {
+		r.Outcome = OutcomeAborted
+		return
+	}
+
+	eni, detached, err := c.grabENI(ctx, &t.param, wp)
+	t.detached = detached
+	switch {
+	case err != nil:
+		r.Outcome, r.Err = OutcomeFailed, err
+		if p.Atomic {
+			cancel()
+		}
+	case eni == nil:
+		r.Outcome = OutcomeSkipped
+	default:
+		r.Outcome = OutcomeMoved
+	}
+}
+
+// rollbackGrabENIs moves each of the ENIs that an atomic grab has moved, or
+// has detached before failing, back to where it was, one by one. ENIs that
+// the grab hasn't touched, such as those leased by another instance, are left
+// alone, and so are those grabbed by another instance in the meantime. Like
+// rollbackGrab, it doesn't inherit the context of the grab.
+func (c *ENIClient) rollbackGrabENIs(tasks []*grabTask, results []*ENIResult, wp *WaiterParam) {
+	for i, t := range tasks {
+		r := results[i]
+		if t.err != nil || !(r.Outcome == OutcomeMoved || (r.Outcome == OutcomeFailed && t.detached)) {
+			continue
+		}
+
+		c.logger.Printf("--> Rolling back: %15s to %s\n", t.param.InterfaceID, t.rollbackTarget())
+
+		// The lease goes back along with the ENI. A failed grab has released it.
+		if r.Outcome == OutcomeMoved {
+			c.releaseGrabLease(&t.param)
+		}
+
+		var err error
+		if t.prevInstanceID == "" {
+			err = c.rollbackAttachment(t, wp)
+		} else {
+			_, err = c.GrabENIWithContext(context.Background(), &GrabENIParam{
+				InterfaceID:            t.param.InterfaceID,
+				InstanceID:             t.prevInstanceID,
+				DeviceIndex:            int(t.prevDeviceIndex),
+				ForceDetach:            t.param.ForceDetach,
+				ForceDetachGracePeriod: t.param.ForceDetachGracePeriod,
+				LeaseTTL:               t.param.LeaseTTL,
+				LeaseSettleDelay:       t.param.LeaseSettleDelay,
+				rollbackFrom:           t.param.InstanceID,
+			}, wp)
+		}
+
+		if err != nil {
+			cause := r.Err
+			if cause == nil {
+				cause = errAtomicAborted
+			}
+			r.Outcome, r.Err = OutcomeFailed, &RollbackError{Err: cause, RollbackErr: err, Target: t.rollbackTarget()}
+			continue
+		}
+		r.Outcome = OutcomeRolledBack
+	}
+}
+
+// rollbackAttachment detaches the ENI of t that was available before the
+// grab, as long as it is still attached to the instance of the grab.
+func (c *ENIClient) rollbackAttachment(t *grabTask, wp *WaiterParam) error {
+	ctx := context.Background()
+	eni, err := c.DescribeENIByIDWithContext(ctx, t.param.InterfaceID)
+	if err != nil {
+		return err
+	}
+	switch eni.AttachedInstanceID() {
+	case "":
+		return nil
+	case t.param.InstanceID:
+	default:
+		return &AlreadyAttachedError{InterfaceID: t.param.InterfaceID, InstanceID: eni.AttachedInstanceID()}
+	}
+
+	_, err = c.DetachENIWithWaiterWithContext(ctx, &DetachENIParam{
+		InterfaceID:      t.param.InterfaceID,
+		Force:            t.param.ForceDetach,
+		ForceGracePeriod: t.param.ForceDetachGracePeriod,
+	}, wp)
+	return err
+}
+
+// PlanGrabENIsWithContext works out what GrabENIsWithContext would do with p,
+// one plan per ENI, like PlanGrabENIWithContext. It stops at the first ENI
+// that would fail.
+func (c *ENIClient) PlanGrabENIsWithContext(ctx context.Context, p *GrabENIsParam) ([]*GrabPlan, error) {
+	tasks, err := c.prepareGrabENIs(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	plans := make([]*GrabPlan, 0, len(tasks))
+	for _, t := range tasks {
+		if t.err != nil {
+			return plans, t.err
+		}
+		plan, err := c.PlanGrabENIWithContext(ctx, &t.param)
+		if plan != nil {
+			plans = append(plans, plan)
+		}
+		if err != nil {
+			return plans, err
+		}
+	}
+	return plans, nil
+}
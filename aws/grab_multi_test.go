@@ -0,0 +1,257 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGrabENIsConcurrentDeviceIndexes(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockDescribeInstance(mockEC2, describeInstanceOutput("i-00000002", 0, 1))
+	for i, id := range []string{"eni-0000000a", "eni-0000000b"} {
+		idx := int64(i + 2)
+		mockDescribeENISequence(mockEC2, id,
+			describeENIAtOutput(id, "", 0),
+			describeENIAtOutput(id, "", 0),
+			describeENIAtOutput(id, "", 0),
+			describeENIAtOutput(id, "i-00000002", idx),
+		)
+		mockAttach(mockEC2, id, "i-00000002", idx, nil)
+	}
+
+	results, err := c.GrabENIsWithContext(context.Background(), &GrabENIsParam{
+		GrabENIParam: GrabENIParam{InstanceID: "i-00000002", DeviceIndex: DeviceIndexAuto},
+		InterfaceIDs: []string{"eni-0000000a", "eni-0000000b"},
+		Concurrency:  2,
+	}, &WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+	assert.NoError(t, err)
+	if assert.Len(t, results, 2) {
+		for _, r := range results {
+			assert.Equal(t, OutcomeMoved, r.Outcome, r.InterfaceID)
+			assert.NoError(t, r.Err, r.InterfaceID)
+		}
+	}
+	mockEC2.AssertExpectations(t)
+}
+
+func TestGrabENIsAtomicRollback(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockDescribeInstance(mockEC2, describeInstanceOutput("i-00000001", 0, 1, 2))
+	mockDescribeInstance(mockEC2, describeInstanceOutput("i-00000002", 0))
+
+	a, b := "eni-0000000a", "eni-0000000b"
+	// eni-0000000a is grabbed, then moved back after eni-0000000b fails
+	mockDescribeENISequence(mockEC2, a,
+		describeENIAtOutput(a, "i-00000001", 1),
+		describeENIAtOutput(a, "i-00000001", 1),
+		describeENIAtOutput(a, "i-00000001", 1),
+		describeENIAtOutput(a, "", 0),
+		describeENIAtOutput(a, "", 0),
+		describeENIAtOutput(a, "i-00000002", 1),
+		describeENIAtOutput(a, "i-00000002", 1),
+		describeENIAtOutput(a, "i-00000002", 1),
+		describeENIAtOutput(a, "", 0),
+		describeENIAtOutput(a, "", 0),
+		describeENIAtOutput(a, "i-00000001", 1),
+	)
+	// eni-0000000b is detached, fails to be attached, then reattached
+	mockDescribeENISequence(mockEC2, b,
+		describeENIAtOutput(b, "i-00000001", 2),
+		describeENIAtOutput(b, "i-00000001", 2),
+		describeENIAtOutput(b, "i-00000001", 2),
+		describeENIAtOutput(b, "", 0),
+		describeENIAtOutput(b, "", 0),
+		describeENIAtOutput(b, "", 0),
+		describeENIAtOutput(b, "", 0),
+		describeENIAtOutput(b, "i-00000001", 2),
+	)
+	mockEC2.On("DetachNetworkInterfaceWithContext", mock.Anything, mock.Anything).Return(
+		&ec2.DetachNetworkInterfaceOutput{}, nil).Times(3)
+
+	attachErr := errors.New("AttachmentLimitExceeded")
+	mockAttach(mockEC2, a, "i-00000002", 1, nil)
+	mockAttach(mockEC2, b, "i-00000002", 2, attachErr)
+	mockAttach(mockEC2, a, "i-00000001", 1, nil)
+	mockAttach(mockEC2, b, "i-00000001", 2, nil)
+
+	results, err := c.GrabENIsWithContext(context.Background(), &GrabENIsParam{
+		GrabENIParam: GrabENIParam{InstanceID: "i-00000002", DeviceIndex: DeviceIndexAuto},
+		InterfaceIDs: []string{a, b},
+		Concurrency:  1,
+		Atomic:       true,
+	}, &WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+	assert.NoError(t, err)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, OutcomeRolledBack, results[0].Outcome)
+		assert.NoError(t, results[0].Err)
+		assert.Equal(t, OutcomeRolledBack, results[1].Outcome)
+		assert.True(t, errors.Is(results[1].Err, attachErr))
+	}
+	mockEC2.AssertExpectations(t)
+}
+
+// leasedENIOutput sets the tags of the lease by owner with token to out.
+func leasedENIOutput(out *ec2.DescribeNetworkInterfacesOutput, owner, token string) *ec2.DescribeNetworkInterfacesOutput {
+	out.NetworkInterfaces[0].TagSet = leaseOutput(owner, time.Now().Add(time.Minute), token).NetworkInterfaces[0].TagSet
+	return out
+}
+
+func TestGrabENIsAtomicRollbackLeased(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockDescribeInstance(mockEC2, describeInstanceOutput("i-00000001", 0, 1, 2))
+	mockDescribeInstance(mockEC2, describeInstanceOutput("i-00000002", 0))
+
+	a, b := "eni-0000000a", "eni-0000000b"
+	// eni-0000000a is leased and grabbed, then moved back with the lease
+	// after eni-0000000b fails
+	mockDescribeENISequence(mockEC2, a,
+		describeENIAtOutput(a, "i-00000001", 1),
+		describeENIAtOutput(a, "i-00000001", 1),
+		describeENIAtOutput(a, "i-00000001", 1),
+		leasedENIOutput(describeENIAtOutput(a, "i-00000001", 1), "i-00000002", "1"),
+		describeENIAtOutput(a, "i-00000001", 1),
+		describeENIAtOutput(a, "", 0),
+		describeENIAtOutput(a, "", 0),
+		describeENIAtOutput(a, "i-00000002", 1),
+		leasedENIOutput(describeENIAtOutput(a, "i-00000002", 1), "i-00000002", "1"),
+		describeENIAtOutput(a, "i-00000002", 1),
+		leasedENIOutput(describeENIAtOutput(a, "i-00000002", 1), "", "1"),
+		leasedENIOutput(describeENIAtOutput(a, "i-00000002", 1), "i-00000001", "2"),
+		describeENIAtOutput(a, "i-00000002", 1),
+		describeENIAtOutput(a, "", 0),
+		describeENIAtOutput(a, "", 0),
+		describeENIAtOutput(a, "i-00000001", 1),
+	)
+	// eni-0000000b is leased by another instance, and left alone
+	mockDescribeENISequence(mockEC2, b,
+		describeENIAtOutput(b, "i-00000001", 2),
+		describeENIAtOutput(b, "i-00000001", 2),
+		leasedENIOutput(describeENIAtOutput(b, "i-00000001", 2), "i-00000003", "5"),
+	)
+	writeLease := func(owner, token string) {
+		mockEC2.On("CreateTagsWithContext", mock.Anything, mock.MatchedBy(func(in *ec2.CreateTagsInput) bool {
+			return *in.Resources[0] == a && *in.Tags[0].Value == owner && *in.Tags[2].Value == token
+		})).Return(&ec2.CreateTagsOutput{}, nil).Once()
+	}
+	writeLease("i-00000002", "1")
+	writeLease("i-00000001", "2")
+	mockEC2.On("DeleteTagsWithContext", mock.Anything, mock.MatchedBy(func(in *ec2.DeleteTagsInput) bool {
+		return *in.Resources[0] == a
+	})).Return(&ec2.DeleteTagsOutput{}, nil).Once()
+	mockEC2.On("DetachNetworkInterfaceWithContext", mock.Anything, mock.Anything).Return(
+		&ec2.DetachNetworkInterfaceOutput{}, nil).Twice()
+	mockAttach(mockEC2, a, "i-00000002", 1, nil)
+	mockAttach(mockEC2, a, "i-00000001", 1, nil)
+
+	results, err := c.GrabENIsWithContext(context.Background(), &GrabENIsParam{
+		GrabENIParam: GrabENIParam{InstanceID: "i-00000002", DeviceIndex: DeviceIndexAuto, LeaseTTL: time.Minute},
+		InterfaceIDs: []string{a, b},
+		Concurrency:  1,
+		Atomic:       true,
+	}, &WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+	assert.NoError(t, err)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, OutcomeRolledBack, results[0].Outcome)
+		assert.Equal(t, OutcomeFailed, results[1].Outcome)
+		var held *LeaseHeldError
+		assert.True(t, errors.As(results[1].Err, &held))
+		var rollbackErr *RollbackError
+		assert.False(t, errors.As(results[1].Err, &rollbackErr))
+	}
+	mockEC2.AssertExpectations(t)
+}
+
+func TestGrabENIsAtomicRollbackTakenOver(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockDescribeInstance(mockEC2, describeInstanceOutput("i-00000002", 0))
+	mockDescribeInstance(mockEC2, describeInstanceOutput("i-00000003", 0, 1))
+
+	a, b := "eni-0000000a", "eni-0000000b"
+	// eni-0000000a is grabbed, but has been taken by another instance by the
+	// time of the rollback
+	mockDescribeENISequence(mockEC2, a,
+		describeENIAtOutput(a, "", 0),
+		describeENIAtOutput(a, "", 0),
+		describeENIAtOutput(a, "", 0),
+		describeENIAtOutput(a, "i-00000002", 1),
+		describeENIAtOutput(a, "i-00000003", 1),
+	)
+	mockDescribeENISequence(mockEC2, b,
+		describeENIAtOutput(b, "", 0),
+		describeENIAtOutput(b, "", 0),
+		describeENIAtOutput(b, "", 0),
+	)
+	attachErr := errors.New("AttachmentLimitExceeded")
+	mockAttach(mockEC2, a, "i-00000002", 1, nil)
+	mockAttach(mockEC2, b, "i-00000002", 2, attachErr)
+
+	results, err := c.GrabENIsWithContext(context.Background(), &GrabENIsParam{
+		GrabENIParam: GrabENIParam{InstanceID: "i-00000002", DeviceIndex: DeviceIndexAuto},
+		InterfaceIDs: []string{a, b},
+		Concurrency:  1,
+		Atomic:       true,
+	}, &WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+	assert.NoError(t, err)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, OutcomeFailed, results[0].Outcome)
+		var rollbackErr *RollbackError
+		if assert.True(t, errors.As(results[0].Err, &rollbackErr)) {
+			var alreadyAttached *AlreadyAttachedError
+			if assert.True(t, errors.As(rollbackErr.RollbackErr, &alreadyAttached)) {
+				assert.Equal(t, "i-00000003", alreadyAttached.InstanceID)
+			}
+		}
+		assert.Equal(t, OutcomeFailed, results[1].Outcome)
+		assert.Equal(t, attachErr, results[1].Err)
+	}
+	mockEC2.AssertNotCalled(t, "DetachNetworkInterfaceWithContext", mock.Anything, mock.Anything)
+	mockEC2.AssertExpectations(t)
+}
+
+func TestGrabENIsAtomicOverENILimit(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	out := describeInstanceOutput("i-00000002", 0)
+	out.Reservations[0].Instances[0].InstanceType = aws.String("t3.micro")
+	mockDescribeInstance(mockEC2, out)
+	mockEC2.On("DescribeInstanceTypesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).Run(
+		returnPages(describeInstanceTypesOutput("t3.micro", 2))).Return(nil).Once()
+	mockDescribeENISequence(mockEC2, "eni-0000000a", describeENIAtOutput("eni-0000000a", "", 0))
+	mockDescribeENISequence(mockEC2, "eni-0000000b", describeENIAtOutput("eni-0000000b", "", 0))
+
+	results, err := c.GrabENIsWithContext(context.Background(), &GrabENIsParam{
+		GrabENIParam: GrabENIParam{InstanceID: "i-00000002", DeviceIndex: DeviceIndexAuto},
+		InterfaceIDs: []string{"eni-0000000a", "eni-0000000b"},
+		Atomic:       true,
+	}, &WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+	assert.NoError(t, err)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, OutcomeAborted, results[0].Outcome)
+		assert.Equal(t, OutcomeFailed, results[1].Outcome)
+		var limitErr *ENILimitExceededError
+		assert.True(t, errors.As(results[1].Err, &limitErr))
+	}
+	mockEC2.AssertNotCalled(t, "AttachNetworkInterfaceWithContext", mock.Anything, mock.Anything)
+	mockEC2.AssertExpectations(t)
+}
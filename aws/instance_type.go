@@ -0,0 +1,133 @@
+package aws
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/yuuki/grabeni/aws/model"
+)
+
+// The maximum number of instance types per DescribeInstanceTypes request
+const describeInstanceTypesBatchSize = 100
+
+// describeInstanceTypes returns the info of the instance types by type name.
+// The info is cached for the lifetime of the client because it never changes.
+func (c *ENIClient) describeInstanceTypes(ctx context.Context, instanceTypes []string) (map[string]*ec2.InstanceTypeInfo, error) {
+	c.instanceTypesMu.Lock()
+	defer c.instanceTypesMu.Unlock()
+
+	if c.instanceTypes == nil {
+		c.instanceTypes = make(map[string]*ec2.InstanceTypeInfo)
+	}
+
+	missing := make([]string, 0, len(instanceTypes))
+	for _, t := range uniqueStrings(instanceTypes) {
+		if _, ok := c.instanceTypes[t]; !ok && t != "" {
+			missing = append(missing, t)
+		}
+	}
+
+	for _, batch := range chunkStrings(missing, describeInstanceTypesBatchSize) {
+		input := &ec2.DescribeInstanceTypesInput{
+			InstanceTypes: aws.StringSlice(batch),
+		}
+		err := c.svc.DescribeInstanceTypesPagesWithContext(ctx, input, func(page *ec2.DescribeInstanceTypesOutput, lastPage bool) bool {
+			for _, info := range page.InstanceTypes {
+				c.instanceTypes[aws.StringValue(info.InstanceType)] = info
+			}
+			return true
+		})
+		if err != nil {
+			return nil, wrapContextError(ctx, "describe", "instance types", err)
+		}
+	}
+
+	infos := make(map[string]*ec2.InstanceTypeInfo, len(instanceTypes))
+	for _, t := range instanceTypes {
+		if info := c.instanceTypes[t]; info != nil {
+			infos[t] = info
+		}
+	}
+	return infos, nil
+}
+
+// SetInstanceTypesWithContext sets the info of their instance types to the instances.
+func (c *ENIClient) SetInstanceTypesWithContext(ctx context.Context, instances []*model.Instance) error {
+	types := make([]string, 0, len(instances))
+	for _, i := range instances {
+		types = append(types, i.Type())
+	}
+
+	infos, err := c.describeInstanceTypes(ctx, types)
+	if err != nil {
+		return err
+	}
+
+	for _, i := range instances {
+		if info := infos[i.Type()]; info != nil {
+			i.SetTypeInfo(info)
+		}
+	}
+	return nil
+}
+
+// describeInstanceWithType describes the instance along with its instance type.
+func (c *ENIClient) describeInstanceWithType(ctx context.Context, instanceID string) (*model.Instance, error) {
+	instance, err := c.DescribeInstanceByIDWithContext(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.SetInstanceTypesWithContext(ctx, []*model.Instance{instance}); err != nil {
+		return nil, err
+	}
+	return instance, nil
+}
+
+// checkENILimit refuses an attachment to the instance that would exceed the
+// maximum number of ENIs of its instance type.
+func checkENILimit(instance *model.Instance) error {
+	if instance.ENILimitReached() {
+		return &ENILimitExceededError{
+			InstanceID:   instance.InstanceID(),
+			InstanceType: instance.Type(),
+			Max:          instance.MaxNetworkInterfaces(),
+		}
+	}
+	return nil
+}
+
+func (c *ENIClient) DescribeInstances() ([]*model.Instance, error) {
+	return c.DescribeInstancesWithContext(context.Background())
+}
+
+// DescribeInstancesWithContext describes all the instances but terminated
+// ones, along with their instance types.
+func (c *ENIClient) DescribeInstancesWithContext(ctx context.Context) ([]*model.Instance, error) {
+	input := &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{{
+			Name:   aws.String("instance-state-name"),
+			Values: aws.StringSlice([]string{"pending", "running", "stopping", "stopped"}),
+		}},
+	}
+
+	instances := make([]*model.Instance, 0)
+	err := c.svc.DescribeInstancesPagesWithContext(ctx, input, func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+		for _, r := range page.Reservations {
+			for _, i := range r.Instances {
+				instances = append(instances, model.NewInstance(i))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, wrapContextError(ctx, "describe", "instances", err)
+	}
+
+	if err := c.SetInstanceTypesWithContext(ctx, instances); err != nil {
+		return nil, err
+	}
+
+	return instances, nil
+}
@@ -0,0 +1,104 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/yuuki/grabeni/aws/model"
+)
+
+func describeInstanceTypesOutput(instanceType string, maxENIs int64) *ec2.DescribeInstanceTypesOutput {
+	return &ec2.DescribeInstanceTypesOutput{
+		InstanceTypes: []*ec2.InstanceTypeInfo{{
+			InstanceType: aws.String(instanceType),
+			NetworkInfo: &ec2.NetworkInfo{
+				MaximumNetworkInterfaces: aws.Int64(maxENIs),
+				MaximumNetworkCards:      aws.Int64(1),
+			},
+		}},
+	}
+}
+
+func TestSetInstanceTypesCached(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockEC2.On("DescribeInstanceTypesPagesWithContext", mock.Anything, &ec2.DescribeInstanceTypesInput{
+		InstanceTypes: []*string{aws.String("t3.micro")},
+	}, mock.Anything).Run(returnPages(describeInstanceTypesOutput("t3.micro", 2))).Return(nil).Once()
+
+	for i := 0; i < 2; i++ {
+		instances := []*model.Instance{
+			model.NewInstance(&ec2.Instance{InstanceType: aws.String("t3.micro")}),
+			model.NewInstance(&ec2.Instance{InstanceType: aws.String("t3.micro")}),
+			model.NewInstance(&ec2.Instance{}),
+		}
+
+		err := c.SetInstanceTypesWithContext(context.Background(), instances)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), instances[0].MaxNetworkInterfaces())
+		assert.Equal(t, int64(2), instances[1].MaxNetworkInterfaces())
+		assert.Equal(t, int64(0), instances[2].MaxNetworkInterfaces())
+	}
+	mockEC2.AssertExpectations(t)
+}
+
+func TestGrabENILimitExceeded(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, mock.Anything).Return(
+		describeENIOutput("in-use", "attached", "i-00000001"), nil)
+	mockEC2.On("DescribeInstancesWithContext", mock.Anything, &ec2.DescribeInstancesInput{
+		InstanceIds: []*string{aws.String("i-00000001")},
+	}).Return(describeInstanceOutput("i-00000001", 0, 1), nil)
+
+	target := describeInstanceOutput("i-00000002", 0, 1)
+	target.Reservations[0].Instances[0].InstanceType = aws.String("t3.micro")
+	mockEC2.On("DescribeInstancesWithContext", mock.Anything, &ec2.DescribeInstancesInput{
+		InstanceIds: []*string{aws.String("i-00000002")},
+	}).Return(target, nil)
+	mockEC2.On("DescribeInstanceTypesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).Run(
+		returnPages(describeInstanceTypesOutput("t3.micro", 2))).Return(nil)
+
+	eni, err := c.GrabENIWithContext(context.Background(), &GrabENIParam{
+		InterfaceID: "eni-00000001",
+		InstanceID:  "i-00000002",
+		DeviceIndex: 1,
+	}, &WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+	assert.Nil(t, eni)
+	assert.EqualError(t, err, "instance i-00000002 (t3.micro) already has the maximum 2 ENIs attached")
+	var limitExceeded *ENILimitExceededError
+	assert.True(t, errors.As(err, &limitExceeded))
+	mockEC2.AssertNotCalled(t, "DetachNetworkInterfaceWithContext", mock.Anything, mock.Anything)
+}
+
+func TestDescribeInstances(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	page := describeInstanceOutput("i-00000001", 0, 1)
+	page.Reservations[0].Instances[0].InstanceType = aws.String("c5.large")
+	mockEC2.On("DescribeInstancesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).Run(
+		returnPages(page)).Return(nil)
+	mockEC2.On("DescribeInstanceTypesPagesWithContext", mock.Anything, &ec2.DescribeInstanceTypesInput{
+		InstanceTypes: []*string{aws.String("c5.large")},
+	}, mock.Anything).Run(returnPages(describeInstanceTypesOutput("c5.large", 3))).Return(nil)
+
+	instances, err := c.DescribeInstances()
+
+	assert.NoError(t, err)
+	if assert.Len(t, instances, 1) {
+		assert.Equal(t, "i-00000001", instances[0].InstanceID())
+		assert.Equal(t, int64(3), instances[0].MaxNetworkInterfaces())
+	}
+}
@@ -0,0 +1,147 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/yuuki/grabeni/aws/model"
+)
+
+type GrabPrivateIPParam struct {
+	PrivateIP  string
+	InstanceID string
+	// InterfaceID is the ENI of InstanceID to move the IP to. The primary ENI
+	// of the instance is chosen if empty.
+	InterfaceID string
+}
+
+// DescribeENIByPrivateIPWithContext returns the ENI in the VPC that holds ip
+// as its primary or secondary private IP, or nil if no ENI holds it.
+func (c *ENIClient) DescribeENIByPrivateIPWithContext(ctx context.Context, vpcID, ip string) (*model.ENI, error) {
+	resp, err := c.svc.DescribeNetworkInterfacesWithContext(ctx, &ec2.DescribeNetworkInterfacesInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String("vpc-id"), Values: aws.StringSlice([]string{vpcID})},
+			{Name: aws.String("addresses.private-ip-address"), Values: aws.StringSlice([]string{ip})},
+		},
+	})
+	if err != nil {
+		return nil, wrapContextError(ctx, "describe", ip, err)
+	}
+
+	switch len(resp.NetworkInterfaces) {
+	case 0:
+		return nil, nil
+	case 1:
+		return model.NewENI(resp.NetworkInterfaces[0]), nil
+	}
+	return nil, fmt.Errorf("%s is held by %d ENIs", ip, len(resp.NetworkInterfaces))
+}
+
+func (c *ENIClient) GrabPrivateIP(p *GrabPrivateIPParam, wp *WaiterParam) (*model.ENI, error) {
+	return c.GrabPrivateIPWithContext(context.Background(), p, wp)
+}
+
+// GrabPrivateIPWithContext moves the secondary private IP from the ENI holding
+// it to the ENI of the instance, and waits until the move is visible. It
+// returns nil without error if the ENI already holds the IP.
+func (c *ENIClient) GrabPrivateIPWithContext(ctx context.Context, p *GrabPrivateIPParam, wp *WaiterParam) (*model.ENI, error) {
+	if err := validateWaitUntilParam(wp); err != nil {
+		return nil, err
+	}
+	ctx, cancel := withOpTimeout(ctx, wp)
+	defer cancel()
+
+	instance, err := c.DescribeInstanceByIDWithContext(ctx, p.InstanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	interfaceID := p.InterfaceID
+	if interfaceID == "" {
+		interfaceID = instance.NetworkInterfaceIDAt(0)
+	} else if !instance.HasNetworkInterface(interfaceID) {
+		return nil, fmt.Errorf("%s is not attached to instance %s", interfaceID, p.InstanceID)
+	}
+
+	holder, err := c.DescribeENIByPrivateIPWithContext(ctx, instance.VpcID(), p.PrivateIP)
+	if err != nil {
+		return nil, err
+	}
+
+	if holder != nil {
+		// Do nothing if the target ENI already holds the IP
+		if holder.InterfaceID() == interfaceID {
+			return nil, nil
+		}
+		if holder.PrivateIpAddress() == p.PrivateIP {
+			return nil, &PrimaryPrivateIPError{PrivateIP: p.PrivateIP, InterfaceID: holder.InterfaceID()}
+		}
+	}
+
+	_, err = c.svc.AssignPrivateIpAddressesWithContext(ctx, &ec2.AssignPrivateIpAddressesInput{
+		NetworkInterfaceId: aws.String(interfaceID),
+		PrivateIpAddresses: aws.StringSlice([]string{p.PrivateIP}),
+		AllowReassignment:  aws.Bool(true),
+	})
+	if err != nil {
+		return nil, wrapContextError(ctx, "assign", p.PrivateIP, translateAPIError(err, interfaceID, ""))
+	}
+
+	c.logger.Printf("--> Assigning: %15s to %s\n", p.PrivateIP, interfaceID)
+
+	// Wait until the target ENI holds the IP and the previous holder has let it go
+	var eni *model.ENI
+	unassigned := false
+	err = c.waitUntil(ctx, "assign", p.PrivateIP, wp, func(ctx context.Context) (bool, error) {
+		var err error
+		if eni, err = c.DescribeENIByIDWithContext(ctx, interfaceID); err != nil {
+			return false, err
+		}
+		if !eni.HasPrivateIP(p.PrivateIP) {
+			return false, nil
+		}
+		if holder == nil {
+			return true, nil
+		}
+
+		prev, err := c.DescribeENIByIDWithContext(ctx, holder.InterfaceID())
+		if err != nil {
+			return false, err
+		}
+		if !prev.HasPrivateIP(p.PrivateIP) {
+			return true, nil
+		}
+
+		// Reassignment normally takes the IP away from the previous holder
+		if !unassigned {
+			if err := c.unassignPrivateIP(ctx, holder.InterfaceID(), p.PrivateIP); err != nil {
+				return false, err
+			}
+			unassigned = true
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.logger.Printf("--> Assigned: %15s to %s\n", p.PrivateIP, interfaceID)
+	return eni, nil
+}
+
+func (c *ENIClient) unassignPrivateIP(ctx context.Context, interfaceID, ip string) error {
+	c.logger.Println()
+	c.logger.Printf("--> Unassigning: %15s from %s\n", ip, interfaceID)
+
+	_, err := c.svc.UnassignPrivateIpAddressesWithContext(ctx, &ec2.UnassignPrivateIpAddressesInput{
+		NetworkInterfaceId: aws.String(interfaceID),
+		PrivateIpAddresses: aws.StringSlice([]string{ip}),
+	})
+	if err != nil {
+		return wrapContextError(ctx, "unassign", ip, translateAPIError(err, interfaceID, ""))
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Build a DescribeNetworkInterfaces response for an unattached ENI holding ips
+func describeENIWithIPsOutput(interfaceID string, ips ...string) *ec2.DescribeNetworkInterfacesOutput {
+	iface := &ec2.NetworkInterface{
+		NetworkInterfaceId: aws.String(interfaceID),
+		Status:             aws.String("available"),
+	}
+	for i, ip := range ips {
+		if i == 0 {
+			iface.PrivateIpAddress = aws.String(ip)
+		}
+		iface.PrivateIpAddresses = append(iface.PrivateIpAddresses, &ec2.NetworkInterfacePrivateIpAddress{
+			PrivateIpAddress: aws.String(ip),
+			Primary:          aws.Bool(i == 0),
+		})
+	}
+	return &ec2.DescribeNetworkInterfacesOutput{
+		NetworkInterfaces: []*ec2.NetworkInterface{iface},
+	}
+}
+
+func mockTargetInstance(mockEC2 *EC2API) {
+	mockEC2.On("DescribeInstancesWithContext", mock.Anything, &ec2.DescribeInstancesInput{
+		InstanceIds: []*string{aws.String("i-00000002")},
+	}).Return(&ec2.DescribeInstancesOutput{
+		Reservations: []*ec2.Reservation{{
+			Instances: []*ec2.Instance{{
+				InstanceId: aws.String("i-00000002"),
+				VpcId:      aws.String("vpc-00000001"),
+				NetworkInterfaces: []*ec2.InstanceNetworkInterface{{
+					NetworkInterfaceId: aws.String("eni-00000002"),
+					Attachment:         &ec2.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int64(0)},
+				}},
+			}},
+		}},
+	}, nil)
+}
+
+func holderInput(ip string) *ec2.DescribeNetworkInterfacesInput {
+	return &ec2.DescribeNetworkInterfacesInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String("vpc-id"), Values: []*string{aws.String("vpc-00000001")}},
+			{Name: aws.String("addresses.private-ip-address"), Values: []*string{aws.String(ip)}},
+		},
+	}
+}
+
+func eniInput(interfaceID string) *ec2.DescribeNetworkInterfacesInput {
+	return &ec2.DescribeNetworkInterfacesInput{
+		NetworkInterfaceIds: []*string{aws.String(interfaceID)},
+	}
+}
+
+func TestGrabPrivateIP(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockTargetInstance(mockEC2)
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, holderInput("10.0.0.10")).Return(
+		describeENIWithIPsOutput("eni-00000001", "10.0.0.1", "10.0.0.10"), nil)
+	mockEC2.On("AssignPrivateIpAddressesWithContext", mock.Anything, &ec2.AssignPrivateIpAddressesInput{
+		NetworkInterfaceId: aws.String("eni-00000002"),
+		PrivateIpAddresses: []*string{aws.String("10.0.0.10")},
+		AllowReassignment:  aws.Bool(true),
+	}).Return(&ec2.AssignPrivateIpAddressesOutput{}, nil).Once()
+
+	// Not moved yet, then moved but still listed by the previous holder, then gone
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, eniInput("eni-00000002")).Return(
+		describeENIWithIPsOutput("eni-00000002", "10.0.0.2"), nil).Once()
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, eniInput("eni-00000002")).Return(
+		describeENIWithIPsOutput("eni-00000002", "10.0.0.2", "10.0.0.10"), nil).Twice()
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, eniInput("eni-00000001")).Return(
+		describeENIWithIPsOutput("eni-00000001", "10.0.0.1", "10.0.0.10"), nil).Once()
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, eniInput("eni-00000001")).Return(
+		describeENIWithIPsOutput("eni-00000001", "10.0.0.1"), nil).Once()
+	mockEC2.On("UnassignPrivateIpAddressesWithContext", mock.Anything, &ec2.UnassignPrivateIpAddressesInput{
+		NetworkInterfaceId: aws.String("eni-00000001"),
+		PrivateIpAddresses: []*string{aws.String("10.0.0.10")},
+	}).Return(&ec2.UnassignPrivateIpAddressesOutput{}, nil).Once()
+
+	eni, err := c.GrabPrivateIPWithContext(context.Background(), &GrabPrivateIPParam{
+		PrivateIP:  "10.0.0.10",
+		InstanceID: "i-00000002",
+	}, &WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, eni) {
+		assert.True(t, eni.HasPrivateIP("10.0.0.10"))
+	}
+	mockEC2.AssertExpectations(t)
+}
+
+func TestGrabPrivateIPAlreadyHeld(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockTargetInstance(mockEC2)
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, holderInput("10.0.0.10")).Return(
+		describeENIWithIPsOutput("eni-00000002", "10.0.0.2", "10.0.0.10"), nil)
+
+	eni, err := c.GrabPrivateIPWithContext(context.Background(), &GrabPrivateIPParam{
+		PrivateIP:  "10.0.0.10",
+		InstanceID: "i-00000002",
+	}, &WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+	assert.NoError(t, err)
+	assert.Nil(t, eni)
+	mockEC2.AssertNotCalled(t, "AssignPrivateIpAddressesWithContext", mock.Anything, mock.Anything)
+}
+
+func TestGrabPrivateIPPrimary(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockTargetInstance(mockEC2)
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, holderInput("10.0.0.1")).Return(
+		describeENIWithIPsOutput("eni-00000001", "10.0.0.1"), nil)
+
+	_, err := c.GrabPrivateIPWithContext(context.Background(), &GrabPrivateIPParam{
+		PrivateIP:  "10.0.0.1",
+		InstanceID: "i-00000002",
+	}, &WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+	var primary *PrimaryPrivateIPError
+	assert.True(t, errors.As(err, &primary))
+	mockEC2.AssertNotCalled(t, "AssignPrivateIpAddressesWithContext", mock.Anything, mock.Anything)
+}
+
+func TestGrabPrivateIPNotAttached(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockTargetInstance(mockEC2)
+
+	_, err := c.GrabPrivateIPWithContext(context.Background(), &GrabPrivateIPParam{
+		PrivateIP:   "10.0.0.10",
+		InstanceID:  "i-00000002",
+		InterfaceID: "eni-00000003",
+	}, &WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+	assert.EqualError(t, err, "eni-00000003 is not attached to instance i-00000002")
+}
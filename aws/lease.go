@@ -0,0 +1,210 @@
+package aws
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/yuuki/grabeni/aws/model"
+	"github.com/yuuki/grabeni/timeutil"
+)
+
+// Tags storing the lease of an ENI
+const (
+	LeaseOwnerTag  = "grabeni:lease-owner"
+	LeaseExpiryTag = "grabeni:lease-expiry"
+	LeaseTokenTag  = "grabeni:lease-token"
+)
+
+// DefaultLeaseSettleDelay is long enough for concurrent writes of a lease to
+// settle, so that reading the lease back tells which of them has won.
+const DefaultLeaseSettleDelay = 2 * time.Second
+
+// Lease is the ownership of an ENI stored in its tags, which serializes the
+// instances grabbing the ENI at the same time. The expiry is compared with the
+// local clock, so the clocks of the instances must agree well within the TTL.
+type Lease struct {
+	InterfaceID string
+	// Owner is the instance holding the lease, empty if none does.
+	Owner  string
+	Expiry time.Time
+	// Token increases with every acquisition of the lease and survives its
+	// release, so that a holder can tell that the lease has been taken over
+	// in the meantime.
+	Token int64
+}
+
+// HeldByOther reports whether an instance other than owner holds the lease
+// at now.
+func (l *Lease) HeldByOther(owner string, now time.Time) bool {
+	return l.Owner != "" && l.Owner != owner && now.Before(l.Expiry)
+}
+
+type LeaseParam struct {
+	InterfaceID string
+	// Owner is the instance to take the lease for.
+	Owner string
+	TTL   time.Duration
+	// SettleDelay is waited after writing the lease and before reading it back
+	// to verify that no concurrent writer has overwritten it.
+	SettleDelay time.Duration
+	// Steal takes the lease even if another instance holds it.
+	Steal bool
+}
+
+// leaseOf reads the lease from the tags of the ENI. Malformed tags are read
+// as no lease, which any instance may take.
+func leaseOf(eni *model.ENI) *Lease {
+	lease := &Lease{InterfaceID: eni.InterfaceID(), Owner: eni.Tag(LeaseOwnerTag)}
+	expiry, err := time.Parse(time.RFC3339Nano, eni.Tag(LeaseExpiryTag))
+	if err != nil {
+		lease.Owner = ""
+	}
+	lease.Expiry = expiry
+	lease.Token, _ = strconv.ParseInt(eni.Tag(LeaseTokenTag), 10, 64)
+	return lease
+}
+
+func (c *ENIClient) DescribeLease(interfaceID string) (*Lease, error) {
+	return c.DescribeLeaseWithContext(context.Background(), interfaceID)
+}
+
+// DescribeLeaseWithContext reads the lease of the ENI, whose Owner is empty
+// if the ENI has never been leased or has been released.
+func (c *ENIClient) DescribeLeaseWithContext(ctx context.Context, interfaceID string) (*Lease, error) {
+	resp, err := c.svc.DescribeNetworkInterfacesWithContext(ctx, &ec2.DescribeNetworkInterfacesInput{
+		NetworkInterfaceIds: []*string{aws.String(interfaceID)},
+	})
+	if err != nil {
+		return nil, wrapContextError(ctx, "describe lease", interfaceID, translateAPIError(err, interfaceID, ""))
+	}
+	if len(resp.NetworkInterfaces) < 1 {
+		return nil, &ENINotFoundError{InterfaceID: interfaceID}
+	}
+	return leaseOf(model.NewENI(resp.NetworkInterfaces[0])), nil
+}
+
+func (c *ENIClient) writeLease(ctx context.Context, lease *Lease) error {
+	_, err := c.svc.CreateTagsWithContext(ctx, &ec2.CreateTagsInput{
+		Resources: []*string{aws.String(lease.InterfaceID)},
+		Tags: []*ec2.Tag{
+			{Key: aws.String(LeaseOwnerTag), Value: aws.String(lease.Owner)},
+			{Key: aws.String(LeaseExpiryTag), Value: aws.String(lease.Expiry.UTC().Format(time.RFC3339Nano))},
+			{Key: aws.String(LeaseTokenTag), Value: aws.String(strconv.FormatInt(lease.Token, 10))},
+		},
+	})
+	if err != nil {
+		return wrapContextError(ctx, "lease", lease.InterfaceID, translateAPIError(err, lease.InterfaceID, ""))
+	}
+	return nil
+}
+
+func (c *ENIClient) AcquireLease(p *LeaseParam) (*Lease, error) {
+	return c.AcquireLeaseWithContext(context.Background(), p)
+}
+
+// AcquireLeaseWithContext takes the lease of the ENI for p.Owner for p.TTL. It
+// returns a LeaseHeldError if another instance holds the lease, unless
+// p.Steal is set, or if another instance has overwritten the lease within
+// p.SettleDelay.
+func (c *ENIClient) AcquireLeaseWithContext(ctx context.Context, p *LeaseParam) (*Lease, error) {
+	current, err := c.DescribeLeaseWithContext(ctx, p.InterfaceID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if current.HeldByOther(p.Owner, now) {
+		if !p.Steal {
+			return nil, &LeaseHeldError{InterfaceID: p.InterfaceID, Owner: current.Owner, Expiry: current.Expiry}
+		}
+		c.logger.Printf("--> Stealing lease: %15s from %s\n", p.InterfaceID, current.Owner)
+	}
+
+	lease := &Lease{
+		InterfaceID: p.InterfaceID,
+		Owner:       p.Owner,
+		Expiry:      now.Add(p.TTL),
+		Token:       current.Token + 1,
+	}
+	if err := c.writeLease(ctx, lease); err != nil {
+		return nil, err
+	}
+
+	// The last of concurrent writers wins, which only reading back tells
+	if err := timeutil.SleepContext(ctx, p.SettleDelay); err != nil {
+		return nil, wrapContextError(ctx, "lease", p.InterfaceID, err)
+	}
+	written, err := c.DescribeLeaseWithContext(ctx, p.InterfaceID)
+	if err != nil {
+		return nil, err
+	}
+	if written.Owner != lease.Owner || written.Token != lease.Token {
+		return nil, &LeaseHeldError{InterfaceID: p.InterfaceID, Owner: written.Owner, Expiry: written.Expiry}
+	}
+
+	c.logger.Printf("--> Leased: %15s (token %d, until %s)\n", p.InterfaceID, lease.Token, lease.Expiry.Format(time.RFC3339))
+	return lease, nil
+}
+
+func (c *ENIClient) RenewLease(p *LeaseParam) (*Lease, error) {
+	return c.RenewLeaseWithContext(context.Background(), p)
+}
+
+// RenewLeaseWithContext extends the lease held by p.Owner to p.TTL from now,
+// keeping its token. A lease that is missing or has expired is acquired
+// afresh as AcquireLeaseWithContext does, and a LeaseLostError is returned if
+// another instance holds the lease.
+func (c *ENIClient) RenewLeaseWithContext(ctx context.Context, p *LeaseParam) (*Lease, error) {
+	lease, err := c.DescribeLeaseWithContext(ctx, p.InterfaceID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if lease.HeldByOther(p.Owner, now) {
+		return nil, &LeaseLostError{InterfaceID: p.InterfaceID, Owner: lease.Owner}
+	}
+	if lease.Owner != p.Owner {
+		acquire := *p
+		acquire.Steal = false
+		return c.AcquireLeaseWithContext(ctx, &acquire)
+	}
+
+	lease.Expiry = now.Add(p.TTL)
+	if err := c.writeLease(ctx, lease); err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+func (c *ENIClient) ReleaseLease(interfaceID, owner string) error {
+	return c.ReleaseLeaseWithContext(context.Background(), interfaceID, owner)
+}
+
+// ReleaseLeaseWithContext removes the lease if owner holds it, and leaves the
+// lease of another instance alone. The token is kept, so that the next
+// acquisition continues from it.
+func (c *ENIClient) ReleaseLeaseWithContext(ctx context.Context, interfaceID, owner string) error {
+	lease, err := c.DescribeLeaseWithContext(ctx, interfaceID)
+	if err != nil {
+		return err
+	}
+	if lease.Owner != owner {
+		return nil
+	}
+
+	_, err = c.svc.DeleteTagsWithContext(ctx, &ec2.DeleteTagsInput{
+		Resources: []*string{aws.String(interfaceID)},
+		Tags: []*ec2.Tag{
+			{Key: aws.String(LeaseOwnerTag)},
+			{Key: aws.String(LeaseExpiryTag)},
+		},
+	})
+	if err != nil {
+		return wrapContextError(ctx, "release lease", interfaceID, translateAPIError(err, interfaceID, ""))
+	}
+	c.logger.Printf("--> Released lease: %15s\n", interfaceID)
+	return nil
+}
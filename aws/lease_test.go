@@ -0,0 +1,290 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// leaseOutput returns eni-00000001 leased by owner, or not leased if owner is
+// empty. The token of a released lease is left if given.
+func leaseOutput(owner string, expiry time.Time, token string) *ec2.DescribeNetworkInterfacesOutput {
+	iface := &ec2.NetworkInterface{
+		NetworkInterfaceId: aws.String("eni-00000001"),
+		Status:             aws.String("available"),
+	}
+	if owner != "" {
+		iface.TagSet = []*ec2.Tag{
+			{Key: aws.String(LeaseOwnerTag), Value: aws.String(owner)},
+			{Key: aws.String(LeaseExpiryTag), Value: aws.String(expiry.UTC().Format(time.RFC3339Nano))},
+		}
+	}
+	if token != "" {
+		iface.TagSet = append(iface.TagSet, &ec2.Tag{Key: aws.String(LeaseTokenTag), Value: aws.String(token)})
+	}
+	return &ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: []*ec2.NetworkInterface{iface}}
+}
+
+func mockDescribeLease(m *EC2API, outputs ...*ec2.DescribeNetworkInterfacesOutput) {
+	for _, out := range outputs {
+		m.On("DescribeNetworkInterfacesWithContext", mock.Anything, &ec2.DescribeNetworkInterfacesInput{
+			NetworkInterfaceIds: []*string{aws.String("eni-00000001")},
+		}).Return(out, nil).Once()
+	}
+}
+
+// mockWriteLease expects the lease of eni-00000001 to be written for owner with token.
+func mockWriteLease(m *EC2API, owner, token string) {
+	m.On("CreateTagsWithContext", mock.Anything, mock.MatchedBy(func(in *ec2.CreateTagsInput) bool {
+		return *in.Resources[0] == "eni-00000001" && len(in.Tags) == 3 &&
+			*in.Tags[0].Key == LeaseOwnerTag && *in.Tags[0].Value == owner &&
+			*in.Tags[2].Key == LeaseTokenTag && *in.Tags[2].Value == token
+	})).Return(&ec2.CreateTagsOutput{}, nil).Once()
+}
+
+func TestAcquireLease(t *testing.T) {
+	expiry := time.Now().Add(time.Minute)
+
+	tests := []struct {
+		name     string
+		current  *ec2.DescribeNetworkInterfacesOutput
+		steal    bool
+		written  *ec2.DescribeNetworkInterfacesOutput
+		token    string
+		heldBy   string
+		expected int64
+	}{
+		{
+			name:     "not leased",
+			current:  leaseOutput("", time.Time{}, ""),
+			token:    "1",
+			written:  leaseOutput("i-00000002", expiry, "1"),
+			expected: 1,
+		},
+		{
+			name:     "expired",
+			current:  leaseOutput("i-00000001", time.Now().Add(-time.Second), "4"),
+			token:    "5",
+			written:  leaseOutput("i-00000002", expiry, "5"),
+			expected: 5,
+		},
+		{
+			name:    "held",
+			current: leaseOutput("i-00000001", expiry, "4"),
+			heldBy:  "i-00000001",
+		},
+		{
+			name:     "steal",
+			current:  leaseOutput("i-00000001", expiry, "4"),
+			steal:    true,
+			token:    "5",
+			written:  leaseOutput("i-00000002", expiry, "5"),
+			expected: 5,
+		},
+		{
+			name:    "lost race",
+			current: leaseOutput("", time.Time{}, ""),
+			token:   "1",
+			written: leaseOutput("i-00000003", expiry, "1"),
+			heldBy:  "i-00000003",
+		},
+	}
+
+	for _, tt := range tests {
+		mockEC2 := new(EC2API)
+		c := newClient(mockEC2)
+
+		mockDescribeLease(mockEC2, tt.current)
+		if tt.written != nil {
+			mockWriteLease(mockEC2, "i-00000002", tt.token)
+			mockDescribeLease(mockEC2, tt.written)
+		}
+
+		lease, err := c.AcquireLeaseWithContext(context.Background(), &LeaseParam{
+			InterfaceID: "eni-00000001",
+			Owner:       "i-00000002",
+			TTL:         time.Minute,
+			SettleDelay: time.Millisecond,
+			Steal:       tt.steal,
+		})
+
+		if tt.heldBy != "" {
+			var held *LeaseHeldError
+			if assert.True(t, errors.As(err, &held), tt.name) {
+				assert.Equal(t, tt.heldBy, held.Owner, tt.name)
+			}
+		} else if assert.NoError(t, err, tt.name) {
+			assert.Equal(t, "i-00000002", lease.Owner, tt.name)
+			assert.Equal(t, tt.expected, lease.Token, tt.name)
+		}
+		mockEC2.AssertExpectations(t)
+	}
+}
+
+func TestRenewLease(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+	p := &LeaseParam{InterfaceID: "eni-00000001", Owner: "i-00000002", TTL: time.Minute, SettleDelay: time.Millisecond}
+
+	mockDescribeLease(mockEC2,
+		leaseOutput("i-00000002", time.Now().Add(time.Second), "7"),
+		leaseOutput("i-00000003", time.Now().Add(time.Minute), "8"),
+	)
+	mockWriteLease(mockEC2, "i-00000002", "7")
+
+	lease, err := c.RenewLeaseWithContext(context.Background(), p)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(7), lease.Token, "token is kept")
+		assert.True(t, lease.Expiry.After(time.Now().Add(50*time.Second)))
+	}
+
+	_, err = c.RenewLeaseWithContext(context.Background(), p)
+	assert.EqualError(t, err, "lease of eni-00000001 has been taken by instance i-00000003")
+	var lost *LeaseLostError
+	assert.True(t, errors.As(err, &lost))
+	mockEC2.AssertExpectations(t)
+}
+
+// TestRenewLeaseAcquire renews a lease that is missing or has expired, which
+// is acquired afresh.
+func TestRenewLeaseAcquire(t *testing.T) {
+	tests := []struct {
+		name    string
+		current *ec2.DescribeNetworkInterfacesOutput
+		token   string
+	}{
+		{name: "missing", current: leaseOutput("", time.Time{}, ""), token: "1"},
+		{name: "expired", current: leaseOutput("i-00000003", time.Now().Add(-time.Second), "8"), token: "9"},
+	}
+
+	for _, tt := range tests {
+		mockEC2 := new(EC2API)
+		c := newClient(mockEC2)
+
+		mockDescribeLease(mockEC2, tt.current, tt.current)
+		mockWriteLease(mockEC2, "i-00000002", tt.token)
+		mockDescribeLease(mockEC2, leaseOutput("i-00000002", time.Now().Add(time.Minute), tt.token))
+
+		lease, err := c.RenewLeaseWithContext(context.Background(), &LeaseParam{
+			InterfaceID: "eni-00000001",
+			Owner:       "i-00000002",
+			TTL:         time.Minute,
+			SettleDelay: time.Millisecond,
+		})
+
+		if assert.NoError(t, err, tt.name) {
+			assert.Equal(t, "i-00000002", lease.Owner, tt.name)
+		}
+		mockEC2.AssertExpectations(t)
+	}
+}
+
+func TestReleaseLease(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockDescribeLease(mockEC2,
+		leaseOutput("i-00000003", time.Now().Add(time.Minute), "8"),
+		leaseOutput("i-00000002", time.Now().Add(time.Minute), "9"),
+	)
+	mockEC2.On("DeleteTagsWithContext", mock.Anything, &ec2.DeleteTagsInput{
+		Resources: []*string{aws.String("eni-00000001")},
+		Tags: []*ec2.Tag{
+			{Key: aws.String(LeaseOwnerTag)},
+			{Key: aws.String(LeaseExpiryTag)},
+		},
+	}).Return(&ec2.DeleteTagsOutput{}, nil).Once()
+
+	// The lease of another instance is left alone
+	assert.NoError(t, c.ReleaseLeaseWithContext(context.Background(), "eni-00000001", "i-00000002"))
+	assert.NoError(t, c.ReleaseLeaseWithContext(context.Background(), "eni-00000001", "i-00000002"))
+	mockEC2.AssertExpectations(t)
+}
+
+func TestLeaseTokenIncreasesOverRelease(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+	p := &LeaseParam{InterfaceID: "eni-00000001", Owner: "i-00000002", TTL: time.Minute}
+	expiry := time.Now().Add(time.Minute)
+
+	mockDescribeLease(mockEC2,
+		leaseOutput("", time.Time{}, ""),
+		leaseOutput("i-00000002", expiry, "1"),
+		leaseOutput("i-00000002", expiry, "1"),
+		leaseOutput("", time.Time{}, "1"),
+		leaseOutput("i-00000002", expiry, "2"),
+	)
+	mockWriteLease(mockEC2, "i-00000002", "1")
+	mockEC2.On("DeleteTagsWithContext", mock.Anything, mock.Anything).Return(&ec2.DeleteTagsOutput{}, nil).Once()
+	mockWriteLease(mockEC2, "i-00000002", "2")
+
+	first, err := c.AcquireLeaseWithContext(context.Background(), p)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(1), first.Token)
+	}
+	assert.NoError(t, c.ReleaseLeaseWithContext(context.Background(), "eni-00000001", "i-00000002"))
+	second, err := c.AcquireLeaseWithContext(context.Background(), p)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(2), second.Token)
+	}
+	mockEC2.AssertExpectations(t)
+}
+
+func TestGrabENILeaseHeld(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, &ec2.DescribeNetworkInterfacesInput{
+		NetworkInterfaceIds: []*string{aws.String("eni-00000001")},
+	}).Return(leaseOutput("i-00000003", time.Now().Add(time.Minute), "3"), nil)
+	mockDescribeInstance(mockEC2, describeInstanceOutput("i-00000002"))
+
+	eni, err := c.GrabENIWithContext(context.Background(), &GrabENIParam{
+		InterfaceID: "eni-00000001",
+		InstanceID:  "i-00000002",
+		DeviceIndex: 1,
+		LeaseTTL:    time.Minute,
+	}, &WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+	assert.Nil(t, eni)
+	var held *LeaseHeldError
+	if assert.True(t, errors.As(err, &held)) {
+		assert.Equal(t, "i-00000003", held.Owner)
+	}
+	mockEC2.AssertNotCalled(t, "CreateTagsWithContext", mock.Anything, mock.Anything)
+	mockEC2.AssertNotCalled(t, "DetachNetworkInterfaceWithContext", mock.Anything, mock.Anything)
+}
+
+// TestGrabENILeaseAlreadyAttached takes the lease of an ENI already attached
+// to the target instance.
+func TestGrabENILeaseAlreadyAttached(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockDescribeENISequence(mockEC2, "eni-00000001",
+		describeENIAtOutput("eni-00000001", "i-00000002", 1),
+		leaseOutput("", time.Time{}, ""),
+	)
+	mockDescribeInstance(mockEC2, describeInstanceOutput("i-00000002", 0, 1))
+	mockWriteLease(mockEC2, "i-00000002", "1")
+	mockDescribeLease(mockEC2, leaseOutput("i-00000002", time.Now().Add(time.Minute), "1"))
+
+	eni, err := c.GrabENIWithContext(context.Background(), &GrabENIParam{
+		InterfaceID:      "eni-00000001",
+		InstanceID:       "i-00000002",
+		DeviceIndex:      1,
+		LeaseTTL:         time.Minute,
+		LeaseSettleDelay: time.Millisecond,
+	}, &WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+	assert.Nil(t, eni)
+	assert.NoError(t, err)
+	mockEC2.AssertExpectations(t)
+	mockEC2.AssertNotCalled(t, "AttachNetworkInterfaceWithContext", mock.Anything, mock.Anything)
+}
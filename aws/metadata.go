@@ -1,6 +1,8 @@
 package aws
 
 import (
+	"context"
+
 	"github.com/aws/aws-sdk-go/aws/ec2metadata"
 	"github.com/aws/aws-sdk-go/aws/session"
 )
@@ -21,6 +23,10 @@ func (c *MetaDataClient) GetInstanceID() (string, error) {
 	return c.svc.GetMetadata("instance-id")
 }
 
+func (c *MetaDataClient) GetInstanceIDWithContext(ctx context.Context) (string, error) {
+	return c.svc.GetMetadataWithContext(ctx, "instance-id")
+}
+
 func (c *MetaDataClient) GetRegion() (string, error) {
 	return c.svc.Region()
 }
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// Address is an Elastic IP.
+type Address struct {
+	addr *ec2.Address
+}
+
+func NewAddress(addr *ec2.Address) *Address {
+	return &Address{addr: addr}
+}
+
+func (a *Address) AllocationID() string {
+	if a.addr.AllocationId != nil {
+		return *a.addr.AllocationId
+	}
+	return ""
+}
+
+func (a *Address) PublicIP() string {
+	if a.addr.PublicIp != nil {
+		return *a.addr.PublicIp
+	}
+	return ""
+}
+
+func (a *Address) AssociationID() string {
+	if a.addr.AssociationId != nil {
+		return *a.addr.AssociationId
+	}
+	return ""
+}
+
+// Associated reports whether the address is associated with any ENI.
+func (a *Address) Associated() bool {
+	return a.AssociationID() != ""
+}
+
+func (a *Address) NetworkInterfaceID() string {
+	if a.addr.NetworkInterfaceId != nil {
+		return *a.addr.NetworkInterfaceId
+	}
+	return ""
+}
+
+func (a *Address) InstanceID() string {
+	if a.addr.InstanceId != nil {
+		return *a.addr.InstanceId
+	}
+	return ""
+}
+
+func (a *Address) PrivateIpAddress() string {
+	if a.addr.PrivateIpAddress != nil {
+		return *a.addr.PrivateIpAddress
+	}
+	return ""
+}
+
+func (a *Address) Name() string {
+	for _, tag := range a.addr.Tags {
+		if tag.Key != nil && *tag.Key == "Name" && tag.Value != nil {
+			return *tag.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddress(t *testing.T) {
+	a := NewAddress(&ec2.Address{
+		AllocationId:       aws.String("eipalloc-00000001"),
+		PublicIp:           aws.String("203.0.113.10"),
+		AssociationId:      aws.String("eipassoc-00000001"),
+		NetworkInterfaceId: aws.String("eni-00000001"),
+		InstanceId:         aws.String("i-00000001"),
+		PrivateIpAddress:   aws.String("10.0.0.10"),
+		Tags: []*ec2.Tag{{
+			Key:   aws.String("Name"),
+			Value: aws.String("vip"),
+		}},
+	})
+
+	assert.Equal(t, "eipalloc-00000001", a.AllocationID())
+	assert.Equal(t, "203.0.113.10", a.PublicIP())
+	assert.Equal(t, "eipassoc-00000001", a.AssociationID())
+	assert.True(t, a.Associated())
+	assert.Equal(t, "eni-00000001", a.NetworkInterfaceID())
+	assert.Equal(t, "i-00000001", a.InstanceID())
+	assert.Equal(t, "10.0.0.10", a.PrivateIpAddress())
+	assert.Equal(t, "vip", a.Name())
+}
+
+func TestAddressNotAssociated(t *testing.T) {
+	a := NewAddress(&ec2.Address{
+		AllocationId: aws.String("eipalloc-00000001"),
+	})
+
+	assert.False(t, a.Associated())
+	assert.Equal(t, "", a.NetworkInterfaceID())
+	assert.Equal(t, "", a.InstanceID())
+	assert.Equal(t, "", a.Name())
+}
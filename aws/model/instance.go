@@ -4,11 +4,19 @@ import (
 	"github.com/aws/aws-sdk-go/service/ec2"
 )
 
-type Instance ec2.Instance
+type Instance struct {
+	ec2.Instance
+	typeInfo *ec2.InstanceTypeInfo
+}
 
 func NewInstance(i *ec2.Instance) *Instance {
-	instance := Instance(*i)
-	return &instance
+	return &Instance{Instance: *i}
+}
+
+// SetTypeInfo sets the information of the instance type, which is not part
+// of the DescribeInstances response.
+func (i *Instance) SetTypeInfo(info *ec2.InstanceTypeInfo) {
+	i.typeInfo = info
 }
 
 func (i *Instance) InstanceID() string {
@@ -25,3 +33,116 @@ func (i *Instance) Name() string {
 	}
 	return ""
 }
+
+func (i *Instance) StateName() string {
+	if i.State != nil && i.State.Name != nil {
+		return *i.State.Name
+	}
+	return ""
+}
+
+func (i *Instance) Type() string {
+	if i.InstanceType != nil {
+		return *i.InstanceType
+	}
+	return ""
+}
+
+func (i *Instance) AvailabilityZone() string {
+	if i.Placement != nil && i.Placement.AvailabilityZone != nil {
+		return *i.Placement.AvailabilityZone
+	}
+	return ""
+}
+
+func (i *Instance) VpcID() string {
+	if i.VpcId != nil {
+		return *i.VpcId
+	}
+	return ""
+}
+
+// PrivateIP returns the primary private IP address of the instance.
+func (i *Instance) PrivateIP() string {
+	if i.PrivateIpAddress != nil {
+		return *i.PrivateIpAddress
+	}
+	return ""
+}
+
+// AttachedDeviceIndexes returns the device indexes of the ENIs attached to the instance.
+func (i *Instance) AttachedDeviceIndexes() []int64 {
+	indexes := make([]int64, 0, len(i.NetworkInterfaces))
+	for _, iface := range i.NetworkInterfaces {
+		if iface.Attachment != nil && iface.Attachment.DeviceIndex != nil {
+			indexes = append(indexes, *iface.Attachment.DeviceIndex)
+		}
+	}
+	return indexes
+}
+
+// NetworkInterfaceIDAt returns the ID of the ENI attached to the instance at
+// deviceIndex, or "" if there is none.
+func (i *Instance) NetworkInterfaceIDAt(deviceIndex int64) string {
+	for _, iface := range i.NetworkInterfaces {
+		if iface.Attachment != nil && iface.Attachment.DeviceIndex != nil && *iface.Attachment.DeviceIndex == deviceIndex && iface.NetworkInterfaceId != nil {
+			return *iface.NetworkInterfaceId
+		}
+	}
+	return ""
+}
+
+// HasNetworkInterface reports whether the ENI is attached to the instance.
+func (i *Instance) HasNetworkInterface(interfaceID string) bool {
+	for _, iface := range i.NetworkInterfaces {
+		if iface.NetworkInterfaceId != nil && *iface.NetworkInterfaceId == interfaceID {
+			return true
+		}
+	}
+	return false
+}
+
+// DeviceIndexInUse reports whether an ENI is attached to the instance at deviceIndex.
+func (i *Instance) DeviceIndexInUse(deviceIndex int64) bool {
+	for _, idx := range i.AttachedDeviceIndexes() {
+		if idx == deviceIndex {
+			return true
+		}
+	}
+	return false
+}
+
+// FreeDeviceIndex returns the lowest device index not in use on the instance,
+// never 0 which is reserved for the primary ENI.
+func (i *Instance) FreeDeviceIndex() int64 {
+	idx := int64(1)
+	for i.DeviceIndexInUse(idx) {
+		idx++
+	}
+	return idx
+}
+
+// MaxNetworkInterfaces returns the maximum number of ENIs of the instance
+// type, or 0 if the type info is not set.
+func (i *Instance) MaxNetworkInterfaces() int64 {
+	if i.typeInfo != nil && i.typeInfo.NetworkInfo != nil && i.typeInfo.NetworkInfo.MaximumNetworkInterfaces != nil {
+		return *i.typeInfo.NetworkInfo.MaximumNetworkInterfaces
+	}
+	return 0
+}
+
+// NetworkCards returns the number of network cards of the instance type, or 0
+// if the type info is not set.
+func (i *Instance) NetworkCards() int64 {
+	if i.typeInfo != nil && i.typeInfo.NetworkInfo != nil && i.typeInfo.NetworkInfo.MaximumNetworkCards != nil {
+		return *i.typeInfo.NetworkInfo.MaximumNetworkCards
+	}
+	return 0
+}
+
+// ENILimitReached reports whether no more ENI can be attached to the instance.
+// It is always false if the type info is not set.
+func (i *Instance) ENILimitReached() bool {
+	max := i.MaxNetworkInterfaces()
+	return max > 0 && int64(len(i.NetworkInterfaces)) >= max
+}
@@ -26,3 +26,140 @@ func TestName(t *testing.T) {
 
 	assert.Equal(t, i.Name(), "grabeni001")
 }
+
+func TestStateName(t *testing.T) {
+	i := NewInstance(&ec2.Instance{
+		State: &ec2.InstanceState{Name: aws.String("running")},
+	})
+
+	assert.Equal(t, "running", i.StateName())
+	assert.Equal(t, "", NewInstance(&ec2.Instance{}).StateName())
+}
+
+func TestPrivateIP(t *testing.T) {
+	i := NewInstance(&ec2.Instance{
+		PrivateIpAddress: aws.String("10.0.0.100"),
+	})
+
+	assert.Equal(t, "10.0.0.100", i.PrivateIP())
+	assert.Equal(t, "", NewInstance(&ec2.Instance{}).PrivateIP())
+}
+
+func TestType(t *testing.T) {
+	i := NewInstance(&ec2.Instance{
+		InstanceType: aws.String("c5.large"),
+	})
+
+	assert.Equal(t, "c5.large", i.Type())
+}
+
+func TestInstanceAvailabilityZone(t *testing.T) {
+	i := NewInstance(&ec2.Instance{
+		Placement: &ec2.Placement{AvailabilityZone: aws.String("ap-northeast-1a")},
+	})
+
+	assert.Equal(t, "ap-northeast-1a", i.AvailabilityZone())
+	assert.Equal(t, "", NewInstance(&ec2.Instance{}).AvailabilityZone())
+}
+
+func TestInstanceVpcID(t *testing.T) {
+	i := NewInstance(&ec2.Instance{
+		VpcId: aws.String("vpc-00000001"),
+	})
+
+	assert.Equal(t, "vpc-00000001", i.VpcID())
+}
+
+func TestAttachedDeviceIndexes(t *testing.T) {
+	i := NewInstance(&ec2.Instance{
+		NetworkInterfaces: []*ec2.InstanceNetworkInterface{
+			{Attachment: &ec2.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int64(0)}},
+			{Attachment: &ec2.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int64(2)}},
+			{Attachment: nil},
+		},
+	})
+
+	assert.Equal(t, []int64{0, 2}, i.AttachedDeviceIndexes())
+}
+
+func TestDeviceIndexInUse(t *testing.T) {
+	i := NewInstance(&ec2.Instance{
+		NetworkInterfaces: []*ec2.InstanceNetworkInterface{
+			{Attachment: &ec2.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int64(0)}},
+			{Attachment: &ec2.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int64(2)}},
+		},
+	})
+
+	assert.True(t, i.DeviceIndexInUse(2))
+	assert.False(t, i.DeviceIndexInUse(1))
+}
+
+func TestFreeDeviceIndex(t *testing.T) {
+	tests := []struct {
+		desc    string
+		indexes []int64
+		want    int64
+	}{
+		{"primary only", []int64{0}, 1},
+		{"gap", []int64{0, 1, 3}, 2},
+		{"contiguous", []int64{0, 2, 1}, 3},
+		{"no interfaces", nil, 1},
+	}
+
+	for _, tt := range tests {
+		ifaces := make([]*ec2.InstanceNetworkInterface, 0, len(tt.indexes))
+		for _, idx := range tt.indexes {
+			ifaces = append(ifaces, &ec2.InstanceNetworkInterface{
+				Attachment: &ec2.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int64(idx)},
+			})
+		}
+		i := NewInstance(&ec2.Instance{NetworkInterfaces: ifaces})
+
+		assert.Equal(t, tt.want, i.FreeDeviceIndex(), tt.desc)
+	}
+}
+
+func TestTypeInfo(t *testing.T) {
+	i := NewInstance(&ec2.Instance{
+		NetworkInterfaces: []*ec2.InstanceNetworkInterface{{}, {}},
+	})
+
+	assert.Equal(t, int64(0), i.MaxNetworkInterfaces())
+	assert.Equal(t, int64(0), i.NetworkCards())
+	assert.False(t, i.ENILimitReached())
+
+	i.SetTypeInfo(&ec2.InstanceTypeInfo{
+		NetworkInfo: &ec2.NetworkInfo{
+			MaximumNetworkInterfaces: aws.Int64(3),
+			MaximumNetworkCards:      aws.Int64(1),
+		},
+	})
+
+	assert.Equal(t, int64(3), i.MaxNetworkInterfaces())
+	assert.Equal(t, int64(1), i.NetworkCards())
+	assert.False(t, i.ENILimitReached())
+
+	i.NetworkInterfaces = append(i.NetworkInterfaces, &ec2.InstanceNetworkInterface{})
+	assert.True(t, i.ENILimitReached())
+}
+
+func TestNetworkInterfaceIDAt(t *testing.T) {
+	i := NewInstance(&ec2.Instance{
+		NetworkInterfaces: []*ec2.InstanceNetworkInterface{
+			{
+				NetworkInterfaceId: aws.String("eni-00000000"),
+				Attachment:         &ec2.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int64(0)},
+			},
+			{
+				NetworkInterfaceId: aws.String("eni-00000001"),
+				Attachment:         &ec2.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int64(1)},
+			},
+		},
+	})
+
+	assert.Equal(t, "eni-00000000", i.NetworkInterfaceIDAt(0))
+	assert.Equal(t, "eni-00000001", i.NetworkInterfaceIDAt(1))
+	assert.Equal(t, "", i.NetworkInterfaceIDAt(2))
+	assert.True(t, i.HasNetworkInterface("eni-00000001"))
+	assert.False(t, i.HasNetworkInterface("eni-00000002"))
+}
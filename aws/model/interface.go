@@ -38,6 +38,27 @@ func (e *ENI) PrivateIpAddress() string {
 	return ""
 }
 
+// PrivateIPAddresses returns the primary and the secondary private IPs of the ENI.
+func (e *ENI) PrivateIPAddresses() []string {
+	ips := make([]string, 0, len(e.iface.PrivateIpAddresses))
+	for _, addr := range e.iface.PrivateIpAddresses {
+		if addr.PrivateIpAddress != nil {
+			ips = append(ips, *addr.PrivateIpAddress)
+		}
+	}
+	return ips
+}
+
+// HasPrivateIP reports whether ip is assigned to the ENI.
+func (e *ENI) HasPrivateIP(ip string) bool {
+	for _, addr := range e.PrivateIPAddresses() {
+		if addr == ip {
+			return true
+		}
+	}
+	return false
+}
+
 func (e *ENI) Status() string {
 	if e.iface.Status != nil {
 		return *e.iface.Status
@@ -66,6 +87,22 @@ func (e *ENI) AttachedStatus() string {
 	return ""
 }
 
+// InTransition reports whether the ENI is being attached or detached.
+func (e *ENI) InTransition() bool {
+	switch e.Status() {
+	case "attaching", "detaching":
+		return true
+	}
+	switch e.AttachedStatus() {
+	case "attaching", "detaching":
+		return true
+	case "detached":
+		// detached but not yet available
+		return e.Status() == "in-use"
+	}
+	return false
+}
+
 func (e *ENI) AttachedInstanceID() string {
 	if e.iface.Attachment != nil && e.iface.Attachment.InstanceId != nil {
 		return *e.iface.Attachment.InstanceId
@@ -84,6 +121,43 @@ func (e *ENI) AvailabilityZone() string {
 	return ""
 }
 
+func (e *ENI) VpcID() string {
+	if e.iface.VpcId != nil {
+		return *e.iface.VpcId
+	}
+	return ""
+}
+
+func (e *ENI) SubnetID() string {
+	if e.iface.SubnetId != nil {
+		return *e.iface.SubnetId
+	}
+	return ""
+}
+
+func (e *ENI) InterfaceType() string {
+	if e.iface.InterfaceType != nil {
+		return *e.iface.InterfaceType
+	}
+	return ""
+}
+
+// RequesterManaged reports whether the ENI is managed by an AWS service such
+// as ELB or RDS, which the user can't move.
+func (e *ENI) RequesterManaged() bool {
+	return e.iface.RequesterManaged != nil && *e.iface.RequesterManaged
+}
+
+// Tag returns the value of the tag of the ENI, or "" if there is none.
+func (e *ENI) Tag(key string) string {
+	for _, tag := range e.iface.TagSet {
+		if tag.Key != nil && *tag.Key == key && tag.Value != nil {
+			return *tag.Value
+		}
+	}
+	return ""
+}
+
 func (e *ENI) Name() string {
 	if len(e.iface.TagSet) > 0 {
 		for _, tag := range e.iface.TagSet {
@@ -45,6 +45,20 @@ func TestPrivateIpAddress(t *testing.T) {
 	assert.Equal(t, eni.PrivateIpAddress(), "10.0.0.100")
 }
 
+func TestPrivateIPAddresses(t *testing.T) {
+	eni := NewENI(&ec2.NetworkInterface{
+		PrivateIpAddress: aws.String("10.0.0.100"),
+		PrivateIpAddresses: []*ec2.NetworkInterfacePrivateIpAddress{
+			{PrivateIpAddress: aws.String("10.0.0.100"), Primary: aws.Bool(true)},
+			{PrivateIpAddress: aws.String("10.0.0.101"), Primary: aws.Bool(false)},
+		},
+	})
+
+	assert.Equal(t, []string{"10.0.0.100", "10.0.0.101"}, eni.PrivateIPAddresses())
+	assert.True(t, eni.HasPrivateIP("10.0.0.101"))
+	assert.False(t, eni.HasPrivateIP("10.0.0.102"))
+}
+
 func TestStatus(t *testing.T) {
 	eni := NewENI(&ec2.NetworkInterface{
 		NetworkInterfaceId: aws.String("eni-2222222"),
@@ -192,3 +206,75 @@ func TestInterfaceName(t *testing.T) {
 
 	assert.Equal(t, eni.Name(), "eni001")
 }
+
+func TestInTransition(t *testing.T) {
+	tests := []struct {
+		status         string
+		attachedStatus string
+		expected       bool
+	}{
+		{"available", "", false},
+		{"in-use", "attached", false},
+		{"in-use", "attaching", true},
+		{"in-use", "detaching", true},
+		{"in-use", "detached", true},
+		{"attaching", "", true},
+		{"detaching", "", true},
+		{"available", "detached", false},
+	}
+
+	for _, tt := range tests {
+		iface := &ec2.NetworkInterface{Status: aws.String(tt.status)}
+		if tt.attachedStatus != "" {
+			iface.Attachment = &ec2.NetworkInterfaceAttachment{Status: aws.String(tt.attachedStatus)}
+		}
+		eni := NewENI(iface)
+
+		assert.Equal(t, tt.expected, eni.InTransition(), "status %q attachment %q", tt.status, tt.attachedStatus)
+	}
+}
+
+func TestVpcID(t *testing.T) {
+	eni := NewENI(&ec2.NetworkInterface{
+		VpcId: aws.String("vpc-00000001"),
+	})
+
+	assert.Equal(t, "vpc-00000001", eni.VpcID())
+}
+
+func TestSubnetID(t *testing.T) {
+	eni := NewENI(&ec2.NetworkInterface{
+		SubnetId: aws.String("subnet-00000001"),
+	})
+
+	assert.Equal(t, "subnet-00000001", eni.SubnetID())
+}
+
+func TestInterfaceType(t *testing.T) {
+	eni := NewENI(&ec2.NetworkInterface{
+		InterfaceType: aws.String("trunk"),
+	})
+
+	assert.Equal(t, "trunk", eni.InterfaceType())
+}
+
+func TestRequesterManaged(t *testing.T) {
+	eni := NewENI(&ec2.NetworkInterface{
+		RequesterManaged: aws.Bool(true),
+	})
+
+	assert.True(t, eni.RequesterManaged())
+	assert.False(t, NewENI(&ec2.NetworkInterface{}).RequesterManaged())
+}
+
+func TestTag(t *testing.T) {
+	eni := NewENI(&ec2.NetworkInterface{
+		TagSet: []*ec2.Tag{
+			{Key: aws.String("Name"), Value: aws.String("db-vip")},
+			{Key: aws.String("grabeni:lease-owner"), Value: aws.String("i-1000000")},
+		},
+	})
+
+	assert.Equal(t, eni.Tag("grabeni:lease-owner"), "i-1000000")
+	assert.Equal(t, eni.Tag("Role"), "")
+}
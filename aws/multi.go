@@ -0,0 +1,91 @@
+package aws
+
+import (
+	"context"
+
+	"github.com/yuuki/grabeni/aws/model"
+)
+
+// Outcomes of ENIResult
+const (
+	OutcomeMoved   = "moved"
+	OutcomeSkipped = "skipped"
+	OutcomeFailed  = "failed"
+	// OutcomeRolledBack is an ENI moved back by an atomic grab that failed.
+	OutcomeRolledBack = "rolled back"
+	// OutcomeAborted is an ENI left untouched by an atomic grab that failed.
+	OutcomeAborted = "aborted"
+)
+
+// ENIResult is the outcome of an operation on one of several ENIs.
+type ENIResult struct {
+	InterfaceID string
+	// InstanceID is the instance that the ENI is attached to by the
+	// operation, empty for a detachment.
+	InstanceID string
+	// Outcome is OutcomeMoved, OutcomeSkipped if the ENI was already in the
+	// requested state, OutcomeFailed, or one of OutcomeRolledBack and
+	// OutcomeAborted for an atomic grab.
+	Outcome string
+	Err     error
+}
+
+// forEachENI runs op on each of the ENIs in turn. op returns a nil ENI if it
+// had nothing to do, like the single ENI operations.
+func forEachENI(ctx context.Context, interfaceIDs []string, instanceID string, op func(ctx context.Context, interfaceID string) (*model.ENI, error)) []*ENIResult {
+	results := make([]*ENIResult, 0, len(interfaceIDs))
+	for _, id := range interfaceIDs {
+		r := &ENIResult{InterfaceID: id, InstanceID: instanceID}
+		eni, err := op(ctx, id)
+		switch {
+		case err != nil:
+			r.Outcome, r.Err = OutcomeFailed, err
+		case eni == nil:
+			r.Outcome = OutcomeSkipped
+		default:
+			r.Outcome = OutcomeMoved
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// AttachENIsWithContext attaches each of the ENIs to the instance as p,
+// ignoring p.InterfaceID. A failure of one ENI doesn't stop the others.
+func (c *ENIClient) AttachENIsWithContext(ctx context.Context, interfaceIDs []string, p *AttachENIParam, wp *WaiterParam) []*ENIResult {
+	ctx, cancel := withOpTimeout(ctx, wp)
+	defer cancel()
+
+	return forEachENI(ctx, interfaceIDs, p.InstanceID, func(ctx context.Context, id string) (*model.ENI, error) {
+		param := *p
+		param.InterfaceID = id
+		return c.AttachENIWithWaiterWithContext(ctx, &param, wp)
+	})
+}
+
+// DetachENIsWithContext detaches each of the ENIs as p, ignoring
+// p.InterfaceID. A failure of one ENI doesn't stop the others.
+func (c *ENIClient) DetachENIsWithContext(ctx context.Context, interfaceIDs []string, p *DetachENIParam, wp *WaiterParam) []*ENIResult {
+	ctx, cancel := withOpTimeout(ctx, wp)
+	defer cancel()
+
+	return forEachENI(ctx, interfaceIDs, "", func(ctx context.Context, id string) (*model.ENI, error) {
+		param := *p
+		param.InterfaceID = id
+		return c.DetachENIWithWaiterWithContext(ctx, &param, wp)
+	})
+}
+
+// DescribeENIIDsByTagsWithContext returns the IDs of the ENIs having all the tags.
+func (c *ENIClient) DescribeENIIDsByTagsWithContext(ctx context.Context, tags map[string]string) ([]string, error) {
+	enis, err := c.DescribeENIsWithContext(ctx, &ListFilter{Tags: tags})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(enis))
+	for _, eni := range enis {
+		ids = append(ids, eni.InterfaceID())
+	}
+	return ids, nil
+}
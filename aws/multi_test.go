@@ -0,0 +1,86 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDetachENIsWithContext(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	describeInput := func(id string) *ec2.DescribeNetworkInterfacesInput {
+		return &ec2.DescribeNetworkInterfacesInput{NetworkInterfaceIds: []*string{aws.String(id)}}
+	}
+
+	// eni-00000001 is detached, eni-00000002 is already available and
+	// eni-00000003 doesn't exist
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, describeInput("eni-00000001")).Return(
+		describeENIOutput("in-use", "attached", "i-00000001"), nil).Once()
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, describeInput("eni-00000001")).Return(
+		describeENIOutput("available", "", ""), nil).Once()
+	mockEC2.On("DescribeInstancesWithContext", mock.Anything, &ec2.DescribeInstancesInput{
+		InstanceIds: []*string{aws.String("i-00000001")},
+	}).Return(describeInstanceOutput("i-00000001", 0, 1), nil).Once()
+	mockEC2.On("DetachNetworkInterfaceWithContext", mock.Anything, &ec2.DetachNetworkInterfaceInput{
+		AttachmentId: aws.String("eni-attach-00000001"),
+		Force:        aws.Bool(false),
+	}).Return(&ec2.DetachNetworkInterfaceOutput{}, nil).Once()
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, describeInput("eni-00000002")).Return(
+		&ec2.DescribeNetworkInterfacesOutput{
+			NetworkInterfaces: []*ec2.NetworkInterface{{
+				NetworkInterfaceId: aws.String("eni-00000002"),
+				Status:             aws.String("available"),
+			}},
+		}, nil).Once()
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, describeInput("eni-00000003")).Return(
+		nil, awserr.New("InvalidNetworkInterfaceID.NotFound", "The networkInterface ID 'eni-00000003' does not exist", nil)).Once()
+
+	results := c.DetachENIsWithContext(context.Background(), []string{"eni-00000001", "eni-00000002", "eni-00000003"},
+		&DetachENIParam{}, &WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+	if assert.Len(t, results, 3) {
+		assert.Equal(t, OutcomeMoved, results[0].Outcome)
+		assert.NoError(t, results[0].Err)
+		assert.Equal(t, OutcomeSkipped, results[1].Outcome)
+		assert.NoError(t, results[1].Err)
+		assert.Equal(t, OutcomeFailed, results[2].Outcome)
+		var notFound *ENINotFoundError
+		assert.True(t, errors.As(results[2].Err, &notFound))
+	}
+	mockEC2.AssertExpectations(t)
+}
+
+func TestDescribeENIIDsByTags(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockEC2.On("DescribeNetworkInterfacesPagesWithContext", mock.Anything, &ec2.DescribeNetworkInterfacesInput{
+		MaxResults: aws.Int64(1000),
+		Filters: []*ec2.Filter{{
+			Name:   aws.String("tag:vip-group"),
+			Values: aws.StringSlice([]string{"mysql-main"}),
+		}},
+	}, mock.Anything).Run(returnPages(
+		&ec2.DescribeNetworkInterfacesOutput{
+			NetworkInterfaces: []*ec2.NetworkInterface{
+				{NetworkInterfaceId: aws.String("eni-00000001")},
+				{NetworkInterfaceId: aws.String("eni-00000002")},
+			},
+		},
+	)).Return(nil)
+
+	ids, err := c.DescribeENIIDsByTagsWithContext(context.Background(), map[string]string{"vip-group": "mysql-main"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"eni-00000001", "eni-00000002"}, ids)
+	mockEC2.AssertExpectations(t)
+}
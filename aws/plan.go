@@ -0,0 +1,141 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/yuuki/grabeni/aws/model"
+)
+
+// GrabPlan is the sequence of operations that GrabENI would perform on the
+// current state of the ENI.
+type GrabPlan struct {
+	InterfaceID string
+	// WaitFor is the attachment status to wait out before anything else, if any.
+	WaitFor string
+	// DetachInstanceID is the instance to detach the ENI from, if any.
+	DetachInstanceID  string
+	DetachDeviceIndex int
+	// AttachInstanceID is the instance to attach the ENI to, empty if the ENI
+	// is already attached to it.
+	AttachInstanceID  string
+	AttachDeviceIndex int
+	// LeaseTTL is the TTL of the lease to take before detaching, if any.
+	LeaseTTL time.Duration
+	// FenceMethod is how to fence DetachInstanceID before detaching, if any.
+	FenceMethod string
+}
+
+// Steps describes the plan in human readable sentences.
+func (p *GrabPlan) Steps() []string {
+	steps := make([]string, 0, 3)
+	if p.WaitFor != "" {
+		steps = append(steps, fmt.Sprintf("wait until %s finishes %s", p.InterfaceID, p.WaitFor))
+	}
+	if p.LeaseTTL > 0 && p.AttachInstanceID != "" {
+		steps = append(steps, fmt.Sprintf("lease %s to %s for %s", p.InterfaceID, p.AttachInstanceID, p.LeaseTTL))
+	}
+	if p.FenceMethod != "" && p.DetachInstanceID != "" {
+		steps = append(steps, fmt.Sprintf("fence %s by %s", p.DetachInstanceID, p.FenceMethod))
+	}
+	if p.DetachInstanceID != "" {
+		steps = append(steps, fmt.Sprintf("detach %s from %s device %d", p.InterfaceID, p.DetachInstanceID, p.DetachDeviceIndex))
+	}
+	if p.AttachInstanceID != "" {
+		steps = append(steps, fmt.Sprintf("attach %s to %s device %d", p.InterfaceID, p.AttachInstanceID, p.AttachDeviceIndex))
+	} else {
+		steps = append(steps, fmt.Sprintf("nothing to attach: %s is already attached to the instance", p.InterfaceID))
+	}
+	return steps
+}
+
+// PlanGrabENIWithContext works out what GrabENI would do with p and validates
+// the plan with DryRun requests, without changing anything. The plan is
+// returned along with the error if it would fail, as long as it could be made.
+func (c *ENIClient) PlanGrabENIWithContext(ctx context.Context, p *GrabENIParam) (*GrabPlan, error) {
+	eni, err := c.DescribeENIByIDWithContext(ctx, p.InterfaceID)
+	if err != nil {
+		return nil, err
+	}
+	instance, err := c.describeInstanceWithType(ctx, p.InstanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &GrabPlan{
+		InterfaceID:       p.InterfaceID,
+		AttachInstanceID:  p.InstanceID,
+		AttachDeviceIndex: c.grabDeviceIndex(p, eni, instance),
+	}
+
+	// Assume that an attachment or a detachment in progress will complete
+	status, attachedStatus := eni.Status(), eni.AttachedStatus()
+	if eni.InTransition() {
+		plan.WaitFor = attachedStatus
+		if plan.WaitFor == "" {
+			plan.WaitFor = status
+		}
+		switch plan.WaitFor {
+		case "attaching":
+			status, attachedStatus = "in-use", "attached"
+		default:
+			status, attachedStatus = "available", ""
+		}
+	}
+
+	switch {
+	case status == "in-use" && attachedStatus == "attached":
+		if eni.AttachedInstanceID() == p.InstanceID {
+			plan.AttachInstanceID = ""
+			return plan, nil
+		}
+		plan.DetachInstanceID = eni.AttachedInstanceID()
+		plan.DetachDeviceIndex = int(eni.AttachedDeviceIndex())
+	case status == "available":
+	default:
+		return plan, &InvalidStateError{
+			Op:             "grab",
+			InterfaceID:    p.InterfaceID,
+			Status:         status,
+			AttachedStatus: attachedStatus,
+		}
+	}
+
+	if err := checkENILimit(instance); err != nil {
+		return plan, err
+	}
+	if p.Fence != "" {
+		if err := ValidateFenceMethod(p.Fence, p.QuarantineSecurityGroupID); err != nil {
+			return plan, err
+		}
+		plan.FenceMethod = p.Fence
+	}
+	if p.LeaseTTL > 0 {
+		plan.LeaseTTL = p.LeaseTTL
+		if lease := leaseOf(eni); lease.HeldByOther(p.InstanceID, time.Now()) && !p.StealLease {
+			return plan, &LeaseHeldError{InterfaceID: p.InterfaceID, Owner: lease.Owner, Expiry: lease.Expiry}
+		}
+	}
+
+	if err := c.dryRunPlan(ctx, eni, plan); err != nil {
+		return plan, err
+	}
+
+	return plan, nil
+}
+
+func (c *ENIClient) dryRunPlan(ctx context.Context, eni *model.ENI, plan *GrabPlan) error {
+	if plan.DetachInstanceID != "" && eni.AttachmentID() != "" {
+		if err := c.detachENIByAttachmentID(ctx, eni.AttachmentID(), false, true); err != nil {
+			return err
+		}
+	}
+
+	return c.attachENI(ctx, &AttachENIParam{
+		InterfaceID: plan.InterfaceID,
+		InstanceID:  plan.AttachInstanceID,
+		DeviceIndex: plan.AttachDeviceIndex,
+		DryRun:      true,
+	})
+}
@@ -0,0 +1,151 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func mockPlanDescribes(m *EC2API, status, attachedStatus, instanceID string) {
+	m.On("DescribeNetworkInterfacesWithContext", mock.Anything, &ec2.DescribeNetworkInterfacesInput{
+		NetworkInterfaceIds: []*string{aws.String("eni-00000001")},
+	}).Return(describeENIOutput(status, attachedStatus, instanceID), nil)
+	// Describing the ENI describes the instance it is attached to as well
+	if instanceID != "" && instanceID != "i-00000002" {
+		mockDescribeInstance(m, describeInstanceOutput(instanceID, 1))
+	}
+	mockDescribeInstance(m, describeInstanceOutput("i-00000002"))
+}
+
+func TestPlanGrabENI(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockPlanDescribes(mockEC2, "in-use", "attached", "i-00000001")
+	dryRunOK := awserr.New("DryRunOperation", "Request would have succeeded, but DryRun flag is set.", nil)
+	mockEC2.On("DetachNetworkInterfaceWithContext", mock.Anything, &ec2.DetachNetworkInterfaceInput{
+		AttachmentId: aws.String("eni-attach-00000001"),
+		Force:        aws.Bool(false),
+		DryRun:       aws.Bool(true),
+	}).Return(nil, dryRunOK).Once()
+	mockEC2.On("AttachNetworkInterfaceWithContext", mock.Anything, &ec2.AttachNetworkInterfaceInput{
+		NetworkInterfaceId: aws.String("eni-00000001"),
+		InstanceId:         aws.String("i-00000002"),
+		DeviceIndex:        aws.Int64(2),
+		DryRun:             aws.Bool(true),
+	}).Return(nil, dryRunOK).Once()
+
+	plan, err := c.PlanGrabENIWithContext(context.Background(), &GrabENIParam{
+		InterfaceID: "eni-00000001",
+		InstanceID:  "i-00000002",
+		DeviceIndex: 2,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"detach eni-00000001 from i-00000001 device 1",
+		"attach eni-00000001 to i-00000002 device 2",
+	}, plan.Steps())
+	mockEC2.AssertExpectations(t)
+}
+
+func TestPlanGrabENIUnauthorized(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockPlanDescribes(mockEC2, "in-use", "detaching", "i-00000001")
+	mockEC2.On("DetachNetworkInterfaceWithContext", mock.Anything, mock.Anything).Return(
+		nil, awserr.New("DryRunOperation", "Request would have succeeded, but DryRun flag is set.", nil))
+	mockEC2.On("AttachNetworkInterfaceWithContext", mock.Anything, mock.Anything).Return(
+		nil, awserr.New("UnauthorizedOperation", "You are not authorized to perform this operation.", nil))
+
+	plan, err := c.PlanGrabENIWithContext(context.Background(), &GrabENIParam{
+		InterfaceID: "eni-00000001",
+		InstanceID:  "i-00000002",
+		DeviceIndex: 1,
+	})
+
+	assert.EqualError(t, err, "UnauthorizedOperation: You are not authorized to perform this operation.")
+	assert.Equal(t, []string{
+		"wait until eni-00000001 finishes detaching",
+		"attach eni-00000001 to i-00000002 device 1",
+	}, plan.Steps())
+}
+
+func TestPlanGrabENIAlreadyAttached(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockPlanDescribes(mockEC2, "in-use", "attached", "i-00000002")
+
+	plan, err := c.PlanGrabENIWithContext(context.Background(), &GrabENIParam{
+		InterfaceID: "eni-00000001",
+		InstanceID:  "i-00000002",
+		DeviceIndex: 1,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"nothing to attach: eni-00000001 is already attached to the instance",
+	}, plan.Steps())
+	mockEC2.AssertNotCalled(t, "AttachNetworkInterfaceWithContext", mock.Anything, mock.Anything)
+}
+
+func TestPlanGrabENIFenceAndLease(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockPlanDescribes(mockEC2, "in-use", "attached", "i-00000001")
+	dryRunOK := awserr.New("DryRunOperation", "Request would have succeeded, but DryRun flag is set.", nil)
+	mockEC2.On("DetachNetworkInterfaceWithContext", mock.Anything, mock.Anything).Return(nil, dryRunOK)
+	mockEC2.On("AttachNetworkInterfaceWithContext", mock.Anything, mock.Anything).Return(nil, dryRunOK)
+
+	plan, err := c.PlanGrabENIWithContext(context.Background(), &GrabENIParam{
+		InterfaceID: "eni-00000001",
+		InstanceID:  "i-00000002",
+		DeviceIndex: 1,
+		LeaseTTL:    time.Minute,
+		Fence:       FenceStop,
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"lease eni-00000001 to i-00000002 for 1m0s",
+		"fence i-00000001 by stop",
+		"detach eni-00000001 from i-00000001 device 1",
+		"attach eni-00000001 to i-00000002 device 1",
+	}, plan.Steps())
+}
+
+func TestPlanGrabENIOverENILimit(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockDescribeENISequence(mockEC2, "eni-00000001", describeENIOutput("available", "", ""))
+	instance := describeInstanceOutput("i-00000002", 0, 1)
+	instance.Reservations[0].Instances[0].InstanceType = aws.String("t3.micro")
+	mockDescribeInstance(mockEC2, instance)
+	mockEC2.On("DescribeInstanceTypesPagesWithContext", mock.Anything, &ec2.DescribeInstanceTypesInput{
+		InstanceTypes: []*string{aws.String("t3.micro")},
+	}, mock.Anything).Run(returnPages(describeInstanceTypesOutput("t3.micro", 2))).Return(nil).Once()
+
+	plan, err := c.PlanGrabENIWithContext(context.Background(), &GrabENIParam{
+		InterfaceID: "eni-00000001",
+		InstanceID:  "i-00000002",
+		DeviceIndex: 2,
+	})
+
+	var limit *ENILimitExceededError
+	assert.True(t, errors.As(err, &limit))
+	assert.Equal(t, []string{
+		"attach eni-00000001 to i-00000002 device 2",
+	}, plan.Steps())
+	mockEC2.AssertNotCalled(t, "AttachNetworkInterfaceWithContext", mock.Anything, mock.Anything)
+}
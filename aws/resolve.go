@@ -0,0 +1,120 @@
+package aws
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+var (
+	eniIDPattern      = regexp.MustCompile(`^eni-[0-9a-f]+$`)
+	instanceIDPattern = regexp.MustCompile(`^i-[0-9a-f]+$`)
+)
+
+// ENI selector prefixes
+const (
+	selectorName = "name:"
+	selectorIP   = "ip:"
+	selectorDNS  = "dns:"
+)
+
+// ResolveENIID resolves the ENI selector into an ENI ID. The selector is an
+// ENI ID, "name:" followed by the Name tag, "ip:" followed by a private IP or
+// "dns:" followed by the private DNS name. Without a prefix, the kind of the
+// selector is detected from its form and a Name tag is assumed if unsure.
+func (c *ENIClient) ResolveENIID(selector string) (string, error) {
+	return c.ResolveENIIDWithContext(context.Background(), selector)
+}
+
+func (c *ENIClient) ResolveENIIDWithContext(ctx context.Context, selector string) (string, error) {
+	var filter *ec2.Filter
+	switch {
+	case strings.HasPrefix(selector, selectorName):
+		filter = newFilter("tag:Name", strings.TrimPrefix(selector, selectorName))
+	case strings.HasPrefix(selector, selectorIP):
+		filter = newFilter("addresses.private-ip-address", strings.TrimPrefix(selector, selectorIP))
+	case strings.HasPrefix(selector, selectorDNS):
+		filter = newFilter("private-dns-name", strings.TrimPrefix(selector, selectorDNS))
+	case eniIDPattern.MatchString(selector):
+		return selector, nil
+	case net.ParseIP(selector) != nil:
+		filter = newFilter("addresses.private-ip-address", selector)
+	case strings.HasSuffix(selector, ".internal"):
+		filter = newFilter("private-dns-name", selector)
+	default:
+		filter = newFilter("tag:Name", selector)
+	}
+
+	resp, err := c.svc.DescribeNetworkInterfacesWithContext(ctx, &ec2.DescribeNetworkInterfacesInput{
+		Filters: []*ec2.Filter{filter},
+	})
+	if err != nil {
+		return "", wrapContextError(ctx, "resolve", selector, err)
+	}
+
+	ids := make([]string, 0, len(resp.NetworkInterfaces))
+	for _, iface := range resp.NetworkInterfaces {
+		ids = append(ids, aws.StringValue(iface.NetworkInterfaceId))
+	}
+	switch len(ids) {
+	case 0:
+		return "", &ENINotFoundError{InterfaceID: selector}
+	case 1:
+		return ids[0], nil
+	}
+	return "", &AmbiguousSelectorError{Selector: selector, Kind: "ENIs", IDs: ids}
+}
+
+// ResolveInstanceID resolves the instance selector, which is an instance ID or
+// the Name tag optionally prefixed with "name:", into an instance ID.
+// Terminated instances are never matched.
+func (c *ENIClient) ResolveInstanceID(selector string) (string, error) {
+	return c.ResolveInstanceIDWithContext(context.Background(), selector)
+}
+
+func (c *ENIClient) ResolveInstanceIDWithContext(ctx context.Context, selector string) (string, error) {
+	if instanceIDPattern.MatchString(selector) {
+		return selector, nil
+	}
+
+	input := &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			newFilter("tag:Name", strings.TrimPrefix(selector, selectorName)),
+			{
+				Name:   aws.String("instance-state-name"),
+				Values: aws.StringSlice([]string{"pending", "running", "stopping", "stopped"}),
+			},
+		},
+	}
+	ids := make([]string, 0)
+	err := c.svc.DescribeInstancesPagesWithContext(ctx, input, func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+		for _, r := range page.Reservations {
+			for _, i := range r.Instances {
+				ids = append(ids, aws.StringValue(i.InstanceId))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return "", wrapContextError(ctx, "resolve", selector, err)
+	}
+
+	switch len(ids) {
+	case 0:
+		return "", &InstanceNotFoundError{InstanceID: selector}
+	case 1:
+		return ids[0], nil
+	}
+	return "", &AmbiguousSelectorError{Selector: selector, Kind: "instances", IDs: ids}
+}
+
+func newFilter(name, value string) *ec2.Filter {
+	return &ec2.Filter{
+		Name:   aws.String(name),
+		Values: []*string{aws.String(value)},
+	}
+}
@@ -0,0 +1,108 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestResolveENIID(t *testing.T) {
+	tests := []struct {
+		selector string
+		filter   string
+		value    string
+	}{
+		{"name:db-master-vip", "tag:Name", "db-master-vip"},
+		{"db-master-vip", "tag:Name", "db-master-vip"},
+		{"ip:10.0.0.10", "addresses.private-ip-address", "10.0.0.10"},
+		{"10.0.0.10", "addresses.private-ip-address", "10.0.0.10"},
+		{"dns:ip-10-0-0-10.ap-northeast-1.compute.internal", "private-dns-name", "ip-10-0-0-10.ap-northeast-1.compute.internal"},
+		{"ip-10-0-0-10.ap-northeast-1.compute.internal", "private-dns-name", "ip-10-0-0-10.ap-northeast-1.compute.internal"},
+		{"name:eni-00000001", "tag:Name", "eni-00000001"},
+	}
+
+	for _, tt := range tests {
+		mockEC2 := new(EC2API)
+		c := newClient(mockEC2)
+
+		mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, &ec2.DescribeNetworkInterfacesInput{
+			Filters: []*ec2.Filter{{
+				Name:   aws.String(tt.filter),
+				Values: []*string{aws.String(tt.value)},
+			}},
+		}).Return(&ec2.DescribeNetworkInterfacesOutput{
+			NetworkInterfaces: []*ec2.NetworkInterface{{NetworkInterfaceId: aws.String("eni-00000002")}},
+		}, nil).Once()
+
+		id, err := c.ResolveENIIDWithContext(context.Background(), tt.selector)
+
+		assert.NoError(t, err, tt.selector)
+		assert.Equal(t, "eni-00000002", id, tt.selector)
+		mockEC2.AssertExpectations(t)
+	}
+}
+
+func TestResolveENIIDAsIs(t *testing.T) {
+	c := newClient(new(EC2API))
+
+	id, err := c.ResolveENIID("eni-00000001")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "eni-00000001", id)
+}
+
+func TestResolveENIIDNoOrManyMatches(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, mock.Anything).Return(
+		&ec2.DescribeNetworkInterfacesOutput{}, nil).Once()
+	mockEC2.On("DescribeNetworkInterfacesWithContext", mock.Anything, mock.Anything).Return(&ec2.DescribeNetworkInterfacesOutput{
+		NetworkInterfaces: []*ec2.NetworkInterface{
+			{NetworkInterfaceId: aws.String("eni-00000001")},
+			{NetworkInterfaceId: aws.String("eni-00000002")},
+		},
+	}, nil).Once()
+
+	_, err := c.ResolveENIID("name:vip")
+
+	var notFound *ENINotFoundError
+	assert.True(t, errors.As(err, &notFound))
+	assert.EqualError(t, err, "no such ENI name:vip")
+
+	_, err = c.ResolveENIID("name:vip")
+
+	var ambiguous *AmbiguousSelectorError
+	assert.True(t, errors.As(err, &ambiguous))
+	assert.EqualError(t, err, "name:vip matches 2 ENIs: eni-00000001, eni-00000002")
+}
+
+func TestResolveInstanceID(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	id, err := c.ResolveInstanceID("i-00000001")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "i-00000001", id)
+
+	mockEC2.On("DescribeInstancesPagesWithContext", mock.Anything, &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String("tag:Name"), Values: []*string{aws.String("db01")}},
+			{Name: aws.String("instance-state-name"), Values: aws.StringSlice([]string{"pending", "running", "stopping", "stopped"})},
+		},
+	}, mock.Anything).Run(returnPages(describeInstanceOutput("i-00000002"))).Return(nil).Twice()
+
+	for _, selector := range []string{"db01", "name:db01"} {
+		id, err = c.ResolveInstanceID(selector)
+
+		assert.NoError(t, err, selector)
+		assert.Equal(t, "i-00000002", id, selector)
+	}
+	mockEC2.AssertExpectations(t)
+}
@@ -0,0 +1,205 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+type GrabRouteParam struct {
+	RouteTableIDs   []string
+	DestinationCIDR string
+	// InterfaceID or InstanceID is the new target of the route. InterfaceID
+	// takes precedence if both are set.
+	InterfaceID string
+	InstanceID  string
+}
+
+func (p *GrabRouteParam) target() string {
+	if p.InterfaceID != "" {
+		return p.InterfaceID
+	}
+	return p.InstanceID
+}
+
+func (p *GrabRouteParam) routesTo(r *ec2.Route) bool {
+	if p.InterfaceID != "" {
+		return aws.StringValue(r.NetworkInterfaceId) == p.InterfaceID
+	}
+	return aws.StringValue(r.InstanceId) == p.InstanceID
+}
+
+// RouteChange is the change of the route in a route table by GrabRoute.
+type RouteChange struct {
+	RouteTableID    string
+	DestinationCIDR string
+	// PreviousTarget is the ENI, the instance or the gateway that the route
+	// pointed at, empty if the route didn't exist.
+	PreviousTarget string
+	Target         string
+	// Action is one of "replaced", "created" and "unchanged".
+	Action string
+}
+
+func (c *ENIClient) GrabRoute(p *GrabRouteParam, wp *WaiterParam) ([]*RouteChange, error) {
+	return c.GrabRouteWithContext(context.Background(), p, wp)
+}
+
+// GrabRouteWithContext points the route to the destination CIDR in each route
+// table at the ENI or the instance of p, creating the route if missing, and
+// waits until DescribeRouteTables shows the new target in all of them. The
+// changes made so far are returned along with the error if it fails halfway.
+func (c *ENIClient) GrabRouteWithContext(ctx context.Context, p *GrabRouteParam, wp *WaiterParam) ([]*RouteChange, error) {
+	if err := validateWaitUntilParam(wp); err != nil {
+		return nil, err
+	}
+	ctx, cancel := withOpTimeout(ctx, wp)
+	defer cancel()
+	if len(p.RouteTableIDs) < 1 {
+		return nil, fmt.Errorf("route table required")
+	}
+	if p.target() == "" {
+		return nil, fmt.Errorf("ENI or instance to route %s to required", p.DestinationCIDR)
+	}
+
+	routes, err := c.describeRoutes(ctx, p.RouteTableIDs, p.DestinationCIDR)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]*RouteChange, 0, len(p.RouteTableIDs))
+	for _, id := range p.RouteTableIDs {
+		change := &RouteChange{
+			RouteTableID:    id,
+			DestinationCIDR: p.DestinationCIDR,
+			Target:          p.target(),
+		}
+
+		route := routes[id]
+		switch {
+		case route != nil && p.routesTo(route):
+			change.PreviousTarget = routeTarget(route)
+			change.Action = "unchanged"
+		case route != nil:
+			change.PreviousTarget = routeTarget(route)
+			change.Action = "replaced"
+			err = c.replaceRoute(ctx, id, p)
+		default:
+			change.Action = "created"
+			err = c.createRoute(ctx, id, p)
+		}
+		if err != nil {
+			return changes, err
+		}
+		changes = append(changes, change)
+
+		if change.Action != "unchanged" {
+			c.logger.Printf("--> Routing: %15s to %s in %s (%s)\n", p.DestinationCIDR, p.target(), id, change.Action)
+		}
+	}
+
+	err = c.waitUntil(ctx, "route", p.DestinationCIDR, wp, func(ctx context.Context) (bool, error) {
+		routes, err := c.describeRoutes(ctx, p.RouteTableIDs, p.DestinationCIDR)
+		if err != nil {
+			return false, err
+		}
+		for _, id := range p.RouteTableIDs {
+			if r := routes[id]; r == nil || !p.routesTo(r) {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return changes, err
+	}
+
+	c.logger.Printf("--> Routed: %15s to %s\n", p.DestinationCIDR, p.target())
+	return changes, nil
+}
+
+// describeRoutes returns the route to the destination CIDR by route table ID.
+// The route tables without the route are missing in the result.
+func (c *ENIClient) describeRoutes(ctx context.Context, routeTableIDs []string, cidr string) (map[string]*ec2.Route, error) {
+	resp, err := c.svc.DescribeRouteTablesWithContext(ctx, &ec2.DescribeRouteTablesInput{
+		RouteTableIds: aws.StringSlice(routeTableIDs),
+	})
+	if err != nil {
+		return nil, wrapContextError(ctx, "describe", "route tables", translateRouteTableError(err, routeTableIDs))
+	}
+
+	found := make(map[string]bool, len(resp.RouteTables))
+	routes := make(map[string]*ec2.Route, len(resp.RouteTables))
+	for _, rt := range resp.RouteTables {
+		id := aws.StringValue(rt.RouteTableId)
+		found[id] = true
+		for _, r := range rt.Routes {
+			if aws.StringValue(r.DestinationCidrBlock) == cidr {
+				routes[id] = r
+			}
+		}
+	}
+	for _, id := range routeTableIDs {
+		if !found[id] {
+			return nil, &RouteTableNotFoundError{RouteTableID: id}
+		}
+	}
+
+	return routes, nil
+}
+
+func (c *ENIClient) replaceRoute(ctx context.Context, routeTableID string, p *GrabRouteParam) error {
+	input := &ec2.ReplaceRouteInput{
+		RouteTableId:         aws.String(routeTableID),
+		DestinationCidrBlock: aws.String(p.DestinationCIDR),
+	}
+	if p.InterfaceID != "" {
+		input.NetworkInterfaceId = aws.String(p.InterfaceID)
+	} else {
+		input.InstanceId = aws.String(p.InstanceID)
+	}
+
+	if _, err := c.svc.ReplaceRouteWithContext(ctx, input); err != nil {
+		return wrapContextError(ctx, "replace route", routeTableID, translateAPIError(err, p.InterfaceID, p.InstanceID))
+	}
+	return nil
+}
+
+func (c *ENIClient) createRoute(ctx context.Context, routeTableID string, p *GrabRouteParam) error {
+	input := &ec2.CreateRouteInput{
+		RouteTableId:         aws.String(routeTableID),
+		DestinationCidrBlock: aws.String(p.DestinationCIDR),
+	}
+	if p.InterfaceID != "" {
+		input.NetworkInterfaceId = aws.String(p.InterfaceID)
+	} else {
+		input.InstanceId = aws.String(p.InstanceID)
+	}
+
+	if _, err := c.svc.CreateRouteWithContext(ctx, input); err != nil {
+		return wrapContextError(ctx, "create route", routeTableID, translateAPIError(err, p.InterfaceID, p.InstanceID))
+	}
+	return nil
+}
+
+// routeTarget returns the ID of whatever the route points at.
+func routeTarget(r *ec2.Route) string {
+	for _, id := range []*string{
+		r.NetworkInterfaceId,
+		r.InstanceId,
+		r.GatewayId,
+		r.NatGatewayId,
+		r.TransitGatewayId,
+		r.VpcPeeringConnectionId,
+		r.LocalGatewayId,
+		r.CarrierGatewayId,
+		r.EgressOnlyInternetGatewayId,
+	} {
+		if aws.StringValue(id) != "" {
+			return *id
+		}
+	}
+	return ""
+}
@@ -0,0 +1,136 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Build a route table with the route to 10.255.0.1/32 via interfaceID, or without it if empty
+func routeTable(routeTableID, interfaceID string) *ec2.RouteTable {
+	rt := &ec2.RouteTable{
+		RouteTableId: aws.String(routeTableID),
+		Routes: []*ec2.Route{{
+			DestinationCidrBlock: aws.String("10.0.0.0/16"),
+			GatewayId:            aws.String("local"),
+		}},
+	}
+	if interfaceID != "" {
+		rt.Routes = append(rt.Routes, &ec2.Route{
+			DestinationCidrBlock: aws.String("10.255.0.1/32"),
+			NetworkInterfaceId:   aws.String(interfaceID),
+		})
+	}
+	return rt
+}
+
+func TestGrabRoute(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	input := &ec2.DescribeRouteTablesInput{
+		RouteTableIds: []*string{aws.String("rtb-00000001"), aws.String("rtb-00000002"), aws.String("rtb-00000003")},
+	}
+	mockEC2.On("DescribeRouteTablesWithContext", mock.Anything, input).Return(&ec2.DescribeRouteTablesOutput{
+		RouteTables: []*ec2.RouteTable{
+			routeTable("rtb-00000001", "eni-00000001"),
+			routeTable("rtb-00000002", ""),
+			routeTable("rtb-00000003", "eni-00000002"),
+		},
+	}, nil).Once()
+	mockEC2.On("ReplaceRouteWithContext", mock.Anything, &ec2.ReplaceRouteInput{
+		RouteTableId:         aws.String("rtb-00000001"),
+		DestinationCidrBlock: aws.String("10.255.0.1/32"),
+		NetworkInterfaceId:   aws.String("eni-00000002"),
+	}).Return(&ec2.ReplaceRouteOutput{}, nil).Once()
+	mockEC2.On("CreateRouteWithContext", mock.Anything, &ec2.CreateRouteInput{
+		RouteTableId:         aws.String("rtb-00000002"),
+		DestinationCidrBlock: aws.String("10.255.0.1/32"),
+		NetworkInterfaceId:   aws.String("eni-00000002"),
+	}).Return(&ec2.CreateRouteOutput{}, nil).Once()
+	mockEC2.On("DescribeRouteTablesWithContext", mock.Anything, input).Return(&ec2.DescribeRouteTablesOutput{
+		RouteTables: []*ec2.RouteTable{
+			routeTable("rtb-00000001", "eni-00000002"),
+			routeTable("rtb-00000002", ""),
+			routeTable("rtb-00000003", "eni-00000002"),
+		},
+	}, nil).Once()
+	mockEC2.On("DescribeRouteTablesWithContext", mock.Anything, input).Return(&ec2.DescribeRouteTablesOutput{
+		RouteTables: []*ec2.RouteTable{
+			routeTable("rtb-00000001", "eni-00000002"),
+			routeTable("rtb-00000002", "eni-00000002"),
+			routeTable("rtb-00000003", "eni-00000002"),
+		},
+	}, nil).Once()
+
+	changes, err := c.GrabRouteWithContext(context.Background(), &GrabRouteParam{
+		RouteTableIDs:   []string{"rtb-00000001", "rtb-00000002", "rtb-00000003"},
+		DestinationCIDR: "10.255.0.1/32",
+		InterfaceID:     "eni-00000002",
+	}, &WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []*RouteChange{
+		{RouteTableID: "rtb-00000001", DestinationCIDR: "10.255.0.1/32", PreviousTarget: "eni-00000001", Target: "eni-00000002", Action: "replaced"},
+		{RouteTableID: "rtb-00000002", DestinationCIDR: "10.255.0.1/32", PreviousTarget: "", Target: "eni-00000002", Action: "created"},
+		{RouteTableID: "rtb-00000003", DestinationCIDR: "10.255.0.1/32", PreviousTarget: "eni-00000002", Target: "eni-00000002", Action: "unchanged"},
+	}, changes)
+	mockEC2.AssertExpectations(t)
+}
+
+func TestGrabRouteFailsHalfway(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockEC2.On("DescribeRouteTablesWithContext", mock.Anything, mock.Anything).Return(&ec2.DescribeRouteTablesOutput{
+		RouteTables: []*ec2.RouteTable{
+			routeTable("rtb-00000001", "eni-00000001"),
+			routeTable("rtb-00000002", "eni-00000001"),
+		},
+	}, nil)
+	mockEC2.On("ReplaceRouteWithContext", mock.Anything, mock.Anything).Return(&ec2.ReplaceRouteOutput{}, nil).Once()
+	mockEC2.On("ReplaceRouteWithContext", mock.Anything, mock.Anything).Return(nil, errors.New("RouteLimitExceeded")).Once()
+
+	changes, err := c.GrabRouteWithContext(context.Background(), &GrabRouteParam{
+		RouteTableIDs:   []string{"rtb-00000001", "rtb-00000002"},
+		DestinationCIDR: "10.255.0.1/32",
+		InstanceID:      "i-00000002",
+	}, &WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+	assert.EqualError(t, err, "RouteLimitExceeded")
+	if assert.Len(t, changes, 1) {
+		assert.Equal(t, "rtb-00000001", changes[0].RouteTableID)
+		assert.Equal(t, "eni-00000001", changes[0].PreviousTarget)
+	}
+}
+
+func TestGrabRouteTableNotFound(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockEC2.On("DescribeRouteTablesWithContext", mock.Anything, mock.Anything).Return(&ec2.DescribeRouteTablesOutput{
+		RouteTables: []*ec2.RouteTable{routeTable("rtb-00000001", "")},
+	}, nil).Once()
+	mockEC2.On("DescribeRouteTablesWithContext", mock.Anything, mock.Anything).Return(
+		nil, awserr.New("InvalidRouteTableID.NotFound", "The routeTable ID 'rtb-00000002' does not exist", nil)).Once()
+
+	for _, ids := range [][]string{{"rtb-00000001", "rtb-00000002"}, {"rtb-00000002"}} {
+		_, err := c.GrabRouteWithContext(context.Background(), &GrabRouteParam{
+			RouteTableIDs:   ids,
+			DestinationCIDR: "10.255.0.1/32",
+			InterfaceID:     "eni-00000002",
+		}, &WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+		var notFound *RouteTableNotFoundError
+		if assert.True(t, errors.As(err, &notFound)) {
+			assert.Equal(t, "rtb-00000002", notFound.RouteTableID)
+		}
+	}
+}
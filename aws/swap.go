@@ -0,0 +1,143 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yuuki/grabeni/aws/model"
+)
+
+type SwapENIParam struct {
+	// InterfaceIDs are the two ENIs to exchange, each attached to a different
+	// instance.
+	InterfaceIDs [2]string
+	// ForceDetach and ForceDetachGracePeriod are used as in GrabENIParam.
+	ForceDetach            bool
+	ForceDetachGracePeriod time.Duration
+}
+
+// swapSlot is where one of the ENIs to swap is attached before the swap.
+type swapSlot struct {
+	interfaceID string
+	instanceID  string
+	deviceIndex int64
+}
+
+func (s *swapSlot) String() string {
+	return fmt.Sprintf("%s on %s (device index %d)", s.interfaceID, s.instanceID, s.deviceIndex)
+}
+
+func (c *ENIClient) SwapENIs(p *SwapENIParam, wp *WaiterParam) ([]*model.ENI, error) {
+	return c.SwapENIsWithContext(context.Background(), p, wp)
+}
+
+// SwapENIsWithContext detaches both ENIs and attaches each to the instance and
+// device index that the other was attached at. If any step fails, it restores
+// the original layout.
+func (c *ENIClient) SwapENIsWithContext(ctx context.Context, p *SwapENIParam, wp *WaiterParam) ([]*model.ENI, error) {
+	if err := validateWaitUntilParam(wp); err != nil {
+		return nil, err
+	}
+	ctx, cancel := withOpTimeout(ctx, wp)
+	defer cancel()
+	if p.InterfaceIDs[0] == p.InterfaceIDs[1] {
+		return nil, fmt.Errorf("can't swap %s with itself", p.InterfaceIDs[0])
+	}
+
+	var slots [2]*swapSlot
+	for i, id := range p.InterfaceIDs {
+		eni, err := c.DescribeENIByIDWithContext(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if eni.Status() != "in-use" || eni.AttachedStatus() != "attached" {
+			return nil, &InvalidStateError{
+				Op:             "swap",
+				InterfaceID:    id,
+				Status:         eni.Status(),
+				AttachedStatus: eni.AttachedStatus(),
+			}
+		}
+		slots[i] = &swapSlot{interfaceID: id, instanceID: eni.AttachedInstanceID(), deviceIndex: eni.AttachedDeviceIndex()}
+	}
+	if slots[0].instanceID == slots[1].instanceID {
+		return nil, fmt.Errorf("%s and %s are attached to the same instance %s", slots[0].interfaceID, slots[1].interfaceID, slots[0].instanceID)
+	}
+
+	c.logger.Printf("--> Swapping: %s <-> %s\n", slots[0], slots[1])
+
+	for _, s := range slots {
+		if _, err := c.DetachENIWithWaiterWithContext(ctx, &DetachENIParam{
+			InterfaceID:      s.interfaceID,
+			Force:            p.ForceDetach,
+			ForceGracePeriod: p.ForceDetachGracePeriod,
+		}, wp); err != nil {
+			return nil, c.restoreSwap(p, slots, wp, err)
+		}
+	}
+
+	enis := make([]*model.ENI, 0, len(slots))
+	for i, s := range slots {
+		other := slots[1-i]
+		eni, err := c.AttachENIWithWaiterWithContext(ctx, &AttachENIParam{
+			InterfaceID: s.interfaceID,
+			InstanceID:  other.instanceID,
+			DeviceIndex: int(other.deviceIndex),
+		}, wp)
+		if err != nil {
+			return nil, c.restoreSwap(p, slots, wp, err)
+		}
+		enis = append(enis, eni)
+	}
+
+	return enis, nil
+}
+
+// restoreSwap puts the ENIs back to where they were before the swap. It first
+// detaches the ENIs that are not there any more, so that neither takes the
+// device index of the other, then reattaches them. Like rollbackGrab, it
+// doesn't inherit the context of the swap.
+func (c *ENIClient) restoreSwap(p *SwapENIParam, slots [2]*swapSlot, wp *WaiterParam, cause error) error {
+	ctx := context.Background()
+	targets := make([]string, 0, len(slots))
+	for _, s := range slots {
+		targets = append(targets, s.String())
+	}
+	rollbackErr := &RollbackError{Err: cause, Target: strings.Join(targets, " and ")}
+
+	c.logger.Printf("--> Restoring: %s\n", rollbackErr.Target)
+
+	for _, s := range slots {
+		eni, err := c.DescribeENIByIDWithContext(ctx, s.interfaceID)
+		if err != nil {
+			rollbackErr.RollbackErr = err
+			return rollbackErr
+		}
+		if eni.AttachedInstanceID() == s.instanceID && eni.AttachedDeviceIndex() == s.deviceIndex {
+			continue
+		}
+		if _, err := c.DetachENIWithWaiterWithContext(ctx, &DetachENIParam{
+			InterfaceID:      s.interfaceID,
+			Force:            p.ForceDetach,
+			ForceGracePeriod: p.ForceDetachGracePeriod,
+		}, wp); err != nil {
+			rollbackErr.RollbackErr = err
+			return rollbackErr
+		}
+	}
+
+	for _, s := range slots {
+		if _, err := c.AttachENIWithWaiterWithContext(ctx, &AttachENIParam{
+			InterfaceID: s.interfaceID,
+			InstanceID:  s.instanceID,
+			DeviceIndex: int(s.deviceIndex),
+		}, wp); err != nil {
+			rollbackErr.RollbackErr = err
+			return rollbackErr
+		}
+	}
+
+	return rollbackErr
+}
@@ -0,0 +1,117 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSwapENIs(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	a, b := "eni-0000000a", "eni-0000000b"
+	mockDescribeInstance(mockEC2, describeInstanceOutput("i-00000001", 0, 1))
+	mockDescribeInstance(mockEC2, describeInstanceOutput("i-00000002", 0, 2))
+	mockDescribeENISequence(mockEC2, a,
+		describeENIAtOutput(a, "i-00000001", 1),
+		describeENIAtOutput(a, "i-00000001", 1),
+		describeENIAtOutput(a, "", 0),
+		describeENIAtOutput(a, "", 0),
+		describeENIAtOutput(a, "i-00000002", 2),
+	)
+	mockDescribeENISequence(mockEC2, b,
+		describeENIAtOutput(b, "i-00000002", 2),
+		describeENIAtOutput(b, "i-00000002", 2),
+		describeENIAtOutput(b, "", 0),
+		describeENIAtOutput(b, "", 0),
+		describeENIAtOutput(b, "i-00000001", 1),
+	)
+	mockEC2.On("DetachNetworkInterfaceWithContext", mock.Anything, mock.Anything).Return(
+		&ec2.DetachNetworkInterfaceOutput{}, nil).Twice()
+	mockAttach(mockEC2, a, "i-00000002", 2, nil)
+	mockAttach(mockEC2, b, "i-00000001", 1, nil)
+
+	enis, err := c.SwapENIsWithContext(context.Background(), &SwapENIParam{InterfaceIDs: [2]string{a, b}},
+		&WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+	assert.NoError(t, err)
+	if assert.Len(t, enis, 2) {
+		assert.Equal(t, "i-00000002", enis[0].AttachedInstanceID())
+		assert.Equal(t, "i-00000001", enis[1].AttachedInstanceID())
+	}
+	mockEC2.AssertExpectations(t)
+}
+
+func TestSwapENIsRestore(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	a, b := "eni-0000000a", "eni-0000000b"
+	mockDescribeInstance(mockEC2, describeInstanceOutput("i-00000001", 0, 1))
+	mockDescribeInstance(mockEC2, describeInstanceOutput("i-00000002", 0, 2))
+	// eni-0000000a is swapped, then detached and reattached to i-00000001
+	mockDescribeENISequence(mockEC2, a,
+		describeENIAtOutput(a, "i-00000001", 1),
+		describeENIAtOutput(a, "i-00000001", 1),
+		describeENIAtOutput(a, "", 0),
+		describeENIAtOutput(a, "", 0),
+		describeENIAtOutput(a, "i-00000002", 2),
+		describeENIAtOutput(a, "i-00000002", 2),
+		describeENIAtOutput(a, "i-00000002", 2),
+		describeENIAtOutput(a, "", 0),
+		describeENIAtOutput(a, "", 0),
+		describeENIAtOutput(a, "i-00000001", 1),
+	)
+	// eni-0000000b fails to be attached to i-00000001, then is reattached to i-00000002
+	mockDescribeENISequence(mockEC2, b,
+		describeENIAtOutput(b, "i-00000002", 2),
+		describeENIAtOutput(b, "i-00000002", 2),
+		describeENIAtOutput(b, "", 0),
+		describeENIAtOutput(b, "", 0),
+		describeENIAtOutput(b, "", 0),
+		describeENIAtOutput(b, "", 0),
+		describeENIAtOutput(b, "", 0),
+		describeENIAtOutput(b, "i-00000002", 2),
+	)
+	mockEC2.On("DetachNetworkInterfaceWithContext", mock.Anything, mock.Anything).Return(
+		&ec2.DetachNetworkInterfaceOutput{}, nil).Times(3)
+
+	attachErr := errors.New("InvalidParameterCombination")
+	mockAttach(mockEC2, a, "i-00000002", 2, nil)
+	mockAttach(mockEC2, b, "i-00000001", 1, attachErr)
+	mockAttach(mockEC2, a, "i-00000001", 1, nil)
+	mockAttach(mockEC2, b, "i-00000002", 2, nil)
+
+	enis, err := c.SwapENIsWithContext(context.Background(), &SwapENIParam{InterfaceIDs: [2]string{a, b}},
+		&WaiterParam{MaxAttempts: 5, Backoff: &ConstantBackoff{Interval: time.Millisecond}})
+
+	assert.Nil(t, enis)
+	var rollbackErr *RollbackError
+	if assert.True(t, errors.As(err, &rollbackErr)) {
+		assert.True(t, rollbackErr.RolledBack())
+		assert.Equal(t, "eni-0000000a on i-00000001 (device index 1) and eni-0000000b on i-00000002 (device index 2)", rollbackErr.Target)
+	}
+	assert.True(t, errors.Is(err, attachErr))
+	mockEC2.AssertExpectations(t)
+}
+
+func TestSwapENIsOnSameInstance(t *testing.T) {
+	mockEC2 := new(EC2API)
+	c := newClient(mockEC2)
+
+	mockDescribeInstance(mockEC2, describeInstanceOutput("i-00000001", 0, 1, 2))
+	mockDescribeENISequence(mockEC2, "eni-0000000a", describeENIAtOutput("eni-0000000a", "i-00000001", 1))
+	mockDescribeENISequence(mockEC2, "eni-0000000b", describeENIAtOutput("eni-0000000b", "i-00000001", 2))
+
+	_, err := c.SwapENIsWithContext(context.Background(), &SwapENIParam{InterfaceIDs: [2]string{"eni-0000000a", "eni-0000000b"}},
+		&WaiterParam{MaxAttempts: 5, IntervalSec: 1})
+
+	assert.EqualError(t, err, "eni-0000000a and eni-0000000b are attached to the same instance i-00000001")
+	mockEC2.AssertNotCalled(t, "DetachNetworkInterfaceWithContext", mock.Anything, mock.Anything)
+}
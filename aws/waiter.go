@@ -0,0 +1,107 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/yuuki/grabeni/timeutil"
+)
+
+type WaiterParam struct {
+	// MaxAttempts is the maximum number of polls. Zero means unlimited, which
+	// is only allowed together with Timeout.
+	MaxAttempts int
+	// IntervalSec is the constant polling interval used when Backoff is nil.
+	IntervalSec int
+	// Backoff decides the polling intervals if set.
+	Backoff BackoffPolicy
+	// Timeout bounds the wall-clock time of the whole operation if positive,
+	// including all of its waits and API calls.
+	Timeout time.Duration
+}
+
+func validateWaitUntilParam(p *WaiterParam) error {
+	if p == nil {
+		return fmt.Errorf("WaitUntilParam require")
+	}
+
+	if p.MaxAttempts < 0 || (p.MaxAttempts == 0 && p.Timeout <= 0) {
+		return fmt.Errorf("invalid max attempts (%d)", p.MaxAttempts)
+	}
+	if p.Backoff == nil && p.IntervalSec <= 0 {
+		return fmt.Errorf("invalid interval (%d) seconds", p.IntervalSec)
+	}
+	if p.Timeout < 0 {
+		return fmt.Errorf("invalid timeout (%s)", p.Timeout)
+	}
+
+	return nil
+}
+
+func (p *WaiterParam) backoff() BackoffPolicy {
+	if p.Backoff != nil {
+		return p.Backoff
+	}
+	return &ConstantBackoff{Interval: time.Duration(p.IntervalSec) * time.Second}
+}
+
+// opTimeoutKey marks a context bounded by WaiterParam.Timeout, so that nested
+// operations share the deadline. The value is the timeout, or zero if the
+// caller's own deadline comes first.
+type opTimeoutKey struct{}
+
+// withOpTimeout bounds the whole operation by wp.Timeout. It does nothing if
+// the timeout isn't set or ctx is already bounded by an outer operation.
+func withOpTimeout(ctx context.Context, wp *WaiterParam) (context.Context, context.CancelFunc) {
+	if wp == nil || wp.Timeout <= 0 || ctx.Value(opTimeoutKey{}) != nil {
+		return ctx, func() {}
+	}
+	timeout, deadline := wp.Timeout, time.Now().Add(wp.Timeout)
+	if parent, ok := ctx.Deadline(); ok && !parent.After(deadline) {
+		// The caller's deadline comes first, so it's the caller who cancels.
+		timeout = 0
+	}
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	return context.WithValue(ctx, opTimeoutKey{}, timeout), cancel
+}
+
+// opTimedOut reports whether ctx is done because the deadline made by
+// withOpTimeout has passed, rather than by the caller.
+func opTimedOut(ctx context.Context) (time.Duration, bool) {
+	timeout, _ := ctx.Value(opTimeoutKey{}).(time.Duration)
+	if timeout == 0 || ctx.Err() != context.DeadlineExceeded {
+		return 0, false
+	}
+	return timeout, true
+}
+
+// waitUntil polls cond until it reports true, the attempts run out, or ctx is
+// done. wp.Timeout is the deadline of the whole operation, shared with the
+// caller's other waits.
+func (c *ENIClient) waitUntil(ctx context.Context, op, id string, wp *WaiterParam, cond func(ctx context.Context) (bool, error)) error {
+	ctx, cancel := withOpTimeout(ctx, wp)
+	defer cancel()
+
+	backoff := wp.backoff()
+
+	for i := 0; wp.MaxAttempts == 0 || i < wp.MaxAttempts; i++ {
+		fmt.Fprint(c.logWriter, ".") // use fmt.Fprint because standard log package always newline
+
+		ok, err := cond(ctx)
+		if err == nil && ok {
+			c.logger.Println()
+			return nil
+		}
+		if err == nil {
+			err = timeutil.SleepContext(ctx, backoff.Backoff(i))
+		}
+		if err != nil {
+			c.logger.Println()
+			return wrapContextError(ctx, op, id, err)
+		}
+	}
+
+	c.logger.Println()
+	return &WaitTimeoutError{Op: op, ID: id, Attempts: wp.MaxAttempts}
+}
@@ -0,0 +1,107 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitUntil(t *testing.T) {
+	c := newClient(new(EC2API))
+
+	attempts := 0
+	err := c.waitUntil(context.Background(), "attach", "eni-00000001", &WaiterParam{
+		MaxAttempts: 5,
+		Backoff:     &ConstantBackoff{Interval: time.Millisecond},
+	}, func(ctx context.Context) (bool, error) {
+		attempts++
+		return attempts == 3, nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestWaitUntilOverMaxAttempts(t *testing.T) {
+	c := newClient(new(EC2API))
+
+	attempts := 0
+	err := c.waitUntil(context.Background(), "attach", "eni-00000001", &WaiterParam{
+		MaxAttempts: 3,
+		Backoff:     &ConstantBackoff{Interval: time.Millisecond},
+	}, func(ctx context.Context) (bool, error) {
+		attempts++
+		return false, nil
+	})
+
+	assert.EqualError(t, err, "attach eni-00000001 error: over 3 polling attempts")
+	assert.Equal(t, 3, attempts)
+}
+
+func TestWaitUntilTimeout(t *testing.T) {
+	c := newClient(new(EC2API))
+
+	err := c.waitUntil(context.Background(), "detach", "eni-00000001", &WaiterParam{
+		Backoff: &ExponentialJitterBackoff{Initial: time.Millisecond, Max: 5 * time.Millisecond},
+		Timeout: 30 * time.Millisecond,
+	}, func(ctx context.Context) (bool, error) {
+		return false, nil
+	})
+
+	assert.EqualError(t, err, "detach eni-00000001 error: timed out after 30ms")
+	var timeout *WaitTimeoutError
+	assert.True(t, errors.As(err, &timeout))
+	var canceled *CanceledError
+	assert.False(t, errors.As(err, &canceled))
+}
+
+func TestWaitUntilSharesOperationTimeout(t *testing.T) {
+	c := newClient(new(EC2API))
+	wp := &WaiterParam{
+		Backoff: &ConstantBackoff{Interval: time.Millisecond},
+		Timeout: 100 * time.Millisecond,
+	}
+
+	ctx, cancel := withOpTimeout(context.Background(), wp)
+	defer cancel()
+
+	start := time.Now()
+	err := c.waitUntil(ctx, "detach", "eni-00000001", wp, func(ctx context.Context) (bool, error) {
+		return time.Since(start) >= 80*time.Millisecond, nil
+	})
+	assert.NoError(t, err)
+
+	err = c.waitUntil(ctx, "attach", "eni-00000001", wp, func(ctx context.Context) (bool, error) {
+		return false, nil
+	})
+	assert.EqualError(t, err, "attach eni-00000001 error: timed out after 100ms")
+	assert.True(t, time.Since(start) < 180*time.Millisecond, "the second wait must not restart the timeout")
+}
+
+func TestWaitUntilCallerDeadline(t *testing.T) {
+	c := newClient(new(EC2API))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := c.waitUntil(ctx, "detach", "eni-00000001", &WaiterParam{
+		Backoff: &ConstantBackoff{Interval: time.Millisecond},
+		Timeout: time.Second,
+	}, func(ctx context.Context) (bool, error) {
+		return false, nil
+	})
+
+	var canceled *CanceledError
+	assert.True(t, errors.As(err, &canceled))
+}
+
+func TestValidateWaitUntilParam(t *testing.T) {
+	assert.Error(t, validateWaitUntilParam(nil))
+	assert.Error(t, validateWaitUntilParam(&WaiterParam{MaxAttempts: 0, IntervalSec: 1}))
+	assert.Error(t, validateWaitUntilParam(&WaiterParam{MaxAttempts: 1, IntervalSec: 0}))
+	assert.NoError(t, validateWaitUntilParam(&WaiterParam{MaxAttempts: 1, IntervalSec: 1}))
+	assert.NoError(t, validateWaitUntilParam(&WaiterParam{Timeout: time.Second, Backoff: &ConstantBackoff{Interval: time.Second}}))
+}
@@ -32,11 +32,21 @@ Run '{{.Name}} COMMAND --help' for more information on a command.
 `
 
 var commandArgs = map[string]string{
-	"status": commands.CommandArgStatus,
-	"list":   commands.CommandArgList,
-	"attach": commands.CommandArgAttach,
-	"detach": commands.CommandArgDetach,
-	"grab":   commands.CommandArgGrab,
+	"status":            commands.CommandArgStatus,
+	"list":              commands.CommandArgList,
+	"instances":         commands.CommandArgInstances,
+	"eips":              commands.CommandArgEIPs,
+	"attach":            commands.CommandArgAttach,
+	"detach":            commands.CommandArgDetach,
+	"grab":              commands.CommandArgGrab,
+	"grab-ip":           commands.CommandArgGrabIP,
+	"grab-eip":          commands.CommandArgGrabEIP,
+	"grab-route":        commands.CommandArgGrabRoute,
+	"evacuate":          commands.CommandArgEvacuate,
+	"swap":              commands.CommandArgSwap,
+	"watchd":            commands.CommandArgWatchd,
+	"keepalived-notify": commands.CommandArgKeepalivedNotify,
+	"check":             commands.CommandArgCheck,
 }
 
 func setDebugOutputLevel() {
@@ -60,7 +70,7 @@ func setDebugOutputLevel() {
 func init() {
 	setDebugOutputLevel()
 	argsTemplate := "{{if false}}"
-	for _, command := range append(commands.Commands) {
+	for _, command := range commands.Commands {
 		argsTemplate = argsTemplate + fmt.Sprintf("{{else if (eq .Name %q)}}%s %s", command.Name, command.Name, commandArgs[command.Name])
 	}
 	argsTemplate = argsTemplate + "{{end}}"
@@ -2,8 +2,8 @@ package commands
 
 import (
 	"errors"
-	"fmt"
 	"os"
+	"strings"
 
 	"github.com/Songmu/prompter"
 	"github.com/urfave/cli"
@@ -12,63 +12,86 @@ import (
 	"github.com/yuuki/grabeni/log"
 )
 
-var CommandArgAttach = "[--instanceid INSTANCE_ID] [--deviceindex DEVICE_INDEX] [--max-attempts MAX_ATTEMPTS] [--interval INTERVAL] ENI_ID"
+var CommandArgAttach = "[--dry-run] [--instanceid INSTANCE_ID] [--deviceindex DEVICE_INDEX|auto] [--max-attempts MAX_ATTEMPTS] [--interval INTERVAL] [--timeout TIMEOUT] [--initial-interval INTERVAL] [--max-interval INTERVAL] [--selector KEY=VALUE[,KEY=VALUE]] [ENI_ID]"
 var CommandAttach = cli.Command{
 	Name:   "attach",
 	Usage:  "Attach ENI",
 	Action: fatalOnError(doAttach),
-	Flags: []cli.Flag{
-		cli.IntFlag{Name: "d, deviceindex", Value: 1, Usage: "device index number"},
-		cli.StringFlag{Name: "I, instanceid", Usage: "attach-targeted instance id"},
-		cli.IntFlag{Name: "n, max-attempts", Value: 10, Usage: "the maximum number of attempts to poll the change of ENI status (default: 10)"},
-		cli.IntFlag{Name: "i, interval", Value: 2, Usage: "the interval in seconds to poll the change of ENI status (default: 2)"},
+	Flags: append([]cli.Flag{
+		cli.StringFlag{Name: "d, deviceindex", Value: "1", Usage: "device index number, or \"auto\" to pick the lowest free one"},
+		cli.StringFlag{Name: "I, instanceid", Usage: "attach-targeted instance id or Name tag"},
 		cli.BoolFlag{Name: "f, force", Usage: "run without y/n acknowledgement (default: false)"},
-	},
+		cli.BoolFlag{Name: "dry-run", Usage: "check whether the request would succeed without changing anything (default: false)"},
+		cli.StringFlag{Name: "selector", Usage: "attach all ENIs having the tags instead of ENI_ID"},
+	}, waiterFlags...),
 }
 
 func doAttach(c *cli.Context) error {
-	if len(c.Args()) < 1 {
+	selector := c.String("selector")
+	if selector == "" && len(c.Args()) < 1 {
 		cli.ShowCommandHelp(c, "attach")
-		return errors.New("ENI_ID required")
+		return errors.New("ENI_ID or --selector required")
+	}
+	if selector != "" && len(c.Args()) > 0 {
+		cli.ShowCommandHelp(c, "attach")
+		return errors.New("ENI_ID and --selector are exclusive")
+	}
+
+	deviceIndex, err := parseDeviceIndex(c.String("deviceindex"))
+	if err != nil {
+		return err
 	}
 
-	eniID := c.Args().Get(0)
+	awscli := aws.NewENIClient().WithLogWriter(os.Stdout)
+
+	eniIDs, err := resolveENIIDs(c, awscli, []string{c.Args().First()})
+	if err != nil {
+		return err
+	}
+	if deviceIndex, err = multiDeviceIndex(c, deviceIndex, len(eniIDs)); err != nil {
+		return err
+	}
 
-	if !c.Bool("force") {
-		if !prompter.YN("Attach following ENI.\n  "+eniID+"\nAre you sure?", true) {
+	if !c.Bool("force") && !c.Bool("dry-run") {
+		if !prompter.YN("Attach following ENI.\n  "+strings.Join(eniIDs, "\n  ")+"\nAre you sure?", true) {
 			log.Infof("Attachment is canceled")
 			return nil
 		}
 	}
 
-	var instanceID string
-	if instanceID = c.String("instanceid"); instanceID == "" {
-		var err error
-		instanceID, err = aws.NewMetaDataClient().GetInstanceID()
-		if err != nil {
-			return err
-		}
-	}
+	ctx, cancel := newSignalContext()
+	defer cancel()
 
-	awscli := aws.NewENIClient().WithLogWriter(os.Stdout)
+	instanceID, err := targetInstanceID(ctx, c, awscli)
+	if err != nil {
+		return err
+	}
 
 	// Check instance id existence
-	instance, err := awscli.DescribeInstanceByID(instanceID)
+	instance, err := awscli.DescribeInstanceByIDWithContext(ctx, instanceID)
 	if err != nil {
 		return err
 	}
-	if instance == nil {
-		return fmt.Errorf("No such instance %s", instanceID)
+
+	if selector != "" {
+		if c.Bool("dry-run") {
+			log.Infof("dry-run: nothing is attached actually")
+		}
+		return reportENIResults(awscli.AttachENIsWithContext(ctx, eniIDs, &aws.AttachENIParam{
+			InstanceID:  *instance.InstanceId,
+			DeviceIndex: deviceIndex,
+			DryRun:      c.Bool("dry-run"),
+		}, newWaiterParam(c)))
 	}
 
-	eni, err := awscli.AttachENIWithWaiter(&aws.AttachENIParam{
+	eniID := eniIDs[0]
+
+	eni, err := awscli.AttachENIWithWaiterWithContext(ctx, &aws.AttachENIParam{
 		InterfaceID: eniID,
 		InstanceID:  *instance.InstanceId,
-		DeviceIndex: c.Int("deviceindex"),
-	}, &aws.WaiterParam{
-		MaxAttempts: c.Int("max-attempts"),
-		IntervalSec: c.Int("interval"),
-	})
+		DeviceIndex: deviceIndex,
+		DryRun:      c.Bool("dry-run"),
+	}, newWaiterParam(c))
 	if err != nil {
 		return err
 	}
@@ -76,6 +99,10 @@ func doAttach(c *cli.Context) error {
 		log.Infof("%s already attached to instance %s", eniID, instanceID)
 		return nil
 	}
+	if c.Bool("dry-run") {
+		log.Infof("dry-run: %s would be attached to instance %s", eniID, instanceID)
+		return nil
+	}
 
 	log.Infof("%s attached to instance %s", eniID, instanceID)
 
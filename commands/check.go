@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli"
+
+	"github.com/yuuki/grabeni/aws"
+	"github.com/yuuki/grabeni/format"
+)
+
+var CommandArgCheck = "[--instanceid INSTANCE_ID] [--deviceindex DEVICE_INDEX|auto] ENI_ID"
+var CommandCheck = cli.Command{
+	Name:   "check",
+	Usage:  "Check whether ENI can be grabbed to the instance",
+	Action: fatalOnError(doCheck),
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "d, deviceindex", Value: "1", Usage: "device index number, or \"auto\" to pick the lowest free one"},
+		cli.StringFlag{Name: "I, instanceid", Usage: "attach-targeted instance id or Name tag"},
+	},
+}
+
+func doCheck(c *cli.Context) error {
+	if len(c.Args()) < 1 {
+		cli.ShowCommandHelp(c, "check")
+		return errors.New("ENI_ID required")
+	}
+
+	eniID := c.Args().Get(0)
+	deviceIndex, err := parseDeviceIndex(c.String("deviceindex"))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := newSignalContext()
+	defer cancel()
+
+	awscli := aws.NewENIClient().WithLogWriter(os.Stdout)
+
+	instanceID, err := targetInstanceID(ctx, c, awscli)
+	if err != nil {
+		return err
+	}
+	if eniID, err = awscli.ResolveENIIDWithContext(ctx, eniID); err != nil {
+		return err
+	}
+
+	results, err := awscli.CheckGrabENIWithContext(ctx, &aws.GrabENIParam{
+		InterfaceID: eniID,
+		InstanceID:  instanceID,
+		DeviceIndex: deviceIndex,
+	})
+	if err != nil {
+		return err
+	}
+
+	format.PrintCheckResults(os.Stdout, results)
+
+	failed := 0
+	for _, r := range results {
+		if !r.OK {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(results))
+	}
+
+	return nil
+}
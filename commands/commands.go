@@ -1,25 +1,238 @@
 package commands
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/urfave/cli"
 
+	"github.com/yuuki/grabeni/aws"
+	"github.com/yuuki/grabeni/format"
 	"github.com/yuuki/grabeni/log"
 )
 
 var Commands = []cli.Command{
 	CommandStatus,
 	CommandList,
+	CommandInstances,
+	CommandEIPs,
 	CommandAttach,
 	CommandDetach,
 	CommandGrab,
+	CommandGrabIP,
+	CommandGrabEIP,
+	CommandGrabRoute,
+	CommandEvacuate,
+	CommandSwap,
+	CommandWatchd,
+	CommandKeepalivedNotify,
+	CommandCheck,
+}
+
+var waiterFlags = []cli.Flag{
+	cli.IntFlag{Name: "n, max-attempts", Value: 10, Usage: "the maximum number of attempts to poll the change of ENI status (default: 10)"},
+	cli.IntFlag{Name: "i, interval", Value: 2, Usage: "the interval in seconds to poll the change of ENI status (default: 2)"},
+	cli.DurationFlag{Name: "timeout", Usage: "the deadline of the whole operation including all of its waits, e.g. 30s; polls without an attempt limit unless --max-attempts is given"},
+	cli.DurationFlag{Name: "initial-interval", Usage: "the first polling interval, e.g. 200ms; enables exponential backoff with jitter instead of --interval"},
+	cli.DurationFlag{Name: "max-interval", Value: 10 * time.Second, Usage: "the upper bound of the exponential backoff interval"},
+}
+
+// newWaiterParam builds a waiter parameter from the flags in waiterFlags.
+func newWaiterParam(c *cli.Context) *aws.WaiterParam {
+	wp := &aws.WaiterParam{
+		MaxAttempts: c.Int("max-attempts"),
+		IntervalSec: c.Int("interval"),
+		Timeout:     c.Duration("timeout"),
+	}
+	if wp.Timeout > 0 && !(c.IsSet("max-attempts") || c.IsSet("n")) {
+		wp.MaxAttempts = 0
+	}
+	if initial := c.Duration("initial-interval"); initial > 0 {
+		wp.Backoff = &aws.ExponentialJitterBackoff{
+			Initial: initial,
+			Max:     c.Duration("max-interval"),
+		}
+	}
+	return wp
+}
+
+// Exit codes per error class
+const (
+	exitCodeError           = 1
+	exitCodeNotFound        = 2
+	exitCodeAlreadyAttached = 3
+	exitCodeTimeout         = 4
+	exitCodeInvalidState    = 5
+	exitCodeCanceled        = 6
+	exitCodeAmbiguous       = 7
+)
+
+func exitCode(err error) int {
+	var (
+		eniNotFound      *aws.ENINotFoundError
+		instanceNotFound *aws.InstanceNotFoundError
+		addressNotFound  *aws.AddressNotFoundError
+		routeNotFound    *aws.RouteTableNotFoundError
+		alreadyAttached  *aws.AlreadyAttachedError
+		leaseHeld        *aws.LeaseHeldError
+		timeout          *aws.WaitTimeoutError
+		invalidState     *aws.InvalidStateError
+		limitExceeded    *aws.ENILimitExceededError
+		primaryIP        *aws.PrimaryPrivateIPError
+		canceled         *aws.CanceledError
+		ambiguous        *aws.AmbiguousSelectorError
+	)
+	switch {
+	case errors.As(err, &eniNotFound), errors.As(err, &instanceNotFound), errors.As(err, &addressNotFound),
+		errors.As(err, &routeNotFound):
+		return exitCodeNotFound
+	case errors.As(err, &alreadyAttached), errors.As(err, &leaseHeld):
+		return exitCodeAlreadyAttached
+	case errors.As(err, &timeout):
+		return exitCodeTimeout
+	case errors.As(err, &invalidState), errors.As(err, &limitExceeded), errors.As(err, &primaryIP):
+		return exitCodeInvalidState
+	case errors.As(err, &canceled):
+		return exitCodeCanceled
+	case errors.As(err, &ambiguous):
+		return exitCodeAmbiguous
+	}
+	return exitCodeError
 }
 
 func fatalOnError(command func(context *cli.Context) error) func(context *cli.Context) {
 	return func(context *cli.Context) {
 		if err := command(context); err != nil {
-			log.Error(fmt.Sprintf("error: %s", err.Error()))
+			log.Exit(exitCode(err), fmt.Sprintf("error: %s", err.Error()))
 		}
 	}
 }
+
+// parseTags parses KEY=VALUE pairs, each of which may also be a comma
+// separated list of pairs.
+func parseTags(pairs []string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, pair := range pairs {
+		for _, kv := range strings.Split(pair, ",") {
+			i := strings.Index(kv, "=")
+			if i < 1 {
+				return nil, fmt.Errorf("invalid tag %q: KEY=VALUE required", kv)
+			}
+			tags[kv[:i]] = kv[i+1:]
+		}
+	}
+	return tags, nil
+}
+
+// parseDeviceIndex parses the --deviceindex value, which is either a number or
+// "auto" to pick the lowest free index on the target instance.
+func parseDeviceIndex(s string) (int, error) {
+	if s == "auto" {
+		return aws.DeviceIndexAuto, nil
+	}
+	idx, err := strconv.Atoi(s)
+	if err != nil || idx < 0 {
+		return 0, fmt.Errorf("invalid device index %q: a number or \"auto\" required", s)
+	}
+	return idx, nil
+}
+
+// targetInstanceID resolves --instanceid, which may also be the Name tag of an
+// instance, into an instance ID. It defaults to the instance grabeni runs on.
+func targetInstanceID(ctx context.Context, c *cli.Context, awscli *aws.ENIClient) (string, error) {
+	selector := c.String("instanceid")
+	if selector == "" {
+		return aws.NewMetaDataClient().GetInstanceIDWithContext(ctx)
+	}
+	return awscli.ResolveInstanceIDWithContext(ctx, selector)
+}
+
+// targetENIOrInstance resolves --eni if given, or --instanceid otherwise.
+func targetENIOrInstance(ctx context.Context, c *cli.Context, awscli *aws.ENIClient) (interfaceID, instanceID string, err error) {
+	if selector := c.String("eni"); selector != "" {
+		interfaceID, err = awscli.ResolveENIIDWithContext(ctx, selector)
+		return interfaceID, "", err
+	}
+	instanceID, err = targetInstanceID(ctx, c, awscli)
+	return "", instanceID, err
+}
+
+// resolveENIIDs resolves --selector into the IDs of the ENIs having all the
+// tags, or each of selectors into an ENI ID otherwise. It is called before the
+// y/n acknowledgement, which lists the ENIs.
+func resolveENIIDs(c *cli.Context, awscli *aws.ENIClient, selectors []string) ([]string, error) {
+	ctx, cancel := newSignalContext()
+	defer cancel()
+
+	selector := c.String("selector")
+	if selector == "" {
+		ids := make([]string, 0, len(selectors))
+		for _, s := range selectors {
+			id, err := awscli.ResolveENIIDWithContext(ctx, s)
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+		return ids, nil
+	}
+
+	tags, err := parseTags([]string{selector})
+	if err != nil {
+		return nil, err
+	}
+	ids, err := awscli.DescribeENIIDsByTagsWithContext(ctx, tags)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, &aws.ENINotFoundError{InterfaceID: selector}
+	}
+	return ids, nil
+}
+
+// multiDeviceIndex returns the device index to attach n ENIs at. Several ENIs
+// can't share a device index, so it is "auto" unless n is 1 or --deviceindex
+// is given explicitly, which is then an error.
+func multiDeviceIndex(c *cli.Context, deviceIndex, n int) (int, error) {
+	if n < 2 || deviceIndex == aws.DeviceIndexAuto {
+		return deviceIndex, nil
+	}
+	if c.IsSet("deviceindex") || c.IsSet("d") {
+		return 0, fmt.Errorf("--deviceindex %d can't be shared by %d ENIs: use \"auto\"", deviceIndex, n)
+	}
+	return aws.DeviceIndexAuto, nil
+}
+
+// reportENIResults prints the summary of the operation on several ENIs, and
+// returns an error if any of them failed.
+func reportENIResults(results []*aws.ENIResult) error {
+	format.PrintENIResults(os.Stdout, results)
+
+	var failed []error
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r.Err)
+		}
+	}
+	if len(failed) == 1 {
+		return failed[0]
+	}
+	if len(failed) > 1 {
+		return fmt.Errorf("%d of %d ENIs failed", len(failed), len(results))
+	}
+	return nil
+}
+
+// newSignalContext returns a context that is canceled on SIGINT or SIGTERM,
+// so that an in-flight operation stops polling and returns cleanly.
+func newSignalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
@@ -3,6 +3,8 @@ package commands
 import (
 	"errors"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/Songmu/prompter"
 	"github.com/urfave/cli"
@@ -11,39 +13,65 @@ import (
 	"github.com/yuuki/grabeni/log"
 )
 
-var CommandArgDetach = "[--max-attempts MAX_ATTEMPTS] [--interval INTERVAL] ENI_ID"
+var CommandArgDetach = "[--dry-run] [--force-detach] [--force-detach-grace-period PERIOD] [--max-attempts MAX_ATTEMPTS] [--interval INTERVAL] [--timeout TIMEOUT] [--initial-interval INTERVAL] [--max-interval INTERVAL] [--selector KEY=VALUE[,KEY=VALUE]] [ENI_ID]"
 var CommandDetach = cli.Command{
 	Name:   "detach",
 	Usage:  "Detach ENI",
 	Action: fatalOnError(doDetach),
-	Flags: []cli.Flag{
-		cli.IntFlag{Name: "n, max-attempts", Value: 10, Usage: "the maximum number of attempts to poll the change of ENI status (default: 10)"},
-		cli.IntFlag{Name: "i, interval", Value: 2, Usage: "the interval in seconds to poll the change of ENI status (default: 2)"},
+	Flags: append([]cli.Flag{
 		cli.BoolFlag{Name: "f, force", Usage: "run without y/n acknowledgement (default: false)"},
-	},
+		cli.BoolFlag{Name: "dry-run", Usage: "check whether the request would succeed without changing anything (default: false)"},
+		cli.BoolFlag{Name: "force-detach", Usage: "force detaching if a normal detach hasn't completed within --force-detach-grace-period (default: false)"},
+		cli.DurationFlag{Name: "force-detach-grace-period", Value: 10 * time.Second, Usage: "the time to wait for a normal detach before forcing it"},
+		cli.StringFlag{Name: "selector", Usage: "detach all ENIs having the tags instead of ENI_ID"},
+	}, waiterFlags...),
 }
 
 func doDetach(c *cli.Context) error {
-	if len(c.Args()) < 1 {
+	selector := c.String("selector")
+	if selector == "" && len(c.Args()) < 1 {
 		cli.ShowCommandHelp(c, "detach")
-		return errors.New("ENI_ID required")
+		return errors.New("ENI_ID or --selector required")
+	}
+	if selector != "" && len(c.Args()) > 0 {
+		cli.ShowCommandHelp(c, "detach")
+		return errors.New("ENI_ID and --selector are exclusive")
 	}
 
-	eniID := c.Args().Get(0)
+	awscli := aws.NewENIClient().WithLogWriter(os.Stdout)
+
+	eniIDs, err := resolveENIIDs(c, awscli, []string{c.Args().First()})
+	if err != nil {
+		return err
+	}
 
-	if !c.Bool("force") {
-		if !prompter.YN("Detach following ENI.\n  "+eniID+"\nAre you sure?", true) {
+	if !c.Bool("force") && !c.Bool("dry-run") {
+		if !prompter.YN("Detach following ENI.\n  "+strings.Join(eniIDs, "\n  ")+"\nAre you sure?", true) {
 			log.Infof("detachment is canceled")
 			return nil
 		}
 	}
 
-	eni, err := aws.NewENIClient().WithLogWriter(os.Stdout).DetachENIWithWaiter(&aws.DetachENIParam{
-		InterfaceID: eniID,
-	}, &aws.WaiterParam{
-		MaxAttempts: c.Int("max-attempts"),
-		IntervalSec: c.Int("interval"),
-	})
+	ctx, cancel := newSignalContext()
+	defer cancel()
+
+	param := &aws.DetachENIParam{
+		Force:            c.Bool("force-detach"),
+		ForceGracePeriod: c.Duration("force-detach-grace-period"),
+		DryRun:           c.Bool("dry-run"),
+	}
+
+	if selector != "" {
+		if c.Bool("dry-run") {
+			log.Infof("dry-run: nothing is detached actually")
+		}
+		return reportENIResults(awscli.DetachENIsWithContext(ctx, eniIDs, param, newWaiterParam(c)))
+	}
+
+	eniID := eniIDs[0]
+	param.InterfaceID = eniID
+
+	eni, err := awscli.DetachENIWithWaiterWithContext(ctx, param, newWaiterParam(c))
 	if err != nil {
 		return err
 	}
@@ -51,6 +79,10 @@ func doDetach(c *cli.Context) error {
 		log.Infof("%s already detached", eniID)
 		return nil
 	}
+	if c.Bool("dry-run") {
+		log.Infof("dry-run: %s would be detached from instance %s", eniID, eni.AttachedInstanceID())
+		return nil
+	}
 
 	log.Infof("%s detached", eniID)
 
@@ -0,0 +1,31 @@
+package commands
+
+import (
+	"os"
+
+	"github.com/urfave/cli"
+
+	"github.com/yuuki/grabeni/aws"
+	"github.com/yuuki/grabeni/format"
+)
+
+var CommandArgEIPs = ""
+var CommandEIPs = cli.Command{
+	Name:   "eips",
+	Usage:  "List Elastic IPs with the ENIs and the instances associated",
+	Action: fatalOnError(doEIPs),
+}
+
+func doEIPs(c *cli.Context) error {
+	ctx, cancel := newSignalContext()
+	defer cancel()
+
+	addrs, err := aws.NewENIClient().WithLogWriter(os.Stdout).DescribeAddressesWithContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	format.PrintAddresses(os.Stdout, addrs)
+
+	return nil
+}
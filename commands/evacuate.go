@@ -0,0 +1,107 @@
+package commands
+
+import (
+	"os"
+	"time"
+
+	"github.com/Songmu/prompter"
+	"github.com/urfave/cli"
+
+	"github.com/yuuki/grabeni/aws"
+	"github.com/yuuki/grabeni/aws/model"
+	"github.com/yuuki/grabeni/format"
+	"github.com/yuuki/grabeni/log"
+)
+
+var CommandArgEvacuate = "[--instanceid INSTANCE_ID] [--standby INSTANCE_ID] [--concurrency N] [--force-detach] [--force-detach-grace-period PERIOD] [--max-attempts MAX_ATTEMPTS] [--interval INTERVAL] [--timeout TIMEOUT] [--initial-interval INTERVAL] [--max-interval INTERVAL]"
+var CommandEvacuate = cli.Command{
+	Name:   "evacuate",
+	Usage:  "Detach all ENIs but the primary one from instance, or move them to a standby instance",
+	Action: fatalOnError(doEvacuate),
+	Flags: append([]cli.Flag{
+		cli.StringFlag{Name: "I, instanceid", Usage: "instance id or Name tag to evacuate (default: the instance grabeni runs on)"},
+		cli.StringFlag{Name: "standby", Usage: "instance id or Name tag to move the ENIs to instead of only detaching them"},
+		cli.IntFlag{Name: "concurrency", Value: 4, Usage: "the maximum number of ENIs moved to --standby at once"},
+		cli.BoolFlag{Name: "f, force", Usage: "run without y/n acknowledgement (default: false)"},
+		cli.BoolFlag{Name: "force-detach", Usage: "force detaching if a normal detach hasn't completed within --force-detach-grace-period (default: false)"},
+		cli.DurationFlag{Name: "force-detach-grace-period", Value: 10 * time.Second, Usage: "the time to wait for a normal detach before forcing it"},
+	}, waiterFlags...),
+}
+
+func doEvacuate(c *cli.Context) error {
+	awscli := aws.NewENIClient().WithLogWriter(os.Stdout)
+
+	param, before, err := evacuation(c, awscli)
+	if err != nil {
+		return err
+	}
+	if len(before) == 0 {
+		log.Infof("no ENI to evacuate from instance %s", param.InstanceID)
+		return nil
+	}
+
+	log.Infof("before:")
+	format.PrintENIs(os.Stdout, before)
+
+	if !c.Bool("force") {
+		msg := "Detach the ENIs above from " + param.InstanceID
+		if param.StandbyInstanceID != "" {
+			msg = "Move the ENIs above from " + param.InstanceID + " to " + param.StandbyInstanceID
+		}
+		if !prompter.YN(msg+".\nAre you sure?", true) {
+			log.Infof("Evacuation is canceled")
+			return nil
+		}
+	}
+
+	ctx, cancel := newSignalContext()
+	defer cancel()
+
+	results, err := awscli.EvacuateENIsWithContext(ctx, param, newWaiterParam(c))
+	if err != nil {
+		return err
+	}
+
+	after := make([]*model.ENI, 0, len(results))
+	for _, r := range results {
+		eni, err := awscli.DescribeENIByIDWithContext(ctx, r.InterfaceID)
+		if err != nil {
+			return err
+		}
+		after = append(after, eni)
+	}
+	log.Infof("after:")
+	format.PrintENIs(os.Stdout, after)
+
+	return reportENIResults(results)
+}
+
+// evacuation resolves the instance to evacuate and the standby instance, and
+// describes the ENIs to evacuate for the y/n acknowledgement.
+func evacuation(c *cli.Context, awscli *aws.ENIClient) (*aws.EvacuateParam, []*model.ENI, error) {
+	ctx, cancel := newSignalContext()
+	defer cancel()
+
+	instanceID, err := targetInstanceID(ctx, c, awscli)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	param := &aws.EvacuateParam{
+		InstanceID:             instanceID,
+		ForceDetach:            c.Bool("force-detach"),
+		ForceDetachGracePeriod: c.Duration("force-detach-grace-period"),
+		Concurrency:            c.Int("concurrency"),
+	}
+	if standby := c.String("standby"); standby != "" {
+		if param.StandbyInstanceID, err = awscli.ResolveInstanceIDWithContext(ctx, standby); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	enis, err := awscli.DescribeSecondaryENIsWithContext(ctx, instanceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return param, enis, nil
+}
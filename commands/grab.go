@@ -1,9 +1,11 @@
 package commands
 
 import (
+	"context"
 	"errors"
-	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/Songmu/prompter"
 	"github.com/urfave/cli"
@@ -12,63 +14,122 @@ import (
 	"github.com/yuuki/grabeni/log"
 )
 
-var CommandArgGrab = "[--instanceid INSTANCE_ID] [--deviceindex DEVICE_INDEX] [--max-attempts MAX_ATTEMPTS] [--interval INTERVAL] ENI_ID"
+var CommandArgGrab = "[--dry-run] [--instanceid INSTANCE_ID] [--deviceindex DEVICE_INDEX|auto] [--preserve-deviceindex] [--force-detach] [--force-detach-grace-period PERIOD] [--rollback] [--max-attempts MAX_ATTEMPTS] [--interval INTERVAL] [--timeout TIMEOUT] [--initial-interval INTERVAL] [--max-interval INTERVAL] [--concurrency N] [--atomic] [--lease-ttl TTL] [--lease-settle-delay DELAY] [--steal] [--fence stop|isolate] [--quarantine-sg SECURITY_GROUP_ID] [--skip-fence-on-error] [--selector KEY=VALUE[,KEY=VALUE]] [ENI_ID...]"
 var CommandGrab = cli.Command{
 	Name:   "grab",
 	Usage:  "Detach and attach ENI whether the eni has already attached or not.",
 	Action: fatalOnError(doGrab),
-	Flags: []cli.Flag{
-		cli.IntFlag{Name: "d, deviceindex", Value: 1, Usage: "device index number"},
-		cli.StringFlag{Name: "I, instanceid", Usage: "attach-targeted instance id"},
-		cli.IntFlag{Name: "n, max-attempts", Value: 10, Usage: "the maximum number of attempts to poll the change of ENI status (default: 10)"},
-		cli.IntFlag{Name: "i, interval", Value: 2, Usage: "the interval in seconds to poll the change of ENI status (default: 2)"},
+	Flags: append([]cli.Flag{
+		cli.StringFlag{Name: "d, deviceindex", Value: "1", Usage: "device index number, or \"auto\" to pick the lowest free one"},
+		cli.StringFlag{Name: "I, instanceid", Usage: "attach-targeted instance id or Name tag"},
+		cli.BoolFlag{Name: "preserve-deviceindex", Usage: "attach ENI at the device index it had on the previous instance if free there (default: false)"},
 		cli.BoolFlag{Name: "f, force", Usage: "run without y/n acknowledgement (default: false)"},
-	},
+		cli.BoolFlag{Name: "dry-run", Usage: "check whether the request would succeed without changing anything (default: false)"},
+		cli.BoolFlag{Name: "force-detach", Usage: "force detaching if a normal detach hasn't completed within --force-detach-grace-period (default: false)"},
+		cli.DurationFlag{Name: "force-detach-grace-period", Value: 10 * time.Second, Usage: "the time to wait for a normal detach before forcing it"},
+		cli.BoolFlag{Name: "rollback", Usage: "reattach ENI to the previous instance if attaching fails (default: false)"},
+		cli.StringFlag{Name: "selector", Usage: "grab all ENIs having the tags instead of ENI_ID"},
+		cli.IntFlag{Name: "concurrency", Value: 4, Usage: "the maximum number of ENIs grabbed at once"},
+		cli.BoolFlag{Name: "atomic", Usage: "move all ENIs back to where they were if any of them fails to be grabbed (default: false)"},
+		cli.DurationFlag{Name: "lease-ttl", Usage: "take a lease of the ENI in its tags for the TTL before detaching, backing off while another instance holds one (default: no lease)"},
+		cli.DurationFlag{Name: "lease-settle-delay", Value: aws.DefaultLeaseSettleDelay, Usage: "the time to wait before verifying that the lease hasn't been overwritten by a concurrent grab"},
+		cli.BoolFlag{Name: "steal", Usage: "take the lease even if another instance holds it (default: false)"},
+		cli.StringFlag{Name: "fence", Usage: "stop or isolate the instance the ENI is attached to before detaching it (default: no fencing)"},
+		cli.StringFlag{Name: "quarantine-sg", Usage: "the security group to isolate the other ENIs of the previous instance into with --fence isolate"},
+		cli.BoolFlag{Name: "skip-fence-on-error", Usage: "go on grabbing even if fencing fails (default: false)"},
+	}, waiterFlags...),
 }
 
 func doGrab(c *cli.Context) error {
-	if len(c.Args()) < 1 {
+	selector := c.String("selector")
+	if selector == "" && len(c.Args()) < 1 {
 		cli.ShowCommandHelp(c, "grab")
-		return errors.New("ENI_ID required")
+		return errors.New("ENI_ID or --selector required")
+	}
+	if selector != "" && len(c.Args()) > 0 {
+		cli.ShowCommandHelp(c, "grab")
+		return errors.New("ENI_ID and --selector are exclusive")
 	}
 
-	eniID := c.Args().Get(0)
-
-	if !c.Bool("force") {
-		if !prompter.YN("Grab following ENI.\n  "+eniID+"\nAre you sure?", true) {
-			log.Infof("Grabbing is canceled")
-			return nil
-		}
+	deviceIndex, err := parseDeviceIndex(c.String("deviceindex"))
+	if err != nil {
+		return err
 	}
 
-	var instanceID string
-	if instanceID = c.String("instanceid"); instanceID == "" {
-		var err error
-		instanceID, err = aws.NewMetaDataClient().GetInstanceID()
-		if err != nil {
+	if fence := c.String("fence"); fence != "" {
+		if err := aws.ValidateFenceMethod(fence, c.String("quarantine-sg")); err != nil {
 			return err
 		}
 	}
 
 	awscli := aws.NewENIClient().WithLogWriter(os.Stdout)
 
-	// Check instance id existence
-	instance, err := awscli.DescribeInstanceByID(instanceID)
+	eniIDs, err := resolveENIIDs(c, awscli, c.Args())
+	if err != nil {
+		return err
+	}
+	if deviceIndex, err = multiDeviceIndex(c, deviceIndex, len(eniIDs)); err != nil {
+		return err
+	}
+
+	if !c.Bool("force") && !c.Bool("dry-run") {
+		if !prompter.YN("Grab following ENI.\n  "+strings.Join(eniIDs, "\n  ")+"\nAre you sure?", true) {
+			log.Infof("Grabbing is canceled")
+			return nil
+		}
+	}
+
+	ctx, cancel := newSignalContext()
+	defer cancel()
+
+	instanceID, err := targetInstanceID(ctx, c, awscli)
 	if err != nil {
 		return err
 	}
-	if instance == nil {
-		return fmt.Errorf("No such instance %s", instanceID)
+
+	// Check instance id existence
+	if _, err := awscli.DescribeInstanceByIDWithContext(ctx, instanceID); err != nil {
+		return err
 	}
 
-	eni, err := awscli.GrabENI(&aws.GrabENIParam{
-		InterfaceID: eniID,
-		InstanceID:  instanceID,
-		DeviceIndex: c.Int("deviceindex"),
-	}, &aws.WaiterParam{
-		MaxAttempts: c.Int("max-attempts"),
-		IntervalSec: c.Int("interval"),
-	})
+	param := &aws.GrabENIParam{
+		InstanceID:                instanceID,
+		DeviceIndex:               deviceIndex,
+		PreserveDeviceIndex:       c.Bool("preserve-deviceindex"),
+		ForceDetach:               c.Bool("force-detach"),
+		ForceDetachGracePeriod:    c.Duration("force-detach-grace-period"),
+		Rollback:                  c.Bool("rollback"),
+		LeaseTTL:                  c.Duration("lease-ttl"),
+		LeaseSettleDelay:          c.Duration("lease-settle-delay"),
+		StealLease:                c.Bool("steal"),
+		Fence:                     c.String("fence"),
+		QuarantineSecurityGroupID: c.String("quarantine-sg"),
+		SkipFenceOnError:          c.Bool("skip-fence-on-error"),
+	}
+
+	if selector != "" || len(eniIDs) > 1 {
+		return grabENIs(ctx, c, awscli, &aws.GrabENIsParam{
+			GrabENIParam: *param,
+			InterfaceIDs: eniIDs,
+			Concurrency:  c.Int("concurrency"),
+			Atomic:       c.Bool("atomic"),
+		})
+	}
+
+	eniID := eniIDs[0]
+	param.InterfaceID = eniID
+
+	if c.Bool("dry-run") {
+		plan, err := awscli.PlanGrabENIWithContext(ctx, param)
+		if plan != nil {
+			for _, step := range plan.Steps() {
+				log.Infof("dry-run: %s", step)
+			}
+		}
+		return err
+	}
+
+	eni, err := awscli.GrabENIWithContext(ctx, param, newWaiterParam(c))
 	if err != nil {
 		return err
 	}
@@ -81,3 +142,23 @@ func doGrab(c *cli.Context) error {
 
 	return nil
 }
+
+// grabENIs grabs several ENIs given as arguments or by --selector, or plans it
+// with --dry-run.
+func grabENIs(ctx context.Context, c *cli.Context, awscli *aws.ENIClient, p *aws.GrabENIsParam) error {
+	if c.Bool("dry-run") {
+		plans, err := awscli.PlanGrabENIsWithContext(ctx, p)
+		for _, plan := range plans {
+			for _, step := range plan.Steps() {
+				log.Infof("dry-run: %s", step)
+			}
+		}
+		return err
+	}
+
+	results, err := awscli.GrabENIsWithContext(ctx, p, newWaiterParam(c))
+	if err != nil {
+		return err
+	}
+	return reportENIResults(results)
+}
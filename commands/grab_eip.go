@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Songmu/prompter"
+	"github.com/urfave/cli"
+
+	"github.com/yuuki/grabeni/aws"
+	"github.com/yuuki/grabeni/aws/model"
+	"github.com/yuuki/grabeni/log"
+)
+
+var CommandArgGrabEIP = "[--dry-run] [--eni ENI_ID | --instanceid INSTANCE_ID] [--private-ip IP] [--max-attempts MAX_ATTEMPTS] [--interval INTERVAL] [--timeout TIMEOUT] [--initial-interval INTERVAL] [--max-interval INTERVAL] ALLOC_ID_OR_IP"
+var CommandGrabEIP = cli.Command{
+	Name:   "grab-eip",
+	Usage:  "Associate Elastic IP with the ENI or the instance whether it has already been associated or not",
+	Action: fatalOnError(doGrabEIP),
+	Flags: append([]cli.Flag{
+		cli.StringFlag{Name: "eni", Usage: "associate-targeted ENI id or selector"},
+		cli.StringFlag{Name: "I, instanceid", Usage: "associate-targeted instance id or Name tag, used if --eni is not given"},
+		cli.StringFlag{Name: "private-ip", Usage: "private IP of the ENI to associate with (default: the primary private IP)"},
+		cli.BoolFlag{Name: "f, force", Usage: "run without y/n acknowledgement (default: false)"},
+		cli.BoolFlag{Name: "dry-run", Usage: "check whether the request would succeed without changing anything (default: false)"},
+	}, waiterFlags...),
+}
+
+func doGrabEIP(c *cli.Context) error {
+	if len(c.Args()) < 1 {
+		cli.ShowCommandHelp(c, "grab-eip")
+		return errors.New("ALLOC_ID_OR_IP required")
+	}
+	if c.String("eni") != "" && c.String("instanceid") != "" {
+		return errors.New("--eni and --instanceid are exclusive")
+	}
+
+	address := c.Args().Get(0)
+
+	if !c.Bool("force") && !c.Bool("dry-run") {
+		if !prompter.YN("Grab following Elastic IP.\n  "+address+"\nAre you sure?", true) {
+			log.Infof("Grabbing is canceled")
+			return nil
+		}
+	}
+
+	ctx, cancel := newSignalContext()
+	defer cancel()
+
+	awscli := aws.NewENIClient().WithLogWriter(os.Stdout)
+
+	interfaceID, instanceID, err := targetENIOrInstance(ctx, c, awscli)
+	if err != nil {
+		return err
+	}
+
+	param := &aws.GrabEIPParam{
+		Address:     address,
+		InterfaceID: interfaceID,
+		InstanceID:  instanceID,
+		PrivateIP:   c.String("private-ip"),
+		DryRun:      c.Bool("dry-run"),
+	}
+	target := param.InterfaceID
+	if target == "" {
+		target = "instance " + param.InstanceID
+	}
+
+	move, err := awscli.GrabEIPWithContext(ctx, param, newWaiterParam(c))
+	if err != nil {
+		return err
+	}
+	if move == nil {
+		log.Infof("%s already associated with %s", address, target)
+		return nil
+	}
+
+	log.Infof("previous association: %s", describeAssociation(move.Previous))
+	if c.Bool("dry-run") {
+		log.Infof("dry-run: %s would be associated with %s", address, target)
+		return nil
+	}
+
+	log.Infof("%s associated with %s", address, target)
+
+	return nil
+}
+
+func describeAssociation(addr *model.Address) string {
+	if !addr.Associated() {
+		return "none"
+	}
+	if addr.InstanceID() == "" {
+		return fmt.Sprintf("%s (%s)", addr.NetworkInterfaceID(), addr.PrivateIpAddress())
+	}
+	return fmt.Sprintf("%s (%s) of instance %s", addr.NetworkInterfaceID(), addr.PrivateIpAddress(), addr.InstanceID())
+}
@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"errors"
+	"os"
+
+	"github.com/Songmu/prompter"
+	"github.com/urfave/cli"
+
+	"github.com/yuuki/grabeni/aws"
+	"github.com/yuuki/grabeni/log"
+)
+
+var CommandArgGrabIP = "[--instanceid INSTANCE_ID] [--interfaceid ENI_ID] [--max-attempts MAX_ATTEMPTS] [--interval INTERVAL] [--timeout TIMEOUT] [--initial-interval INTERVAL] [--max-interval INTERVAL] IP"
+var CommandGrabIP = cli.Command{
+	Name:   "grab-ip",
+	Usage:  "Move secondary private IP to the instance from the ENI holding it",
+	Action: fatalOnError(doGrabIP),
+	Flags: append([]cli.Flag{
+		cli.StringFlag{Name: "I, instanceid", Usage: "assign-targeted instance id or Name tag"},
+		cli.StringFlag{Name: "interfaceid", Usage: "assign-targeted ENI id or selector attached to the instance (default: the primary ENI)"},
+		cli.BoolFlag{Name: "f, force", Usage: "run without y/n acknowledgement (default: false)"},
+	}, waiterFlags...),
+}
+
+func doGrabIP(c *cli.Context) error {
+	if len(c.Args()) < 1 {
+		cli.ShowCommandHelp(c, "grab-ip")
+		return errors.New("IP required")
+	}
+
+	ip := c.Args().Get(0)
+
+	if !c.Bool("force") {
+		if !prompter.YN("Grab following private IP.\n  "+ip+"\nAre you sure?", true) {
+			log.Infof("Grabbing is canceled")
+			return nil
+		}
+	}
+
+	ctx, cancel := newSignalContext()
+	defer cancel()
+
+	awscli := aws.NewENIClient().WithLogWriter(os.Stdout)
+
+	instanceID, err := targetInstanceID(ctx, c, awscli)
+	if err != nil {
+		return err
+	}
+	interfaceID := c.String("interfaceid")
+	if interfaceID != "" {
+		if interfaceID, err = awscli.ResolveENIIDWithContext(ctx, interfaceID); err != nil {
+			return err
+		}
+	}
+
+	eni, err := awscli.GrabPrivateIPWithContext(ctx, &aws.GrabPrivateIPParam{
+		PrivateIP:   ip,
+		InstanceID:  instanceID,
+		InterfaceID: interfaceID,
+	}, newWaiterParam(c))
+	if err != nil {
+		return err
+	}
+	if eni == nil {
+		log.Infof("%s already assigned to instance %s", ip, instanceID)
+		return nil
+	}
+
+	log.Infof("%s assigned to %s of instance %s", ip, eni.InterfaceID(), instanceID)
+
+	return nil
+}
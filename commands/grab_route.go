@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/Songmu/prompter"
+	"github.com/urfave/cli"
+
+	"github.com/yuuki/grabeni/aws"
+	"github.com/yuuki/grabeni/format"
+	"github.com/yuuki/grabeni/log"
+)
+
+var CommandArgGrabRoute = "--route-table ROUTE_TABLE_ID... --cidr CIDR [--eni ENI_ID | --instanceid INSTANCE_ID] [--max-attempts MAX_ATTEMPTS] [--interval INTERVAL] [--timeout TIMEOUT] [--initial-interval INTERVAL] [--max-interval INTERVAL]"
+var CommandGrabRoute = cli.Command{
+	Name:   "grab-route",
+	Usage:  "Point the route in the route tables at the ENI or the instance",
+	Action: fatalOnError(doGrabRoute),
+	Flags: append([]cli.Flag{
+		cli.StringSliceFlag{Name: "route-table", Usage: "route table id to change, or a comma separated list of them (repeatable)"},
+		cli.StringFlag{Name: "cidr", Usage: "destination CIDR of the route"},
+		cli.StringFlag{Name: "eni", Usage: "route-targeted ENI id or selector"},
+		cli.StringFlag{Name: "I, instanceid", Usage: "route-targeted instance id or Name tag, used if --eni is not given"},
+		cli.BoolFlag{Name: "f, force", Usage: "run without y/n acknowledgement (default: false)"},
+	}, waiterFlags...),
+}
+
+func doGrabRoute(c *cli.Context) error {
+	routeTableIDs := make([]string, 0)
+	for _, s := range c.StringSlice("route-table") {
+		routeTableIDs = append(routeTableIDs, strings.Split(s, ",")...)
+	}
+	cidr := c.String("cidr")
+	if len(routeTableIDs) < 1 || cidr == "" {
+		cli.ShowCommandHelp(c, "grab-route")
+		return errors.New("--route-table and --cidr required")
+	}
+	if c.String("eni") != "" && c.String("instanceid") != "" {
+		return errors.New("--eni and --instanceid are exclusive")
+	}
+
+	if !c.Bool("force") {
+		if !prompter.YN("Grab following route.\n  "+cidr+" in "+strings.Join(routeTableIDs, ", ")+"\nAre you sure?", true) {
+			log.Infof("Grabbing is canceled")
+			return nil
+		}
+	}
+
+	ctx, cancel := newSignalContext()
+	defer cancel()
+
+	awscli := aws.NewENIClient().WithLogWriter(os.Stdout)
+
+	interfaceID, instanceID, err := targetENIOrInstance(ctx, c, awscli)
+	if err != nil {
+		return err
+	}
+
+	changes, err := awscli.GrabRouteWithContext(ctx, &aws.GrabRouteParam{
+		RouteTableIDs:   routeTableIDs,
+		DestinationCIDR: cidr,
+		InterfaceID:     interfaceID,
+		InstanceID:      instanceID,
+	}, newWaiterParam(c))
+	// Report the changes even on failure so that they can be rolled back by hand
+	if len(changes) > 0 {
+		format.PrintRouteChanges(os.Stdout, changes)
+	}
+	return err
+}
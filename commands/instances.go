@@ -0,0 +1,31 @@
+package commands
+
+import (
+	"os"
+
+	"github.com/urfave/cli"
+
+	"github.com/yuuki/grabeni/aws"
+	"github.com/yuuki/grabeni/format"
+)
+
+var CommandArgInstances = ""
+var CommandInstances = cli.Command{
+	Name:   "instances",
+	Usage:  "List instances with the number of ENIs attached and the maximum",
+	Action: fatalOnError(doInstances),
+}
+
+func doInstances(c *cli.Context) error {
+	ctx, cancel := newSignalContext()
+	defer cancel()
+
+	instances, err := aws.NewENIClient().WithLogWriter(os.Stdout).DescribeInstancesWithContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	format.PrintInstances(os.Stdout, instances)
+
+	return nil
+}
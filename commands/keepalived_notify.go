@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/yuuki/grabeni/aws"
+	"github.com/yuuki/grabeni/keepalived"
+	"github.com/yuuki/grabeni/log"
+)
+
+var CommandArgKeepalivedNotify = "--config PATH [--max-attempts MAX_ATTEMPTS] [--interval INTERVAL] [--timeout TIMEOUT] [--initial-interval INTERVAL] [--max-interval INTERVAL] TYPE NAME STATE [PRIORITY]"
+var CommandKeepalivedNotify = cli.Command{
+	Name:   "keepalived-notify",
+	Usage:  "Grab or release the ENIs of a VRRP instance or group as a keepalived notify script",
+	Action: fatalOnError(doKeepalivedNotify),
+	Flags: append([]cli.Flag{
+		cli.StringFlag{Name: "c, config", Usage: "path to the JSON file mapping VRRP instances and groups onto ENIs"},
+	}, waiterFlags...),
+}
+
+func doKeepalivedNotify(c *cli.Context) error {
+	path := c.String("config")
+	if path == "" {
+		cli.ShowCommandHelp(c, "keepalived-notify")
+		return errors.New("--config required")
+	}
+	n, err := keepalived.ParseNotification(c.Args())
+	if err != nil {
+		cli.ShowCommandHelp(c, "keepalived-notify")
+		return err
+	}
+
+	config, err := keepalived.LoadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := newSignalContext()
+	defer cancel()
+	// Resolving the local instance counts toward the deadline too
+	ctx, cancelDeadline := context.WithTimeout(ctx, time.Duration(config.Deadline))
+	defer cancelDeadline()
+
+	awscli := aws.NewENIClient().WithLogWriter(os.Stdout)
+
+	instanceID := config.InstanceID
+	if instanceID == "" {
+		instanceID, err = aws.NewMetaDataClient().GetInstanceIDWithContext(ctx)
+	} else {
+		instanceID, err = awscli.ResolveInstanceIDWithContext(ctx, instanceID)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Infof("keepalived: %s", n)
+	return keepalived.New(config, awscli, instanceID, newWaiterParam(c)).Handle(ctx, n)
+}
@@ -9,16 +9,50 @@ import (
 	"github.com/yuuki/grabeni/format"
 )
 
-var CommandArgList = ""
+var CommandArgList = "[--vpc VPC_ID] [--subnet SUBNET_ID] [--az AZ] [--status STATUS] [--tag KEY=VALUE]... [--instance INSTANCE_ID] [--name PATTERN]"
 var CommandList = cli.Command{
 	Name:    "list",
 	Aliases: []string{"ls"},
 	Usage:   "List ENIs",
 	Action:  fatalOnError(doList),
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "vpc", Usage: "list only ENIs in the VPC"},
+		cli.StringFlag{Name: "subnet", Usage: "list only ENIs in the subnet"},
+		cli.StringFlag{Name: "az", Usage: "list only ENIs in the availability zone"},
+		cli.StringFlag{Name: "status", Usage: "list only ENIs with the status (available, in-use, attaching, detaching)"},
+		cli.StringSliceFlag{Name: "tag", Usage: "list only ENIs tagged KEY=VALUE (repeatable)"},
+		cli.StringFlag{Name: "instance", Usage: "list only ENIs attached to the instance id or Name tag"},
+		cli.StringFlag{Name: "name", Usage: "list only ENIs whose Name tag matches the glob pattern"},
+	},
 }
 
 func doList(c *cli.Context) error {
-	enis, err := aws.NewENIClient().WithLogWriter(os.Stdout).DescribeENIs()
+	tags, err := parseTags(c.StringSlice("tag"))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := newSignalContext()
+	defer cancel()
+
+	awscli := aws.NewENIClient().WithLogWriter(os.Stdout)
+
+	instanceID := c.String("instance")
+	if instanceID != "" {
+		if instanceID, err = awscli.ResolveInstanceIDWithContext(ctx, instanceID); err != nil {
+			return err
+		}
+	}
+
+	enis, err := awscli.DescribeENIsWithContext(ctx, &aws.ListFilter{
+		VPCID:            c.String("vpc"),
+		SubnetID:         c.String("subnet"),
+		AvailabilityZone: c.String("az"),
+		Status:           c.String("status"),
+		InstanceID:       instanceID,
+		Tags:             tags,
+		Name:             c.String("name"),
+	})
 	if err != nil {
 		return err
 	}
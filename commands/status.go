@@ -26,12 +26,19 @@ func doStatus(c *cli.Context) error {
 
 	eniID := c.Args().Get(0)
 
-	eni, err := aws.NewENIClient().WithLogWriter(os.Stdout).DescribeENIByID(eniID)
+	ctx, cancel := newSignalContext()
+	defer cancel()
+
+	awscli := aws.NewENIClient().WithLogWriter(os.Stdout)
+
+	eniID, err := awscli.ResolveENIIDWithContext(ctx, eniID)
 	if err != nil {
 		return err
 	}
-	if eni == nil {
-		return nil
+
+	eni, err := awscli.DescribeENIByIDWithContext(ctx, eniID)
+	if err != nil {
+		return err
 	}
 
 	format.PrintENI(os.Stdout, eni)
@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/Songmu/prompter"
+	"github.com/urfave/cli"
+
+	"github.com/yuuki/grabeni/aws"
+	"github.com/yuuki/grabeni/log"
+)
+
+var CommandArgSwap = "[--force-detach] [--force-detach-grace-period PERIOD] [--max-attempts MAX_ATTEMPTS] [--interval INTERVAL] [--timeout TIMEOUT] [--initial-interval INTERVAL] [--max-interval INTERVAL] ENI_A ENI_B"
+var CommandSwap = cli.Command{
+	Name:   "swap",
+	Usage:  "Exchange two ENIs between the instances they are attached to",
+	Action: fatalOnError(doSwap),
+	Flags: append([]cli.Flag{
+		cli.BoolFlag{Name: "f, force", Usage: "run without y/n acknowledgement (default: false)"},
+		cli.BoolFlag{Name: "force-detach", Usage: "force detaching if a normal detach hasn't completed within --force-detach-grace-period (default: false)"},
+		cli.DurationFlag{Name: "force-detach-grace-period", Value: 10 * time.Second, Usage: "the time to wait for a normal detach before forcing it"},
+	}, waiterFlags...),
+}
+
+func doSwap(c *cli.Context) error {
+	if len(c.Args()) < 2 {
+		cli.ShowCommandHelp(c, "swap")
+		return errors.New("ENI_A and ENI_B required")
+	}
+
+	awscli := aws.NewENIClient().WithLogWriter(os.Stdout)
+
+	eniIDs, err := resolveENIIDs(c, awscli, c.Args()[:2])
+	if err != nil {
+		return err
+	}
+
+	if !c.Bool("force") {
+		if !prompter.YN("Swap following ENIs.\n  "+eniIDs[0]+"\n  "+eniIDs[1]+"\nAre you sure?", true) {
+			log.Infof("Swapping is canceled")
+			return nil
+		}
+	}
+
+	ctx, cancel := newSignalContext()
+	defer cancel()
+
+	enis, err := awscli.SwapENIsWithContext(ctx, &aws.SwapENIParam{
+		InterfaceIDs:           [2]string{eniIDs[0], eniIDs[1]},
+		ForceDetach:            c.Bool("force-detach"),
+		ForceDetachGracePeriod: c.Duration("force-detach-grace-period"),
+	}, newWaiterParam(c))
+	if err != nil {
+		return err
+	}
+
+	for _, eni := range enis {
+		log.Infof("%s attached to instance %s", eni.InterfaceID(), eni.AttachedInstanceID())
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"errors"
+	"os"
+
+	"github.com/urfave/cli"
+
+	"github.com/yuuki/grabeni/aws"
+	"github.com/yuuki/grabeni/watchd"
+)
+
+var CommandArgWatchd = "--config PATH [--max-attempts MAX_ATTEMPTS] [--interval INTERVAL] [--timeout TIMEOUT] [--initial-interval INTERVAL] [--max-interval INTERVAL]"
+var CommandWatchd = cli.Command{
+	Name:   "watchd",
+	Usage:  "Run as a daemon grabbing ENIs when the local node is healthy and their owner has failed",
+	Action: fatalOnError(doWatchd),
+	Flags: append([]cli.Flag{
+		cli.StringFlag{Name: "c, config", Usage: "path to the JSON configuration file"},
+	}, waiterFlags...),
+}
+
+func doWatchd(c *cli.Context) error {
+	path := c.String("config")
+	if path == "" {
+		cli.ShowCommandHelp(c, "watchd")
+		return errors.New("--config required")
+	}
+
+	config, err := watchd.LoadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := newSignalContext()
+	defer cancel()
+
+	awscli := aws.NewENIClient().WithLogWriter(os.Stdout)
+
+	// Resolve the selectors once, so that the daemon works on IDs
+	if config.InstanceID == "" {
+		config.InstanceID, err = aws.NewMetaDataClient().GetInstanceIDWithContext(ctx)
+	} else {
+		config.InstanceID, err = awscli.ResolveInstanceIDWithContext(ctx, config.InstanceID)
+	}
+	if err != nil {
+		return err
+	}
+	for i, selector := range config.ENIs {
+		if config.ENIs[i], err = awscli.ResolveENIIDWithContext(ctx, selector); err != nil {
+			return err
+		}
+	}
+
+	d, err := watchd.New(config, awscli, newWaiterParam(c))
+	if err != nil {
+		return err
+	}
+	return d.Run(ctx)
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"text/tabwriter"
 
+	"github.com/yuuki/grabeni/aws"
 	"github.com/yuuki/grabeni/aws/model"
 )
 
@@ -48,3 +49,110 @@ func PrintENIs(w io.Writer, enis []*model.ENI) {
 
 	tw.Flush()
 }
+
+const checkHeader = "CHECK\tRESULT\tDETAIL"
+
+func PrintCheckResults(w io.Writer, results []*aws.CheckResult) {
+	tw := tabwriter.NewWriter(w, 0, 8, 0, '\t', 0)
+
+	fmt.Fprintln(tw, checkHeader)
+
+	for _, r := range results {
+		result := "PASS"
+		if !r.OK {
+			result = "FAIL"
+		}
+		fmt.Fprintln(tw, fmt.Sprintf("%s\t%s\t%s", r.Name, result, r.Detail))
+	}
+
+	tw.Flush()
+}
+
+const instanceHeader = "ID\tNAME\tSTATE\tTYPE\tAZ\tENIS"
+
+func PrintInstances(w io.Writer, instances []*model.Instance) {
+	tw := tabwriter.NewWriter(w, 0, 8, 0, '\t', 0)
+
+	fmt.Fprintln(tw, instanceHeader)
+
+	for _, i := range instances {
+		if i == nil {
+			continue
+		}
+
+		// The maximum is unknown without the instance type info
+		max := "-"
+		if n := i.MaxNetworkInterfaces(); n > 0 {
+			max = fmt.Sprintf("%d", n)
+		}
+
+		fmt.Fprintln(tw, fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%d/%s",
+			i.InstanceID(),
+			i.Name(),
+			i.StateName(),
+			i.Type(),
+			i.AvailabilityZone(),
+			len(i.NetworkInterfaces),
+			max,
+		))
+	}
+
+	tw.Flush()
+}
+
+const addressHeader = "ALLOCATION ID\tPUBLIC IP\tNAME\tENI ID\tPRIVATE IP\tINSTANCE ID"
+
+func PrintAddresses(w io.Writer, addrs []*model.Address) {
+	tw := tabwriter.NewWriter(w, 0, 8, 0, '\t', 0)
+
+	fmt.Fprintln(tw, addressHeader)
+
+	for _, a := range addrs {
+		if a == nil {
+			continue
+		}
+
+		fmt.Fprintln(tw, fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s",
+			a.AllocationID(),
+			a.PublicIP(),
+			a.Name(),
+			a.NetworkInterfaceID(),
+			a.PrivateIpAddress(),
+			a.InstanceID(),
+		))
+	}
+
+	tw.Flush()
+}
+
+const routeChangeHeader = "ROUTE TABLE\tDESTINATION\tPREVIOUS TARGET\tTARGET\tACTION"
+
+func PrintRouteChanges(w io.Writer, changes []*aws.RouteChange) {
+	tw := tabwriter.NewWriter(w, 0, 8, 0, '\t', 0)
+
+	fmt.Fprintln(tw, routeChangeHeader)
+
+	for _, c := range changes {
+		fmt.Fprintln(tw, fmt.Sprintf("%s\t%s\t%s\t%s\t%s", c.RouteTableID, c.DestinationCIDR, c.PreviousTarget, c.Target, c.Action))
+	}
+
+	tw.Flush()
+}
+
+const eniResultHeader = "ENI ID\tINSTANCE ID\tRESULT\tERROR"
+
+func PrintENIResults(w io.Writer, results []*aws.ENIResult) {
+	tw := tabwriter.NewWriter(w, 0, 8, 0, '\t', 0)
+
+	fmt.Fprintln(tw, eniResultHeader)
+
+	for _, r := range results {
+		var errMsg string
+		if r.Err != nil {
+			errMsg = r.Err.Error()
+		}
+		fmt.Fprintln(tw, fmt.Sprintf("%s\t%s\t%s\t%s", r.InterfaceID, r.InstanceID, r.Outcome, errMsg))
+	}
+
+	tw.Flush()
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/stretchr/testify/assert"
 
+	grabeniaws "github.com/yuuki/grabeni/aws"
 	"github.com/yuuki/grabeni/aws/model"
 )
 
@@ -50,3 +51,71 @@ func TestPrintENIs(t *testing.T) {
 	expected = "ID\tNAME\tSTATUS\tPRIVATE DNS NAMEPRIVATE IP\tAZ\tDEVICE INDEX\tINSTANCE ID\tINSTANCE NAME\n\t\t\t\t\t\t\t\t-1\t\ti-1000000\tgrabeni001\n"
 	assert.Equal(t, expected, string(w.Bytes()))
 }
+
+func TestPrintCheckResults(t *testing.T) {
+	w := new(bytes.Buffer)
+	PrintCheckResults(w, []*grabeniaws.CheckResult{
+		{Name: "vpc", OK: true, Detail: "eni: vpc-1, instance: vpc-1"},
+		{Name: "instance state", OK: false, Detail: "i-1 is stopped"},
+	})
+
+	expected := "CHECK\t\tRESULT\tDETAIL\nvpc\t\tPASS\teni: vpc-1, instance: vpc-1\ninstance state\tFAIL\ti-1 is stopped\n"
+	assert.Equal(t, expected, string(w.Bytes()))
+}
+
+func TestPrintInstances(t *testing.T) {
+	i := model.NewInstance(&ec2.Instance{
+		InstanceId:        aws.String("i-1"),
+		InstanceType:      aws.String("t3.micro"),
+		State:             &ec2.InstanceState{Name: aws.String("running")},
+		Placement:         &ec2.Placement{AvailabilityZone: aws.String("az-1")},
+		NetworkInterfaces: []*ec2.InstanceNetworkInterface{{}},
+	})
+	i.SetTypeInfo(&ec2.InstanceTypeInfo{
+		NetworkInfo: &ec2.NetworkInfo{MaximumNetworkInterfaces: aws.Int64(2)},
+	})
+
+	w := new(bytes.Buffer)
+	PrintInstances(w, []*model.Instance{i, model.NewInstance(&ec2.Instance{InstanceId: aws.String("i-2")})})
+
+	expected := "ID\tNAME\tSTATE\tTYPE\tAZ\tENIS\ni-1\t\trunning\tt3.microaz-1\t1/2\ni-2\t\t\t\t\t0/-\n"
+	assert.Equal(t, expected, string(w.Bytes()))
+}
+
+func TestPrintAddresses(t *testing.T) {
+	w := new(bytes.Buffer)
+	PrintAddresses(w, []*model.Address{
+		model.NewAddress(&ec2.Address{
+			AllocationId:       aws.String("eipalloc-1"),
+			PublicIp:           aws.String("203.0.113.10"),
+			NetworkInterfaceId: aws.String("eni-1"),
+			PrivateIpAddress:   aws.String("10.0.0.10"),
+			InstanceId:         aws.String("i-1"),
+		}),
+		nil,
+	})
+
+	expected := "ALLOCATION ID\tPUBLIC IP\tNAME\tENI ID\tPRIVATE IP\tINSTANCE ID\neipalloc-1\t203.0.113.10\t\teni-1\t10.0.0.10\ti-1\n"
+	assert.Equal(t, expected, string(w.Bytes()))
+}
+
+func TestPrintRouteChanges(t *testing.T) {
+	w := new(bytes.Buffer)
+	PrintRouteChanges(w, []*grabeniaws.RouteChange{
+		{RouteTableID: "rtb-1", DestinationCIDR: "10.255.0.1/32", PreviousTarget: "eni-1", Target: "eni-2", Action: "replaced"},
+	})
+
+	expected := "ROUTE TABLE\tDESTINATION\tPREVIOUS TARGET\tTARGET\tACTION\nrtb-1\t\t10.255.0.1/32\teni-1\t\teni-2\treplaced\n"
+	assert.Equal(t, expected, string(w.Bytes()))
+}
+
+func TestPrintENIResults(t *testing.T) {
+	w := new(bytes.Buffer)
+	PrintENIResults(w, []*grabeniaws.ENIResult{
+		{InterfaceID: "eni-1", InstanceID: "i-1", Outcome: grabeniaws.OutcomeMoved},
+		{InterfaceID: "eni-2", InstanceID: "i-1", Outcome: grabeniaws.OutcomeFailed, Err: &grabeniaws.ENINotFoundError{InterfaceID: "eni-2"}},
+	})
+
+	expected := "ENI ID\tINSTANCE ID\tRESULT\tERROR\neni-1\ti-1\t\tmoved\t\neni-2\ti-1\t\tfailed\tno such ENI eni-2\n"
+	assert.Equal(t, expected, string(w.Bytes()))
+}
@@ -0,0 +1,150 @@
+// Package health probes the health of a node by TCP connect, HTTP GET or
+// running a command.
+package health
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os/exec"
+	"time"
+)
+
+// DefaultTimeout bounds a probe whose Timeout is not set.
+const DefaultTimeout = 5 * time.Second
+
+// Checker probes something and returns nil if it is healthy.
+type Checker interface {
+	Check(ctx context.Context) error
+	String() string
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// TCPChecker is healthy if a TCP connection to Address can be established.
+type TCPChecker struct {
+	Address string
+	Timeout time.Duration
+}
+
+func (c *TCPChecker) Check(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.Address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func (c *TCPChecker) String() string {
+	return "tcp " + c.Address
+}
+
+// HTTPChecker is healthy if GET URL responds with ExpectStatus, or any 2xx
+// status if ExpectStatus is 0.
+type HTTPChecker struct {
+	URL          string
+	ExpectStatus int
+	Timeout      time.Duration
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+func (c *HTTPChecker) Check(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodGet, c.URL, nil)
+	if err != nil {
+		return err
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if c.ExpectStatus != 0 {
+		if resp.StatusCode != c.ExpectStatus {
+			return fmt.Errorf("GET %s: status %d, expected %d", c.URL, resp.StatusCode, c.ExpectStatus)
+		}
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("GET %s: status %d", c.URL, resp.StatusCode)
+	}
+	return nil
+}
+
+func (c *HTTPChecker) String() string {
+	return "http " + c.URL
+}
+
+// ExecChecker is healthy if Command exits with status 0. Command[0] is the
+// program and the rest are its arguments, not interpreted by a shell.
+type ExecChecker struct {
+	Command []string
+	Timeout time.Duration
+}
+
+func (c *ExecChecker) Check(ctx context.Context) error {
+	if len(c.Command) == 0 {
+		return fmt.Errorf("empty command")
+	}
+
+	ctx, cancel := withTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, c.Command[0], c.Command[1:]...).CombinedOutput()
+	if err != nil {
+		if len(out) > 0 {
+			return fmt.Errorf("%s: %s", err, lastLine(out))
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *ExecChecker) String() string {
+	return fmt.Sprintf("exec %q", c.Command)
+}
+
+// lastLine returns the last non-empty line of out, which is usually the
+// error message of a failed command.
+func lastLine(out []byte) string {
+	end := len(out)
+	for end > 0 && (out[end-1] == '\n' || out[end-1] == '\r') {
+		end--
+	}
+	start := end
+	for start > 0 && out[start-1] != '\n' {
+		start--
+	}
+	return string(out[start:end])
+}
+
+// CheckAll runs the checkers in turn and returns the first error, annotated
+// with the checker. It returns nil if checkers is empty.
+func CheckAll(ctx context.Context, checkers []Checker) error {
+	for _, c := range checkers {
+		if err := c.Check(ctx); err != nil {
+			return fmt.Errorf("%s: %s", c, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package health
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTCPChecker(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+
+	assert.NoError(t, (&TCPChecker{Address: addr}).Check(context.Background()))
+
+	l.Close()
+	assert.Error(t, (&TCPChecker{Address: addr}).Check(context.Background()))
+}
+
+func TestHTTPChecker(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		checker *HTTPChecker
+		healthy bool
+	}{
+		{name: "2xx", checker: &HTTPChecker{URL: ts.URL + "/up"}, healthy: true},
+		{name: "5xx", checker: &HTTPChecker{URL: ts.URL + "/down"}, healthy: false},
+		{name: "expected status", checker: &HTTPChecker{URL: ts.URL + "/down", ExpectStatus: 503}, healthy: true},
+		{name: "unexpected status", checker: &HTTPChecker{URL: ts.URL + "/up", ExpectStatus: 200}, healthy: false},
+	}
+
+	for _, tt := range tests {
+		err := tt.checker.Check(context.Background())
+		assert.Equal(t, tt.healthy, err == nil, "%s: %v", tt.name, err)
+	}
+}
+
+func TestExecChecker(t *testing.T) {
+	assert.NoError(t, (&ExecChecker{Command: []string{"true"}}).Check(context.Background()))
+
+	err := (&ExecChecker{Command: []string{"sh", "-c", "echo starting; echo replica lagging >&2; exit 1"}}).Check(context.Background())
+	assert.EqualError(t, err, "exit status 1: replica lagging")
+
+	assert.EqualError(t, (&ExecChecker{}).Check(context.Background()), "empty command")
+}
+
+func TestCheckAll(t *testing.T) {
+	checkers := []Checker{
+		&ExecChecker{Command: []string{"true"}},
+		&ExecChecker{Command: []string{"false"}},
+	}
+
+	assert.EqualError(t, CheckAll(context.Background(), checkers), `exec ["false"]: exit status 1`)
+	assert.NoError(t, CheckAll(context.Background(), nil))
+}
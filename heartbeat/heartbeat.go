@@ -0,0 +1,283 @@
+// Package heartbeat exchanges heartbeats over UDP between the grabeni nodes
+// competing for the same ENIs, so that a node takes over only when the owner
+// has gone silent and no live node has a higher priority.
+package heartbeat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yuuki/grabeni/log"
+)
+
+// Default values of Config
+const (
+	DefaultInterval = time.Second
+	DefaultTimeout  = 5 * time.Second
+)
+
+// maxMessageSize bounds the size of a heartbeat datagram
+const maxMessageSize = 4096
+
+// Message is a heartbeat, sent as JSON in a UDP datagram.
+type Message struct {
+	NodeID   string `json:"node_id"`
+	Priority int    `json:"priority"`
+	// Epoch increases with every heartbeat of a node, across restarts too, so
+	// that delayed or replayed heartbeats are told apart from fresh ones.
+	Epoch uint64 `json:"epoch"`
+	// Owned is set while the node owns the ENIs and is healthy.
+	Owned bool `json:"owned"`
+	// Healthy is set while the node passes its health checks.
+	Healthy bool      `json:"healthy"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+type Config struct {
+	// NodeID identifies the node among its peers, such as its instance ID.
+	NodeID   string
+	Priority int
+	// Listen is the UDP address to receive heartbeats on, such as ":7946".
+	Listen string
+	// Peers are the UDP addresses of the other nodes to send heartbeats to.
+	Peers []string
+	// Interval is the period of sending heartbeats, DefaultInterval if zero.
+	Interval time.Duration
+	// Timeout is the silence after which a node is deemed dead,
+	// DefaultTimeout if zero.
+	Timeout time.Duration
+}
+
+// Peer is the last heartbeat received from another node.
+type Peer struct {
+	Message
+	ReceivedAt time.Time
+}
+
+type Node struct {
+	config  Config
+	conn    *net.UDPConn
+	peers   []*net.UDPAddr
+	started time.Time
+
+	mu      sync.Mutex
+	owned   bool
+	healthy bool
+	epoch   uint64
+	seen    map[string]*Peer
+
+	now func() time.Time
+}
+
+// Listen opens the UDP socket of the node. Heartbeats are exchanged once Run
+// is called.
+func Listen(c *Config) (*Node, error) {
+	if c.NodeID == "" {
+		return nil, errors.New("node ID required")
+	}
+	config := *c
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+	if config.Timeout <= config.Interval {
+		return nil, fmt.Errorf("timeout %s must be longer than interval %s", config.Timeout, config.Interval)
+	}
+
+	peers := make([]*net.UDPAddr, 0, len(config.Peers))
+	for _, p := range config.Peers {
+		addr, err := net.ResolveUDPAddr("udp", p)
+		if err != nil {
+			return nil, err
+		}
+		peers = append(peers, addr)
+	}
+
+	laddr, err := net.ResolveUDPAddr("udp", config.Listen)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &Node{
+		config:  config,
+		conn:    conn,
+		peers:   peers,
+		started: now,
+		// Start from the clock so that the epochs keep increasing after a restart
+		epoch: uint64(now.UnixNano()),
+		seen:  make(map[string]*Peer),
+		now:   time.Now,
+	}, nil
+}
+
+// LocalAddr returns the address that the node receives heartbeats on.
+func (n *Node) LocalAddr() net.Addr {
+	return n.conn.LocalAddr()
+}
+
+// Run sends a heartbeat to the peers every Interval and receives theirs until
+// ctx is done, then closes the socket.
+func (n *Node) Run(ctx context.Context) error {
+	errc := make(chan error, 1)
+	go func() { errc <- n.receive() }()
+
+	ticker := time.NewTicker(n.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		n.send()
+
+		select {
+		case <-ctx.Done():
+			n.conn.Close()
+			return <-errc
+		case err := <-errc:
+			n.conn.Close()
+			return err
+		case <-ticker.C:
+		}
+	}
+}
+
+func (n *Node) send() {
+	n.mu.Lock()
+	n.epoch++
+	m := &Message{
+		NodeID:   n.config.NodeID,
+		Priority: n.config.Priority,
+		Epoch:    n.epoch,
+		Owned:    n.owned,
+		Healthy:  n.healthy,
+		SentAt:   n.now(),
+	}
+	n.mu.Unlock()
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		log.Debugf("heartbeat: %s", err)
+		return
+	}
+	for _, p := range n.peers {
+		// A peer that is down is nothing unusual
+		if _, err := n.conn.WriteToUDP(b, p); err != nil {
+			log.Debugf("heartbeat: send to %s: %s", p, err)
+		}
+	}
+}
+
+func (n *Node) receive() error {
+	buf := make([]byte, maxMessageSize)
+	for {
+		size, addr, err := n.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		if !n.handle(buf[:size]) {
+			log.Debugf("heartbeat: ignored a message from %s", addr)
+		}
+	}
+}
+
+// handle records a heartbeat and reports whether it was accepted. Malformed
+// heartbeats, the node's own ones and stale ones, whose epoch isn't greater
+// than the last one seen from the same node, are ignored.
+func (n *Node) handle(b []byte) bool {
+	var m Message
+	if err := json.Unmarshal(b, &m); err != nil || m.NodeID == "" || m.NodeID == n.config.NodeID {
+		return false
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if p, ok := n.seen[m.NodeID]; ok && m.Epoch <= p.Epoch {
+		return false
+	}
+	n.seen[m.NodeID] = &Peer{Message: m, ReceivedAt: n.now()}
+	return true
+}
+
+// SetState sets whether the node owns the ENIs and whether it is healthy,
+// announced from the next heartbeat on. A node doesn't announce the ownership
+// while it is unhealthy, so that the peers take over.
+func (n *Node) SetState(owned, healthy bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.owned, n.healthy = owned && healthy, healthy
+}
+
+// Ready reports whether the node has been listening for Timeout, before which
+// a silent peer can't be told from one not heard from yet.
+func (n *Node) Ready() bool {
+	return n.now().Sub(n.started) >= n.config.Timeout
+}
+
+// alive reports whether p has been heard from within Timeout. n.mu must be held.
+func (n *Node) alive(p *Peer) bool {
+	return n.now().Sub(p.ReceivedAt) < n.config.Timeout
+}
+
+// Alive reports whether the node has been heard from within Timeout.
+func (n *Node) Alive(nodeID string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	p, ok := n.seen[nodeID]
+	return ok && n.alive(p)
+}
+
+// LivePeers returns the peers heard from within Timeout, sorted by node ID.
+func (n *Node) LivePeers() []Peer {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	peers := make([]Peer, 0, len(n.seen))
+	for _, p := range n.seen {
+		if n.alive(p) {
+			peers = append(peers, *p)
+		}
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].NodeID < peers[j].NodeID })
+	return peers
+}
+
+// LiveOwner returns the live peer announcing the ownership of the ENIs, if any.
+func (n *Node) LiveOwner() (Peer, bool) {
+	for _, p := range n.LivePeers() {
+		if p.Owned {
+			return p, true
+		}
+	}
+	return Peer{}, false
+}
+
+// Highest reports whether the node has the highest priority among itself and
+// the live healthy peers. A tie goes to the smaller node ID.
+func (n *Node) Highest() bool {
+	for _, p := range n.LivePeers() {
+		if !p.Healthy {
+			continue
+		}
+		if p.Priority > n.config.Priority || (p.Priority == n.config.Priority && p.NodeID < n.config.NodeID) {
+			return false
+		}
+	}
+	return true
+}
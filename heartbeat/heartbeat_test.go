@@ -0,0 +1,161 @@
+package heartbeat
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestNode(t *testing.T, nodeID string, priority int) *Node {
+	n, err := Listen(&Config{
+		NodeID:   nodeID,
+		Priority: priority,
+		Listen:   "127.0.0.1:0",
+		Interval: 10 * time.Millisecond,
+		Timeout:  100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// connect makes the nodes send heartbeats to each other.
+func connect(nodes ...*Node) {
+	for _, n := range nodes {
+		for _, p := range nodes {
+			if p != n {
+				n.peers = append(n.peers, p.LocalAddr().(*net.UDPAddr))
+			}
+		}
+	}
+}
+
+// eventually polls cond until it holds or a second has passed.
+func eventually(t *testing.T, cond func() bool, msg string) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting until %s", msg)
+}
+
+func encode(t *testing.T, m *Message) []byte {
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestHandle(t *testing.T) {
+	n := newTestNode(t, "i-aaaa", 100)
+	defer n.conn.Close()
+
+	assert.False(t, n.handle([]byte("{")), "malformed")
+	assert.False(t, n.handle(encode(t, &Message{Epoch: 1})), "no node ID")
+	assert.False(t, n.handle(encode(t, &Message{NodeID: "i-aaaa", Epoch: 1})), "own heartbeat")
+
+	assert.True(t, n.handle(encode(t, &Message{NodeID: "i-bbbb", Epoch: 5, Owned: true, Healthy: true})))
+	assert.False(t, n.handle(encode(t, &Message{NodeID: "i-bbbb", Epoch: 5})), "same epoch")
+	assert.False(t, n.handle(encode(t, &Message{NodeID: "i-bbbb", Epoch: 4})), "stale epoch")
+
+	owner, ok := n.LiveOwner()
+	assert.True(t, ok)
+	assert.Equal(t, "i-bbbb", owner.NodeID)
+
+	assert.True(t, n.handle(encode(t, &Message{NodeID: "i-bbbb", Epoch: 6})))
+	_, ok = n.LiveOwner()
+	assert.False(t, ok, "the ownership is released by the newer heartbeat")
+}
+
+func TestHighest(t *testing.T) {
+	n := newTestNode(t, "i-bbbb", 100)
+	defer n.conn.Close()
+
+	assert.True(t, n.Highest(), "alone")
+
+	n.handle(encode(t, &Message{NodeID: "i-cccc", Priority: 200, Epoch: 1}))
+	assert.True(t, n.Highest(), "unhealthy peer doesn't count")
+
+	n.handle(encode(t, &Message{NodeID: "i-cccc", Priority: 200, Epoch: 2, Healthy: true}))
+	assert.False(t, n.Highest())
+
+	n.handle(encode(t, &Message{NodeID: "i-cccc", Priority: 100, Epoch: 3, Healthy: true}))
+	assert.True(t, n.Highest(), "tie goes to the smaller node ID")
+
+	n.handle(encode(t, &Message{NodeID: "i-aaaa", Priority: 100, Epoch: 1, Healthy: true}))
+	assert.False(t, n.Highest())
+
+	now := time.Now()
+	n.now = func() time.Time { return now.Add(time.Second) }
+	assert.True(t, n.Highest(), "silent peers don't count")
+	assert.Empty(t, n.LivePeers())
+}
+
+func TestSetState(t *testing.T) {
+	n := newTestNode(t, "i-aaaa", 100)
+	defer n.conn.Close()
+
+	n.SetState(true, false)
+	assert.False(t, n.owned, "unhealthy node doesn't announce the ownership")
+	assert.False(t, n.healthy)
+
+	n.SetState(true, true)
+	assert.True(t, n.owned)
+	assert.True(t, n.healthy)
+}
+
+func TestListenInvalid(t *testing.T) {
+	_, err := Listen(&Config{Listen: "127.0.0.1:0"})
+	assert.Error(t, err, "no node ID")
+
+	_, err = Listen(&Config{NodeID: "i-aaaa", Listen: "127.0.0.1:0", Interval: time.Second, Timeout: time.Second})
+	assert.Error(t, err, "timeout not longer than interval")
+}
+
+// TestRun exchanges heartbeats between nodes on localhost, and sees a node
+// turn silent once it stops.
+func TestRun(t *testing.T) {
+	a := newTestNode(t, "i-aaaa", 100)
+	b := newTestNode(t, "i-bbbb", 200)
+	connect(a, b)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctxB, stopB := context.WithCancel(ctx)
+
+	b.SetState(true, true)
+	a.SetState(false, true)
+
+	done := make(chan error, 2)
+	go func() { done <- a.Run(ctx) }()
+	go func() { done <- b.Run(ctxB) }()
+
+	eventually(t, func() bool { return a.Alive("i-bbbb") && b.Alive("i-aaaa") }, "the nodes hear each other")
+
+	owner, ok := a.LiveOwner()
+	assert.True(t, ok)
+	assert.Equal(t, "i-bbbb", owner.NodeID)
+	assert.Equal(t, 200, owner.Priority)
+	assert.False(t, a.Highest())
+	assert.True(t, b.Highest())
+
+	stopB()
+	assert.NoError(t, <-done)
+
+	eventually(t, func() bool { return !a.Alive("i-bbbb") }, "i-bbbb turns silent")
+	_, ok = a.LiveOwner()
+	assert.False(t, ok)
+	assert.True(t, a.Highest())
+	assert.True(t, a.Ready())
+
+	cancel()
+	assert.NoError(t, <-done)
+}
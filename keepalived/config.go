@@ -0,0 +1,115 @@
+package keepalived
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/yuuki/grabeni/timeutil"
+)
+
+const (
+	// DefaultDeadline is well within the default script timeout of keepalived.
+	DefaultDeadline = 20 * time.Second
+	// DefaultForceDetachGracePeriod is the time to wait for a graceful
+	// detachment before forcing it.
+	DefaultForceDetachGracePeriod = 10 * time.Second
+)
+
+// Config maps the VRRP instances and groups of keepalived onto ENIs, read
+// from a JSON file.
+type Config struct {
+	// InstanceID is the local instance, the instance grabeni runs on if empty.
+	InstanceID string `json:"instance_id"`
+	// Deadline bounds the time spent on a notification, after which the
+	// notification is given up so as not to hold keepalived up.
+	Deadline timeutil.Duration `json:"deadline"`
+	// Instances and Groups map the names of VRRP instances and VRRP sync
+	// groups onto their ENIs.
+	Instances map[string]*Mapping `json:"instances"`
+	Groups    map[string]*Mapping `json:"groups"`
+}
+
+// Mapping is the ENIs following a VRRP instance or group.
+type Mapping struct {
+	ENIs []ENIConfig `json:"enis"`
+	// Release detaches the ENIs attached to the local instance on BACKUP or
+	// FAULT. They are left attached until the new MASTER grabs them if unset.
+	Release                bool              `json:"release"`
+	ForceDetach            bool              `json:"force_detach"`
+	ForceDetachGracePeriod timeutil.Duration `json:"force_detach_grace_period"`
+}
+
+// ENIConfig is an ENI ID or selector, and the device index to attach it at,
+// a free one if unset.
+type ENIConfig struct {
+	ENI         string `json:"eni"`
+	DeviceIndex *int   `json:"device_index"`
+}
+
+// LoadConfig reads the configuration from the JSON file at path, and fills
+// in the defaults.
+func LoadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config, err := ParseConfig(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return config, nil
+}
+
+// ParseConfig parses the configuration in JSON, and fills in the defaults.
+func ParseConfig(b []byte) (*Config, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+
+	config := &Config{}
+	if err := dec.Decode(config); err != nil {
+		return nil, err
+	}
+	if config.Deadline == 0 {
+		config.Deadline = timeutil.Duration(DefaultDeadline)
+	}
+	for _, mappings := range []map[string]*Mapping{config.Instances, config.Groups} {
+		for _, m := range mappings {
+			if m != nil && m.ForceDetachGracePeriod == 0 {
+				m.ForceDetachGracePeriod = timeutil.Duration(DefaultForceDetachGracePeriod)
+			}
+		}
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (c *Config) validate() error {
+	if c.Deadline < 0 {
+		return fmt.Errorf("deadline must not be negative")
+	}
+	if len(c.Instances) == 0 && len(c.Groups) == 0 {
+		return fmt.Errorf("instances or groups required")
+	}
+	for kind, mappings := range map[string]map[string]*Mapping{"instances": c.Instances, "groups": c.Groups} {
+		for name, m := range mappings {
+			if m == nil || len(m.ENIs) == 0 {
+				return fmt.Errorf("%s: %s requires enis", kind, name)
+			}
+			for _, e := range m.ENIs {
+				if e.ENI == "" {
+					return fmt.Errorf("%s: %s has an ENI without eni", kind, name)
+				}
+				if e.DeviceIndex != nil && *e.DeviceIndex < 1 {
+					return fmt.Errorf("%s: %s: device_index of %s must be 1 or more", kind, name, e.ENI)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+// Package keepalived handles the notifications of keepalived, grabbing the
+// ENIs of a VRRP instance or group when it turns MASTER.
+package keepalived
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yuuki/grabeni/aws"
+	"github.com/yuuki/grabeni/aws/model"
+	"github.com/yuuki/grabeni/log"
+)
+
+// Types and states of the notifications, as keepalived passes them to its
+// notify scripts
+const (
+	TypeInstance = "INSTANCE"
+	TypeGroup    = "GROUP"
+
+	StateMaster = "MASTER"
+	StateBackup = "BACKUP"
+	StateFault  = "FAULT"
+	StateStop   = "STOP"
+)
+
+// Notification is the TYPE NAME STATE arguments of a notify script.
+type Notification struct {
+	Type  string
+	Name  string
+	State string
+}
+
+func (n *Notification) String() string {
+	return fmt.Sprintf("%s %s %s", n.Type, n.Name, n.State)
+}
+
+// ParseNotification parses the arguments of a notify script. keepalived may
+// append the priority, which is ignored.
+func ParseNotification(args []string) (*Notification, error) {
+	if len(args) < 3 || len(args) > 4 {
+		return nil, fmt.Errorf("TYPE NAME STATE required, got %d arguments", len(args))
+	}
+
+	n := &Notification{Type: strings.ToUpper(args[0]), Name: args[1], State: strings.ToUpper(args[2])}
+	switch n.Type {
+	case TypeInstance, TypeGroup:
+	default:
+		return nil, fmt.Errorf("unknown type %q, must be %s or %s", args[0], TypeInstance, TypeGroup)
+	}
+	switch n.State {
+	case StateMaster, StateBackup, StateFault, StateStop:
+	default:
+		return nil, fmt.Errorf("unknown state %q", args[2])
+	}
+	return n, nil
+}
+
+// Client is the part of aws.ENIClient that the handler uses.
+type Client interface {
+	ResolveENIIDWithContext(ctx context.Context, selector string) (string, error)
+	DescribeENIByIDWithContext(ctx context.Context, interfaceID string) (*model.ENI, error)
+	GrabENIWithContext(ctx context.Context, p *aws.GrabENIParam, wp *aws.WaiterParam) (*model.ENI, error)
+	DetachENIWithWaiterWithContext(ctx context.Context, p *aws.DetachENIParam, wp *aws.WaiterParam) (*model.ENI, error)
+}
+
+// DeadlineError is returned when a notification hasn't been handled within
+// the deadline.
+type DeadlineError struct {
+	Notification *Notification
+	Deadline     time.Duration
+}
+
+func (e *DeadlineError) Error() string {
+	return fmt.Sprintf("%s not handled within the deadline %s", e.Notification, e.Deadline)
+}
+
+type Handler struct {
+	config     *Config
+	client     Client
+	instanceID string
+	waiter     *aws.WaiterParam
+}
+
+// New returns a handler moving the ENIs to or from instanceID through client,
+// waiting for them with wp.
+func New(config *Config, client Client, instanceID string, wp *aws.WaiterParam) *Handler {
+	return &Handler{config: config, client: client, instanceID: instanceID, waiter: wp}
+}
+
+// mapping returns the mapping of the VRRP instance or group.
+func (h *Handler) mapping(n *Notification) (*Mapping, error) {
+	mappings, kind := h.config.Instances, "instance"
+	if n.Type == TypeGroup {
+		mappings, kind = h.config.Groups, "group"
+	}
+	m, ok := mappings[n.Name]
+	if !ok {
+		return nil, fmt.Errorf("no mapping for VRRP %s %s", kind, n.Name)
+	}
+	return m, nil
+}
+
+// Handle grabs the ENIs of the VRRP instance or group on MASTER, and releases
+// them on BACKUP or FAULT if the mapping says so. It returns a DeadlineError
+// once the deadline has passed, even if an AWS API call is still hanging, so
+// that keepalived is never held up longer.
+func (h *Handler) Handle(ctx context.Context, n *Notification) error {
+	m, err := h.mapping(n)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Duration(h.config.Deadline)
+	ctx, cancel := context.WithTimeout(ctx, deadline)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- h.handle(ctx, n, m) }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return &DeadlineError{Notification: n, Deadline: deadline}
+		}
+		return ctx.Err()
+	}
+}
+
+func (h *Handler) handle(ctx context.Context, n *Notification, m *Mapping) error {
+	switch n.State {
+	case StateMaster:
+		return h.grab(ctx, m)
+	case StateBackup, StateFault:
+		if m.Release {
+			return h.release(ctx, m)
+		}
+	}
+	log.Infof("keepalived: nothing to do on %s", n)
+	return nil
+}
+
+func (h *Handler) grab(ctx context.Context, m *Mapping) error {
+	for _, e := range m.ENIs {
+		id, err := h.client.ResolveENIIDWithContext(ctx, e.ENI)
+		if err != nil {
+			return err
+		}
+		deviceIndex := aws.DeviceIndexAuto
+		if e.DeviceIndex != nil {
+			deviceIndex = *e.DeviceIndex
+		}
+
+		eni, err := h.client.GrabENIWithContext(ctx, &aws.GrabENIParam{
+			InterfaceID:            id,
+			InstanceID:             h.instanceID,
+			DeviceIndex:            deviceIndex,
+			ForceDetach:            m.ForceDetach,
+			ForceDetachGracePeriod: time.Duration(m.ForceDetachGracePeriod),
+		}, h.waiter)
+		if err != nil {
+			return err
+		}
+		if eni == nil {
+			log.Infof("keepalived: %s already attached to instance %s", id, h.instanceID)
+			continue
+		}
+		log.Infof("keepalived: %s attached to instance %s", id, h.instanceID)
+	}
+	return nil
+}
+
+// release detaches the ENIs attached to the local instance, and leaves the
+// ones that a new MASTER has already grabbed alone.
+func (h *Handler) release(ctx context.Context, m *Mapping) error {
+	for _, e := range m.ENIs {
+		id, err := h.client.ResolveENIIDWithContext(ctx, e.ENI)
+		if err != nil {
+			return err
+		}
+		eni, err := h.client.DescribeENIByIDWithContext(ctx, id)
+		if err != nil {
+			return err
+		}
+		if eni.AttachedInstanceID() != h.instanceID {
+			log.Infof("keepalived: %s not attached to instance %s", id, h.instanceID)
+			continue
+		}
+
+		if _, err := h.client.DetachENIWithWaiterWithContext(ctx, &aws.DetachENIParam{
+			InterfaceID:      id,
+			Force:            m.ForceDetach,
+			ForceGracePeriod: time.Duration(m.ForceDetachGracePeriod),
+		}, h.waiter); err != nil {
+			return err
+		}
+		log.Infof("keepalived: %s detached from instance %s", id, h.instanceID)
+	}
+	return nil
+}
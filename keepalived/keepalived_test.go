@@ -0,0 +1,159 @@
+package keepalived
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yuuki/grabeni/aws"
+	"github.com/yuuki/grabeni/aws/model"
+)
+
+// fakeClient attaches the ENIs to owner, and records the grabs and the
+// detaches. A grab blocks until block is closed if set.
+type fakeClient struct {
+	owner    string
+	grabs    []*aws.GrabENIParam
+	detaches []*aws.DetachENIParam
+	block    chan struct{}
+}
+
+func (c *fakeClient) ResolveENIIDWithContext(ctx context.Context, selector string) (string, error) {
+	if selector == "name:db-vip" {
+		return "eni-00000002", nil
+	}
+	return selector, nil
+}
+
+func (c *fakeClient) DescribeENIByIDWithContext(ctx context.Context, interfaceID string) (*model.ENI, error) {
+	iface := &ec2.NetworkInterface{NetworkInterfaceId: &interfaceID}
+	if c.owner != "" {
+		iface.Attachment = &ec2.NetworkInterfaceAttachment{InstanceId: &c.owner}
+	}
+	return model.NewENI(iface), nil
+}
+
+func (c *fakeClient) GrabENIWithContext(ctx context.Context, p *aws.GrabENIParam, wp *aws.WaiterParam) (*model.ENI, error) {
+	if c.block != nil {
+		<-c.block
+	}
+	c.grabs = append(c.grabs, p)
+	return model.NewENI(&ec2.NetworkInterface{NetworkInterfaceId: &p.InterfaceID}), nil
+}
+
+func (c *fakeClient) DetachENIWithWaiterWithContext(ctx context.Context, p *aws.DetachENIParam, wp *aws.WaiterParam) (*model.ENI, error) {
+	c.detaches = append(c.detaches, p)
+	return model.NewENI(&ec2.NetworkInterface{NetworkInterfaceId: &p.InterfaceID}), nil
+}
+
+const testConfig = `{
+	"deadline": "50ms",
+	"instances": {
+		"VI_1": {"enis": [{"eni": "eni-00000001", "device_index": 2}, {"eni": "name:db-vip"}], "release": true, "force_detach": true}
+	},
+	"groups": {
+		"VG_1": {"enis": [{"eni": "eni-00000003"}]}
+	}
+}`
+
+func newTestHandler(t *testing.T, client *fakeClient) *Handler {
+	config, err := ParseConfig([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(config, client, "i-00000002", &aws.WaiterParam{MaxAttempts: 1, IntervalSec: 1})
+}
+
+func TestParseNotification(t *testing.T) {
+	n, err := ParseNotification([]string{"INSTANCE", "VI_1", "MASTER", "100"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, &Notification{Type: TypeInstance, Name: "VI_1", State: StateMaster}, n)
+	}
+
+	errs := map[string][]string{
+		"TYPE NAME STATE required, got 2 arguments":      {"INSTANCE", "VI_1"},
+		`unknown type "VRRP", must be INSTANCE or GROUP`: {"VRRP", "VI_1", "MASTER"},
+		`unknown state "PRIMARY"`:                        {"GROUP", "VG_1", "PRIMARY"},
+		"TYPE NAME STATE required, got 5 arguments":      {"GROUP", "VG_1", "MASTER", "100", "x"},
+	}
+	for expected, args := range errs {
+		_, err := ParseNotification(args)
+		assert.EqualError(t, err, expected)
+	}
+}
+
+func TestHandleMaster(t *testing.T) {
+	client := &fakeClient{}
+	h := newTestHandler(t, client)
+
+	assert.NoError(t, h.Handle(context.Background(), &Notification{Type: TypeInstance, Name: "VI_1", State: StateMaster}))
+	if assert.Len(t, client.grabs, 2) {
+		assert.Equal(t, "eni-00000001", client.grabs[0].InterfaceID)
+		assert.Equal(t, "i-00000002", client.grabs[0].InstanceID)
+		assert.Equal(t, 2, client.grabs[0].DeviceIndex)
+		assert.True(t, client.grabs[0].ForceDetach)
+		assert.Equal(t, 10*time.Second, client.grabs[0].ForceDetachGracePeriod)
+		assert.Equal(t, "eni-00000002", client.grabs[1].InterfaceID)
+		assert.Equal(t, aws.DeviceIndexAuto, client.grabs[1].DeviceIndex)
+	}
+
+	assert.EqualError(t, h.Handle(context.Background(), &Notification{Type: TypeGroup, Name: "VI_1", State: StateMaster}), "no mapping for VRRP group VI_1")
+}
+
+func TestHandleRelease(t *testing.T) {
+	tests := []struct {
+		name     string
+		n        *Notification
+		owner    string
+		expected int
+	}{
+		{name: "backup", n: &Notification{Type: TypeInstance, Name: "VI_1", State: StateBackup}, owner: "i-00000002", expected: 2},
+		{name: "fault", n: &Notification{Type: TypeInstance, Name: "VI_1", State: StateFault}, owner: "i-00000002", expected: 2},
+		{name: "grabbed by new master", n: &Notification{Type: TypeInstance, Name: "VI_1", State: StateBackup}, owner: "i-00000001", expected: 0},
+		{name: "stop", n: &Notification{Type: TypeInstance, Name: "VI_1", State: StateStop}, owner: "i-00000002", expected: 0},
+		{name: "release unset", n: &Notification{Type: TypeGroup, Name: "VG_1", State: StateBackup}, owner: "i-00000002", expected: 0},
+	}
+
+	for _, tt := range tests {
+		client := &fakeClient{owner: tt.owner}
+		h := newTestHandler(t, client)
+
+		assert.NoError(t, h.Handle(context.Background(), tt.n), tt.name)
+		assert.Len(t, client.detaches, tt.expected, tt.name)
+		assert.Len(t, client.grabs, 0, tt.name)
+	}
+}
+
+func TestHandleDeadline(t *testing.T) {
+	client := &fakeClient{block: make(chan struct{})}
+	defer close(client.block)
+	h := newTestHandler(t, client)
+
+	start := time.Now()
+	err := h.Handle(context.Background(), &Notification{Type: TypeInstance, Name: "VI_1", State: StateMaster})
+
+	assert.EqualError(t, err, "INSTANCE VI_1 MASTER not handled within the deadline 50ms")
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestParseConfig(t *testing.T) {
+	c, err := ParseConfig([]byte(`{"groups": {"VG_1": {"enis": [{"eni": "eni-00000001"}]}}}`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, DefaultDeadline, time.Duration(c.Deadline))
+	}
+
+	errs := map[string]string{
+		`{}`:                                    "instances or groups required",
+		`{"instances": {"VI_1": {"enis": []}}}`: "instances: VI_1 requires enis",
+		`{"instances": {"VI_1": {"enis": [{"device_index": 1}]}}}`:                        "instances: VI_1 has an ENI without eni",
+		`{"instances": {"VI_1": {"enis": [{"eni": "eni-00000001", "device_index": 0}]}}}`: "instances: VI_1: device_index of eni-00000001 must be 1 or more",
+		`{"instances": {"VI_1": {"enis": [{"eni": "eni-00000001"}]}}, "timeout": "1s"}`:   `json: unknown field "timeout"`,
+	}
+	for config, expected := range errs {
+		_, err := ParseConfig([]byte(config))
+		assert.EqualError(t, err, expected, config)
+	}
+}
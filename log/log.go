@@ -2,6 +2,7 @@ package log
 
 import (
 	"log"
+	"os"
 )
 
 var IsDebug = false
@@ -37,3 +38,8 @@ func Error(v ...interface{}) {
 func Errorf(format string, v ...interface{}) {
 	log.Fatalf(format, v...)
 }
+
+func Exit(code int, v ...interface{}) {
+	log.Println(v...)
+	os.Exit(code)
+}
@@ -0,0 +1,27 @@
+package timeutil
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDurationUnmarshalJSON(t *testing.T) {
+	var d Duration
+	assert.NoError(t, json.Unmarshal([]byte(`"1m30s"`), &d))
+	assert.Equal(t, Duration(90*time.Second), d)
+
+	assert.EqualError(t, json.Unmarshal([]byte(`2`), &d), `duration must be a string such as "2s": 2`)
+	assert.Error(t, json.Unmarshal([]byte(`"2 seconds"`), &d))
+}
+
+func TestSleepContext(t *testing.T) {
+	assert.NoError(t, SleepContext(context.Background(), time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, SleepContext(ctx, time.Hour))
+}
@@ -0,0 +1,246 @@
+package watchd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yuuki/grabeni/aws"
+	"github.com/yuuki/grabeni/health"
+	"github.com/yuuki/grabeni/heartbeat"
+	"github.com/yuuki/grabeni/timeutil"
+)
+
+// Default values of Config
+const (
+	DefaultInterval               = 2 * time.Second
+	DefaultRise                   = 2
+	DefaultFall                   = 3
+	DefaultCooldown               = 30 * time.Second
+	DefaultForceDetachGracePeriod = 10 * time.Second
+)
+
+// OwnerIPPlaceholder in the address, the URL or the command of an owner check
+// is replaced with the primary private IP of the instance owning the ENIs.
+const OwnerIPPlaceholder = "{owner_ip}"
+
+// Config is the configuration of the daemon, read from a JSON file.
+type Config struct {
+	// InstanceID is the local instance, the instance grabeni runs on if empty.
+	InstanceID string `json:"instance_id"`
+	// ENIs are grabbed all together to the local instance.
+	ENIs []string `json:"enis"`
+	// DeviceIndex is the device index to attach a single ENI at. Free ones
+	// are picked if unset.
+	DeviceIndex *int `json:"device_index"`
+
+	// Interval is the period of the health checks.
+	Interval timeutil.Duration `json:"interval"`
+	// Rise is the number of successful checks in a row to turn healthy, and
+	// Fall the number of failed checks in a row to turn failed.
+	Rise int `json:"rise"`
+	Fall int `json:"fall"`
+	// Cooldown is the minimum time between two grabs.
+	Cooldown timeutil.Duration `json:"cooldown"`
+
+	// Priority decides which node takes over: a node with a higher priority
+	// preempts a healthy owner with a lower one if Preempt is set, and nodes
+	// with lower priorities wait TakeoverDelay for each peer with a higher
+	// priority before taking over from a failed owner.
+	Priority      int               `json:"priority"`
+	Preempt       bool              `json:"preempt"`
+	TakeoverDelay timeutil.Duration `json:"takeover_delay"`
+	Peers         []PeerConfig      `json:"peers"`
+
+	// Checks probe the local node, which must pass all of them to grab.
+	Checks []CheckConfig `json:"checks"`
+	// OwnerChecks probe the node owning the ENIs, which is deemed failed once
+	// it fails any of them Fall times in a row, or if it isn't running. If
+	// empty, only the instance state is looked at.
+	OwnerChecks []CheckConfig `json:"owner_checks"`
+
+	// Heartbeat, if set, exchanges heartbeats with the peers, and then the
+	// owner is deemed failed only once it has gone silent or stopped
+	// announcing the ownership, and the local node takes over only with the
+	// highest priority among the live healthy nodes. Peers, OwnerChecks and
+	// TakeoverDelay are left unused.
+	Heartbeat *HeartbeatConfig `json:"heartbeat"`
+
+	// LeaseTTL, if set, makes the node take the leases of the ENIs in their
+	// tags when grabbing them, and renew the leases while it is healthy, so
+	// that another grabber backs off until they expire.
+	LeaseTTL         timeutil.Duration `json:"lease_ttl"`
+	LeaseSettleDelay timeutil.Duration `json:"lease_settle_delay"`
+
+	ForceDetach            bool              `json:"force_detach"`
+	ForceDetachGracePeriod timeutil.Duration `json:"force_detach_grace_period"`
+}
+
+// HeartbeatConfig is the UDP heartbeat exchange between the nodes, which are
+// identified by their instance IDs.
+type HeartbeatConfig struct {
+	// Listen is the UDP address to receive heartbeats on, such as ":7946".
+	Listen string `json:"listen"`
+	// Peers are the UDP addresses of the other nodes.
+	Peers    []string          `json:"peers"`
+	Interval timeutil.Duration `json:"interval"`
+	// Timeout is the silence after which a node is deemed dead.
+	Timeout timeutil.Duration `json:"timeout"`
+}
+
+// PeerConfig is another node competing for the ENIs.
+type PeerConfig struct {
+	InstanceID string `json:"instance_id"`
+	Priority   int    `json:"priority"`
+}
+
+// CheckConfig is a health check of type "tcp", "http" or "exec".
+type CheckConfig struct {
+	Type string `json:"type"`
+	// Address is the host:port to connect to for "tcp".
+	Address string `json:"address"`
+	// URL is the URL to GET for "http", which is healthy with ExpectStatus
+	// or any 2xx status if ExpectStatus is 0.
+	URL          string `json:"url"`
+	ExpectStatus int    `json:"expect_status"`
+	// Command is the program and its arguments to run for "exec".
+	Command []string          `json:"command"`
+	Timeout timeutil.Duration `json:"timeout"`
+}
+
+// checker builds the checker, replacing the placeholders with r.
+func (c *CheckConfig) checker(r *strings.Replacer) (health.Checker, error) {
+	timeout := time.Duration(c.Timeout)
+	switch c.Type {
+	case "tcp":
+		if c.Address == "" {
+			return nil, fmt.Errorf("tcp check requires address")
+		}
+		return &health.TCPChecker{Address: r.Replace(c.Address), Timeout: timeout}, nil
+	case "http":
+		if c.URL == "" {
+			return nil, fmt.Errorf("http check requires url")
+		}
+		return &health.HTTPChecker{URL: r.Replace(c.URL), ExpectStatus: c.ExpectStatus, Timeout: timeout}, nil
+	case "exec":
+		if len(c.Command) == 0 {
+			return nil, fmt.Errorf("exec check requires command")
+		}
+		command := make([]string, 0, len(c.Command))
+		for _, arg := range c.Command {
+			command = append(command, r.Replace(arg))
+		}
+		return &health.ExecChecker{Command: command, Timeout: timeout}, nil
+	}
+	return nil, fmt.Errorf("unknown check type %q", c.Type)
+}
+
+func buildCheckers(configs []CheckConfig, r *strings.Replacer) ([]health.Checker, error) {
+	checkers := make([]health.Checker, 0, len(configs))
+	for i := range configs {
+		c, err := configs[i].checker(r)
+		if err != nil {
+			return nil, err
+		}
+		checkers = append(checkers, c)
+	}
+	return checkers, nil
+}
+
+// LoadConfig reads the configuration from the JSON file at path, and fills
+// in the defaults.
+func LoadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config, err := ParseConfig(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return config, nil
+}
+
+// ParseConfig parses the configuration in JSON, and fills in the defaults.
+func ParseConfig(b []byte) (*Config, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+
+	config := &Config{}
+	if err := dec.Decode(config); err != nil {
+		return nil, err
+	}
+
+	if config.Interval == 0 {
+		config.Interval = timeutil.Duration(DefaultInterval)
+	}
+	if config.Rise == 0 {
+		config.Rise = DefaultRise
+	}
+	if config.Fall == 0 {
+		config.Fall = DefaultFall
+	}
+	if config.Cooldown == 0 {
+		config.Cooldown = timeutil.Duration(DefaultCooldown)
+	}
+	if hb := config.Heartbeat; hb != nil {
+		if hb.Interval == 0 {
+			hb.Interval = timeutil.Duration(heartbeat.DefaultInterval)
+		}
+		if hb.Timeout == 0 {
+			hb.Timeout = timeutil.Duration(heartbeat.DefaultTimeout)
+		}
+	}
+	if config.LeaseSettleDelay == 0 {
+		config.LeaseSettleDelay = timeutil.Duration(aws.DefaultLeaseSettleDelay)
+	}
+	if config.ForceDetachGracePeriod == 0 {
+		config.ForceDetachGracePeriod = timeutil.Duration(DefaultForceDetachGracePeriod)
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (c *Config) validate() error {
+	if len(c.ENIs) == 0 {
+		return fmt.Errorf("enis required")
+	}
+	if c.DeviceIndex != nil && len(c.ENIs) > 1 {
+		return fmt.Errorf("device_index can't be shared by %d ENIs", len(c.ENIs))
+	}
+	if c.Interval < 0 || c.Cooldown < 0 || c.TakeoverDelay < 0 {
+		return fmt.Errorf("interval, cooldown and takeover_delay must not be negative")
+	}
+	if c.LeaseTTL != 0 && c.LeaseTTL < 3*c.Interval {
+		return fmt.Errorf("lease_ttl must be at least three times the interval")
+	}
+	if c.Rise < 1 || c.Fall < 1 {
+		return fmt.Errorf("rise and fall must be 1 or more")
+	}
+	if hb := c.Heartbeat; hb != nil {
+		if hb.Listen == "" {
+			return fmt.Errorf("heartbeat requires listen")
+		}
+		if hb.Timeout <= hb.Interval {
+			return fmt.Errorf("heartbeat timeout must be longer than its interval")
+		}
+	}
+	if len(c.Checks) == 0 {
+		return fmt.Errorf("checks required")
+	}
+
+	noop := strings.NewReplacer()
+	if _, err := buildCheckers(c.Checks, noop); err != nil {
+		return fmt.Errorf("checks: %s", err)
+	}
+	if _, err := buildCheckers(c.OwnerChecks, noop); err != nil {
+		return fmt.Errorf("owner_checks: %s", err)
+	}
+	return nil
+}
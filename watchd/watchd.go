@@ -0,0 +1,390 @@
+// Package watchd implements a daemon that grabs ENIs to the local node when
+// the local node is healthy and the node owning the ENIs has failed.
+package watchd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yuuki/grabeni/aws"
+	"github.com/yuuki/grabeni/aws/model"
+	"github.com/yuuki/grabeni/health"
+	"github.com/yuuki/grabeni/heartbeat"
+	"github.com/yuuki/grabeni/log"
+	"github.com/yuuki/grabeni/timeutil"
+)
+
+// Client is the part of aws.ENIClient that the daemon uses.
+type Client interface {
+	DescribeENIByIDWithContext(ctx context.Context, interfaceID string) (*model.ENI, error)
+	DescribeInstanceByIDWithContext(ctx context.Context, instanceID string) (*model.Instance, error)
+	GrabENIsWithContext(ctx context.Context, p *aws.GrabENIsParam, wp *aws.WaiterParam) ([]*aws.ENIResult, error)
+	RenewLeaseWithContext(ctx context.Context, p *aws.LeaseParam) (*aws.Lease, error)
+}
+
+// threshold turns a series of check results into a state with hysteresis. It
+// turns up after rise successes in a row, and down after fall failures in a row.
+type threshold struct {
+	rise, fall int
+	up         bool
+	// count is the number of results in a row against the current state
+	count int
+}
+
+// observe records a check result and reports whether the state has changed.
+func (t *threshold) observe(ok bool) bool {
+	if ok == t.up {
+		t.count = 0
+		return false
+	}
+
+	t.count++
+	limit := t.rise
+	if t.up {
+		limit = t.fall
+	}
+	if t.count < limit {
+		return false
+	}
+
+	t.up, t.count = ok, 0
+	return true
+}
+
+type Daemon struct {
+	config *Config
+	client Client
+	waiter *aws.WaiterParam
+	checks []health.Checker
+	// heartbeat is nil unless Heartbeat is configured
+	heartbeat *heartbeat.Node
+
+	// local is the health of the local node, which starts failed
+	local *threshold
+	// owner is the health of ownerID, which starts healthy
+	owner    *threshold
+	ownerID  string
+	lastGrab time.Time
+	// lastRenew is when the leases were last taken or renewed
+	lastRenew time.Time
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// New returns a daemon for config, whose InstanceID must be set. It grabs the
+// ENIs through client, waiting for them with wp.
+func New(config *Config, client Client, wp *aws.WaiterParam) (*Daemon, error) {
+	if config.InstanceID == "" {
+		return nil, errors.New("instance ID of the local node required")
+	}
+	checks, err := buildCheckers(config.Checks, strings.NewReplacer())
+	if err != nil {
+		return nil, err
+	}
+
+	var hb *heartbeat.Node
+	if c := config.Heartbeat; c != nil {
+		hb, err = heartbeat.Listen(&heartbeat.Config{
+			NodeID:   config.InstanceID,
+			Priority: config.Priority,
+			Listen:   c.Listen,
+			Peers:    c.Peers,
+			Interval: time.Duration(c.Interval),
+			Timeout:  time.Duration(c.Timeout),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("heartbeat: %s", err)
+		}
+	}
+
+	return &Daemon{
+		config:    config,
+		client:    client,
+		waiter:    wp,
+		checks:    checks,
+		heartbeat: hb,
+		local:     &threshold{rise: config.Rise, fall: config.Fall},
+		owner:     &threshold{rise: config.Rise, fall: config.Fall, up: true},
+		now:       time.Now,
+		sleep:     timeutil.SleepContext,
+	}, nil
+}
+
+// Run checks every Interval until ctx is done. Errors of a round are logged
+// rather than stopping the daemon.
+func (d *Daemon) Run(ctx context.Context) error {
+	log.Infof("watchd: watching %s for instance %s (priority %d)", strings.Join(d.config.ENIs, ", "), d.config.InstanceID, d.config.Priority)
+
+	if d.heartbeat != nil {
+		log.Infof("watchd: exchanging heartbeats on %s", d.heartbeat.LocalAddr())
+		go func() {
+			if err := d.heartbeat.Run(ctx); err != nil {
+				log.Infof("watchd: heartbeat: %s", err)
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(time.Duration(d.config.Interval))
+	defer ticker.Stop()
+
+	for {
+		if err := d.tick(ctx); err != nil && ctx.Err() == nil {
+			log.Infof("watchd: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Infof("watchd: stopped")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// tick runs a round of the checks, and grabs the ENIs if the local node is
+// healthy and the owner has failed or is to be preempted.
+func (d *Daemon) tick(ctx context.Context) error {
+	err := health.CheckAll(ctx, d.checks)
+	if d.local.observe(err == nil) {
+		if d.local.up {
+			log.Infof("watchd: local node is healthy")
+		} else {
+			log.Infof("watchd: local node has failed: %s", err)
+		}
+	}
+
+	owned, ownerID, err := d.ownership(ctx)
+	if err != nil {
+		return err
+	}
+	if d.heartbeat != nil {
+		d.heartbeat.SetState(owned, d.local.up)
+	}
+	if owned {
+		// Judge the next owner afresh, even if it is the same as the last one
+		d.ownerID = ""
+		return d.renewLeases(ctx)
+	}
+	if !d.local.up {
+		return nil
+	}
+
+	if ownerID != d.ownerID {
+		d.ownerID = ownerID
+		d.owner = &threshold{rise: d.config.Rise, fall: d.config.Fall, up: true}
+	}
+
+	reason, err := d.takeoverReason(ctx, ownerID)
+	if err != nil || reason == "" {
+		return err
+	}
+
+	if since := d.now().Sub(d.lastGrab); since < time.Duration(d.config.Cooldown) {
+		log.Infof("watchd: not grabbing (%s) within cooldown, %s since the last grab", reason, since.Round(time.Second))
+		return nil
+	}
+
+	if delay := d.takeoverDelay(ownerID); delay > 0 {
+		log.Infof("watchd: waiting %s for the peers with higher priorities to take over (%s)", delay, reason)
+		if err := d.sleep(ctx, delay); err != nil {
+			return err
+		}
+		owned, current, err := d.ownership(ctx)
+		if err != nil {
+			return err
+		}
+		if owned || current != ownerID {
+			log.Infof("watchd: %s has taken over meanwhile", current)
+			return nil
+		}
+	}
+
+	return d.grab(ctx, reason)
+}
+
+// renewLeases renews the leases of the ENIs once a third of their TTL has
+// passed, taking the ones missing such as at startup. Each ENI is renewed
+// even if another fails, and the failed ones are retried on the next round.
+// A failed node lets the leases expire, so that a peer can take over.
+func (d *Daemon) renewLeases(ctx context.Context) error {
+	ttl := time.Duration(d.config.LeaseTTL)
+	if ttl == 0 || !d.local.up || d.now().Sub(d.lastRenew) < ttl/3 {
+		return nil
+	}
+
+	var errs []string
+	for _, id := range d.config.ENIs {
+		_, err := d.client.RenewLeaseWithContext(ctx, &aws.LeaseParam{
+			InterfaceID: id,
+			Owner:       d.config.InstanceID,
+			TTL:         ttl,
+			SettleDelay: time.Duration(d.config.LeaseSettleDelay),
+		})
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("renewing leases failed: %s", strings.Join(errs, "; "))
+	}
+	d.lastRenew = d.now()
+	return nil
+}
+
+// ownership reports whether the local node owns all the ENIs, and otherwise
+// which instance owns them, empty if none does.
+func (d *Daemon) ownership(ctx context.Context) (bool, string, error) {
+	owned, ownerID := true, ""
+	for _, id := range d.config.ENIs {
+		eni, err := d.client.DescribeENIByIDWithContext(ctx, id)
+		if err != nil {
+			return false, "", err
+		}
+		instanceID := eni.AttachedInstanceID()
+		if instanceID == d.config.InstanceID {
+			continue
+		}
+		owned = false
+		if ownerID == "" {
+			ownerID = instanceID
+		}
+	}
+	return owned, ownerID, nil
+}
+
+// takeoverReason returns why the local node should take over the ENIs from
+// ownerID, or "" if it shouldn't.
+func (d *Daemon) takeoverReason(ctx context.Context, ownerID string) (string, error) {
+	if d.heartbeat != nil {
+		return d.heartbeatTakeoverReason(ownerID), nil
+	}
+	if ownerID == "" {
+		return "no owner", nil
+	}
+
+	instance, err := d.client.DescribeInstanceByIDWithContext(ctx, ownerID)
+	var notFound *aws.InstanceNotFoundError
+	if errors.As(err, &notFound) {
+		return fmt.Sprintf("owner %s not found", ownerID), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if state := instance.StateName(); state != "running" {
+		return fmt.Sprintf("owner %s is %s", ownerID, state), nil
+	}
+
+	if len(d.config.OwnerChecks) > 0 {
+		checks, err := buildCheckers(d.config.OwnerChecks, strings.NewReplacer(OwnerIPPlaceholder, instance.PrivateIP()))
+		if err != nil {
+			return "", err
+		}
+		err = health.CheckAll(ctx, checks)
+		if d.owner.observe(err == nil) {
+			if d.owner.up {
+				log.Infof("watchd: owner %s has recovered", ownerID)
+			} else {
+				log.Infof("watchd: owner %s has failed: %s", ownerID, err)
+			}
+		}
+		if !d.owner.up {
+			return fmt.Sprintf("owner %s has failed", ownerID), nil
+		}
+	}
+
+	if priority, ok := d.peerPriority(ownerID); ok && d.config.Preempt && d.config.Priority > priority {
+		return fmt.Sprintf("preempting owner %s of priority %d", ownerID, priority), nil
+	}
+	return "", nil
+}
+
+// heartbeatTakeoverReason is takeoverReason judged by the heartbeats. No node
+// takes over while a live peer announces the ownership, except for preempting
+// it, and only the live healthy node with the highest priority takes over.
+func (d *Daemon) heartbeatTakeoverReason(ownerID string) string {
+	hb := d.heartbeat
+	if !hb.Ready() || !hb.Highest() {
+		return ""
+	}
+
+	if owner, ok := hb.LiveOwner(); ok {
+		if d.config.Preempt && d.config.Priority > owner.Priority {
+			return fmt.Sprintf("preempting owner %s of priority %d", owner.NodeID, owner.Priority)
+		}
+		return ""
+	}
+
+	switch {
+	case ownerID == "":
+		return "no owner"
+	case hb.Alive(ownerID):
+		return fmt.Sprintf("owner %s has released", ownerID)
+	}
+	return fmt.Sprintf("owner %s is silent", ownerID)
+}
+
+// peerPriority returns the priority of the peer, or false if instanceID is
+// not a peer. An owner that is not a peer is never preempted.
+func (d *Daemon) peerPriority(instanceID string) (int, bool) {
+	for _, p := range d.config.Peers {
+		if p.InstanceID == instanceID {
+			return p.Priority, true
+		}
+	}
+	return 0, false
+}
+
+// takeoverDelay returns how long to wait for the peers with priorities higher
+// than the local node, other than the owner, to take over first. There is no
+// delay with the heartbeats, which already leave the takeover to the highest.
+func (d *Daemon) takeoverDelay(ownerID string) time.Duration {
+	if d.heartbeat != nil {
+		return 0
+	}
+	rank := 0
+	for _, p := range d.config.Peers {
+		if p.InstanceID != ownerID && p.InstanceID != d.config.InstanceID && p.Priority > d.config.Priority {
+			rank++
+		}
+	}
+	return time.Duration(rank) * time.Duration(d.config.TakeoverDelay)
+}
+
+func (d *Daemon) grab(ctx context.Context, reason string) error {
+	log.Infof("watchd: grabbing %s (%s)", strings.Join(d.config.ENIs, ", "), reason)
+	d.lastGrab = d.now()
+
+	deviceIndex := aws.DeviceIndexAuto
+	if d.config.DeviceIndex != nil {
+		deviceIndex = *d.config.DeviceIndex
+	}
+	results, err := d.client.GrabENIsWithContext(ctx, &aws.GrabENIsParam{
+		GrabENIParam: aws.GrabENIParam{
+			InstanceID:             d.config.InstanceID,
+			DeviceIndex:            deviceIndex,
+			ForceDetach:            d.config.ForceDetach,
+			ForceDetachGracePeriod: time.Duration(d.config.ForceDetachGracePeriod),
+			LeaseTTL:               time.Duration(d.config.LeaseTTL),
+			LeaseSettleDelay:       time.Duration(d.config.LeaseSettleDelay),
+		},
+		InterfaceIDs: d.config.ENIs,
+		Concurrency:  len(d.config.ENIs),
+		Atomic:       true,
+	}, d.waiter)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		if r.Err != nil {
+			return fmt.Errorf("grabbing %s failed: %s", r.InterfaceID, r.Err)
+		}
+	}
+	d.lastRenew = d.now()
+	log.Infof("watchd: grabbed %s", strings.Join(d.config.ENIs, ", "))
+	return nil
+}
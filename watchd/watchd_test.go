@@ -0,0 +1,386 @@
+package watchd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yuuki/grabeni/aws"
+	"github.com/yuuki/grabeni/aws/model"
+	"github.com/yuuki/grabeni/health"
+	"github.com/yuuki/grabeni/heartbeat"
+	"github.com/yuuki/grabeni/timeutil"
+)
+
+// fakeClient attaches the ENIs to owner, and records the grabs.
+type fakeClient struct {
+	owner      string
+	ownerState string
+	grabs      []*aws.GrabENIsParam
+	grabErr    error
+	renews     []string
+	// leases are the leases by ENI ID, as stored in the tags of the ENIs
+	leases map[string]*aws.Lease
+}
+
+func (c *fakeClient) DescribeENIByIDWithContext(ctx context.Context, interfaceID string) (*model.ENI, error) {
+	iface := &ec2.NetworkInterface{
+		NetworkInterfaceId: strPtr(interfaceID),
+		Status:             strPtr("available"),
+	}
+	if c.owner != "" {
+		iface.Status = strPtr("in-use")
+		iface.Attachment = &ec2.NetworkInterfaceAttachment{
+			InstanceId: strPtr(c.owner),
+			Status:     strPtr("attached"),
+		}
+	}
+	return model.NewENI(iface), nil
+}
+
+func (c *fakeClient) DescribeInstanceByIDWithContext(ctx context.Context, instanceID string) (*model.Instance, error) {
+	return model.NewInstance(&ec2.Instance{
+		InstanceId:       strPtr(instanceID),
+		PrivateIpAddress: strPtr("127.0.0.1"),
+		State:            &ec2.InstanceState{Name: strPtr(c.ownerState)},
+	}), nil
+}
+
+func (c *fakeClient) GrabENIsWithContext(ctx context.Context, p *aws.GrabENIsParam, wp *aws.WaiterParam) ([]*aws.ENIResult, error) {
+	c.grabs = append(c.grabs, p)
+	if c.grabErr != nil {
+		return []*aws.ENIResult{{InterfaceID: p.InterfaceIDs[0], Outcome: aws.OutcomeFailed, Err: c.grabErr}}, nil
+	}
+	c.owner = p.InstanceID
+	return []*aws.ENIResult{{InterfaceID: p.InterfaceIDs[0], Outcome: aws.OutcomeMoved}}, nil
+}
+
+func (c *fakeClient) RenewLeaseWithContext(ctx context.Context, p *aws.LeaseParam) (*aws.Lease, error) {
+	c.renews = append(c.renews, p.InterfaceID)
+
+	// Like aws.ENIClient, take a missing or expired lease, and fail on one
+	// held by another instance
+	lease, ok := c.leases[p.InterfaceID]
+	if !ok {
+		lease = &aws.Lease{InterfaceID: p.InterfaceID}
+	}
+	now := time.Now()
+	if lease.HeldByOther(p.Owner, now) {
+		return nil, &aws.LeaseLostError{InterfaceID: p.InterfaceID, Owner: lease.Owner}
+	}
+	if lease.Owner != p.Owner {
+		lease.Token++
+	}
+	lease.Owner, lease.Expiry = p.Owner, now.Add(p.TTL)
+	if c.leases == nil {
+		c.leases = make(map[string]*aws.Lease)
+	}
+	c.leases[p.InterfaceID] = lease
+	return lease, nil
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+// fakeChecker fails with err
+type fakeChecker struct {
+	err error
+}
+
+func (c *fakeChecker) Check(ctx context.Context) error { return c.err }
+func (c *fakeChecker) String() string                  { return "fake" }
+
+func newTestDaemon(t *testing.T, config string, client *fakeClient) (*Daemon, *fakeChecker) {
+	c, err := ParseConfig([]byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := New(c, client, &aws.WaiterParam{MaxAttempts: 1, IntervalSec: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checker := &fakeChecker{}
+	d.checks = []health.Checker{checker}
+	d.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+	return d, checker
+}
+
+const baseConfig = `{
+	"instance_id": "i-00000002",
+	"enis": ["eni-00000001"],
+	"rise": 2,
+	"fall": 2,
+	"checks": [{"type": "exec", "command": ["true"]}]
+`
+
+func TestTickRise(t *testing.T) {
+	client := &fakeClient{owner: "i-00000001", ownerState: "stopped"}
+	d, checker := newTestDaemon(t, baseConfig+`}`, client)
+
+	// Not healthy until rise checks in a row pass
+	checker.err = errors.New("down")
+	assert.NoError(t, d.tick(context.Background()))
+	checker.err = nil
+	assert.NoError(t, d.tick(context.Background()))
+	assert.Len(t, client.grabs, 0)
+
+	assert.NoError(t, d.tick(context.Background()))
+	if assert.Len(t, client.grabs, 1) {
+		assert.Equal(t, "i-00000002", client.grabs[0].InstanceID)
+		assert.Equal(t, []string{"eni-00000001"}, client.grabs[0].InterfaceIDs)
+		assert.True(t, client.grabs[0].Atomic)
+	}
+
+	// Nothing to do once owned
+	assert.NoError(t, d.tick(context.Background()))
+	assert.Len(t, client.grabs, 1)
+}
+
+func TestTickOwnerChecks(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+
+	client := &fakeClient{owner: "i-00000001", ownerState: "running"}
+	d, _ := newTestDaemon(t, baseConfig+`,
+		"owner_checks": [{"type": "tcp", "address": "{owner_ip}:`+port+`"}]
+	}`, client)
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, d.tick(context.Background()))
+	}
+	assert.Len(t, client.grabs, 0, "owner is healthy")
+
+	// The owner fails fall checks in a row
+	l.Close()
+	assert.NoError(t, d.tick(context.Background()))
+	assert.Len(t, client.grabs, 0)
+	assert.NoError(t, d.tick(context.Background()))
+	assert.Len(t, client.grabs, 1)
+}
+
+func TestTickPreempt(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		expected int
+	}{
+		{name: "higher priority", config: `, "priority": 100, "preempt": true, "peers": [{"instance_id": "i-00000001", "priority": 50}]}`, expected: 1},
+		{name: "without preempt", config: `, "priority": 100, "peers": [{"instance_id": "i-00000001", "priority": 50}]}`, expected: 0},
+		{name: "lower priority", config: `, "priority": 10, "preempt": true, "peers": [{"instance_id": "i-00000001", "priority": 50}]}`, expected: 0},
+		{name: "unknown owner", config: `, "priority": 100, "preempt": true}`, expected: 0},
+	}
+
+	for _, tt := range tests {
+		client := &fakeClient{owner: "i-00000001", ownerState: "running"}
+		d, _ := newTestDaemon(t, baseConfig+tt.config, client)
+
+		for i := 0; i < 2; i++ {
+			assert.NoError(t, d.tick(context.Background()), tt.name)
+		}
+		assert.Len(t, client.grabs, tt.expected, tt.name)
+	}
+}
+
+func TestTickCooldown(t *testing.T) {
+	client := &fakeClient{ownerState: "running", grabErr: errors.New("AttachmentLimitExceeded")}
+	d, _ := newTestDaemon(t, baseConfig+`, "rise": 1, "cooldown": "1m"}`, client)
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	d.now = func() time.Time { return now }
+
+	assert.EqualError(t, d.tick(context.Background()), "grabbing eni-00000001 failed: AttachmentLimitExceeded")
+	assert.NoError(t, d.tick(context.Background()))
+	assert.Len(t, client.grabs, 1, "within cooldown")
+
+	now = now.Add(time.Minute)
+	client.grabErr = nil
+	assert.NoError(t, d.tick(context.Background()))
+	assert.Len(t, client.grabs, 2)
+}
+
+func TestTickLease(t *testing.T) {
+	client := &fakeClient{ownerState: "running"}
+	d, checker := newTestDaemon(t, baseConfig+`, "rise": 1, "fall": 1, "lease_ttl": "30s"}`, client)
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	d.now = func() time.Time { return now }
+
+	assert.NoError(t, d.tick(context.Background()))
+	if assert.Len(t, client.grabs, 1) {
+		assert.Equal(t, 30*time.Second, client.grabs[0].LeaseTTL)
+		assert.Equal(t, aws.DefaultLeaseSettleDelay, client.grabs[0].LeaseSettleDelay)
+	}
+
+	// Renewed once a third of the TTL has passed since the grab
+	now = now.Add(5 * time.Second)
+	assert.NoError(t, d.tick(context.Background()))
+	assert.Len(t, client.renews, 0)
+	now = now.Add(5 * time.Second)
+	assert.NoError(t, d.tick(context.Background()))
+	assert.Equal(t, []string{"eni-00000001"}, client.renews)
+
+	// Not renewed while failed
+	checker.err = errors.New("down")
+	now = now.Add(10 * time.Second)
+	assert.NoError(t, d.tick(context.Background()))
+	assert.Len(t, client.renews, 1)
+}
+
+// TestTickLeaseOwned starts the daemon on the node already owning the ENIs,
+// which have no lease or one held by another instance.
+func TestTickLeaseOwned(t *testing.T) {
+	client := &fakeClient{owner: "i-00000002", ownerState: "running", leases: map[string]*aws.Lease{
+		"eni-00000001": {InterfaceID: "eni-00000001", Owner: "i-00000009", Expiry: time.Now().Add(time.Minute), Token: 3},
+	}}
+	d, _ := newTestDaemon(t, baseConfig+`, "enis": ["eni-00000001", "eni-00000002"], "rise": 1, "lease_ttl": "30s"}`, client)
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	d.now = func() time.Time { return now }
+
+	assert.EqualError(t, d.tick(context.Background()), "renewing leases failed: lease of eni-00000001 has been taken by instance i-00000009")
+	assert.Len(t, client.grabs, 0)
+	if assert.Contains(t, client.leases, "eni-00000002", "missing lease taken despite the failure of the other") {
+		assert.Equal(t, "i-00000002", client.leases["eni-00000002"].Owner)
+	}
+
+	// The failed one is retried on the next round
+	delete(client.leases, "eni-00000001")
+	assert.NoError(t, d.tick(context.Background()))
+	assert.Equal(t, []string{"eni-00000001", "eni-00000002", "eni-00000001", "eni-00000002"}, client.renews)
+	assert.Equal(t, "i-00000002", client.leases["eni-00000001"].Owner)
+}
+
+func TestTakeoverDelay(t *testing.T) {
+	d, _ := newTestDaemon(t, baseConfig+`,
+		"priority": 50,
+		"takeover_delay": "5s",
+		"peers": [
+			{"instance_id": "i-00000001", "priority": 100},
+			{"instance_id": "i-00000003", "priority": 80},
+			{"instance_id": "i-00000004", "priority": 60},
+			{"instance_id": "i-00000005", "priority": 10}
+		]
+	}`, &fakeClient{})
+
+	assert.Equal(t, 10*time.Second, d.takeoverDelay("i-00000001"))
+	assert.Equal(t, 15*time.Second, d.takeoverDelay(""))
+}
+
+// TestTickHeartbeat runs the owner as another heartbeat node on localhost.
+func TestTickHeartbeat(t *testing.T) {
+	heartbeatConfig := `, "rise": 1, "priority": %d, "preempt": true,
+		"heartbeat": {"listen": "127.0.0.1:0", "interval": "10ms", "timeout": "100ms"}
+	}`
+	tests := []struct {
+		name     string
+		priority int
+		expected []int
+	}{
+		{name: "lower priority", priority: 100, expected: []int{0, 1}},
+		{name: "preempt", priority: 300, expected: []int{1, 1}},
+	}
+
+	for _, tt := range tests {
+		client := &fakeClient{owner: "i-00000001", ownerState: "running"}
+		d, _ := newTestDaemon(t, baseConfig+fmt.Sprintf(heartbeatConfig, tt.priority), client)
+
+		owner, err := heartbeat.Listen(&heartbeat.Config{
+			NodeID:   "i-00000001",
+			Priority: 200,
+			Listen:   "127.0.0.1:0",
+			Peers:    []string{d.heartbeat.LocalAddr().String()},
+			Interval: 10 * time.Millisecond,
+			Timeout:  100 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		owner.SetState(true, true)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		ownerCtx, stopOwner := context.WithCancel(ctx)
+		go d.heartbeat.Run(ctx)
+		go owner.Run(ownerCtx)
+
+		// Not ready until the timeout has passed
+		assert.NoError(t, d.tick(ctx), tt.name)
+		assert.Len(t, client.grabs, 0, tt.name)
+
+		time.Sleep(150 * time.Millisecond)
+		assert.True(t, d.heartbeat.Alive("i-00000001"), tt.name)
+		assert.NoError(t, d.tick(ctx), tt.name)
+		assert.Len(t, client.grabs, tt.expected[0], tt.name)
+
+		// The owner goes silent
+		stopOwner()
+		time.Sleep(150 * time.Millisecond)
+		assert.NoError(t, d.tick(ctx), tt.name)
+		assert.Len(t, client.grabs, tt.expected[1], tt.name)
+
+		cancel()
+	}
+}
+
+func TestThreshold(t *testing.T) {
+	th := &threshold{rise: 2, fall: 3}
+
+	var states []bool
+	for _, ok := range []bool{true, false, true, true, false, false, true, false, false, false} {
+		th.observe(ok)
+		states = append(states, th.up)
+	}
+
+	assert.Equal(t, []bool{false, false, false, true, true, true, true, true, true, false}, states)
+}
+
+func TestParseConfig(t *testing.T) {
+	c, err := ParseConfig([]byte(`{"enis": ["eni-00000001"], "checks": [{"type": "tcp", "address": "127.0.0.1:3306"}]}`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, timeutil.Duration(DefaultInterval), c.Interval)
+		assert.Equal(t, DefaultRise, c.Rise)
+		assert.Equal(t, DefaultFall, c.Fall)
+		assert.Equal(t, timeutil.Duration(DefaultCooldown), c.Cooldown)
+	}
+
+	c, err = ParseConfig([]byte(`{"enis": ["eni-00000001"], "checks": [{"type": "exec", "command": ["true"]}], "heartbeat": {"listen": ":7946"}}`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, timeutil.Duration(heartbeat.DefaultInterval), c.Heartbeat.Interval)
+		assert.Equal(t, timeutil.Duration(heartbeat.DefaultTimeout), c.Heartbeat.Timeout)
+	}
+
+	errs := map[string]string{
+		`{"checks": [{"type": "tcp", "address": "127.0.0.1:3306"}]}`:                                                                       "enis required",
+		`{"enis": ["eni-00000001"]}`:                                                                                                       "checks required",
+		`{"enis": ["eni-00000001"], "checks": [{"type": "icmp"}]}`:                                                                         `checks: unknown check type "icmp"`,
+		`{"enis": ["eni-00000001"], "checks": [{"type": "exec", "command": ["true"]}], "interval": 2}`:                                     `duration must be a string such as "2s": 2`,
+		`{"enis": ["eni-00000001"], "checks": [{"type": "exec", "command": ["true"]}], "intreval": "2s"}`:                                  `json: unknown field "intreval"`,
+		`{"enis": ["eni-00000001"], "checks": [{"type": "exec", "command": ["true"]}], "heartbeat": {}}`:                                   "heartbeat requires listen",
+		`{"enis": ["eni-00000001"], "checks": [{"type": "exec", "command": ["true"]}], "heartbeat": {"listen": ":7946", "timeout": "1s"}}`: "heartbeat timeout must be longer than its interval",
+	}
+	for config, expected := range errs {
+		_, err := ParseConfig([]byte(config))
+		assert.EqualError(t, err, expected, config)
+	}
+}
+
+func TestOwnerIPPlaceholder(t *testing.T) {
+	checkers, err := buildCheckers([]CheckConfig{
+		{Type: "http", URL: "http://{owner_ip}:8080/health"},
+		{Type: "exec", Command: []string{"mysqladmin", "-h", "{owner_ip}", "ping"}},
+	}, strings.NewReplacer(OwnerIPPlaceholder, "10.0.0.100"))
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, "http http://10.0.0.100:8080/health", checkers[0].String())
+		assert.Equal(t, `exec ["mysqladmin" "-h" "10.0.0.100" "ping"]`, checkers[1].String())
+	}
+}